@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// apiCalls bounds the API requests in flight across every client and
+// goroutine when --max-concurrent-api-calls is set.
+var (
+	apiCalls     chan struct{}
+	apiCallsOnce sync.Once
+)
+
+// limitAPICalls wraps a client transport so every request holds a slot of the
+// shared semaphore until its response body is closed.
+func limitAPICalls(rt http.RoundTripper) http.RoundTripper {
+	apiCallsOnce.Do(func() {
+		apiCalls = make(chan struct{}, maxAPICalls)
+	})
+	return &limitedRoundTripper{rt: rt}
+}
+
+type limitedRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func (l *limitedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case apiCalls <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	var once sync.Once
+	release := func() { once.Do(func() { <-apiCalls }) }
+
+	resp, err := l.rt.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees the semaphore slot of a request once its body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
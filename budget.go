@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+)
+
+// maxTotalSnapshots caps the origin snapshots a run creates, 0 for no limit.
+var maxTotalSnapshots int
+
+// snapshotBudget is the --max-total-snapshots budget of a run: how many
+// origin snapshots it may create, how many it did, and the PVCs it did not
+// start once the budget was used up.
+type snapshotBudget struct {
+	Limit   int         `json:"limit"`
+	Used    int         `json:"used"`
+	Skipped []objectRef `json:"skipped,omitempty"`
+}
+
+// newSnapshotBudget returns the budget of a run, or nil without
+// --max-total-snapshots.
+func newSnapshotBudget() *snapshotBudget {
+	if maxTotalSnapshots == 0 {
+		return nil
+	}
+	return &snapshotBudget{Limit: maxTotalSnapshots}
+}
+
+// exhausted tells whether the next PVC could create a snapshot over budget.
+func (b *snapshotBudget) exhausted() bool {
+	return b != nil && b.Used >= b.Limit
+}
+
+// record counts the origin snapshot the migration m created, if any. A
+// snapshot given with --source-snapshot or reused with --reuse-in-progress is
+// not created by the run, while one deleted by the cleanup after a failure
+// was.
+func (b *snapshotBudget) record(m *migration, res *migrationResult) {
+	if b != nil && res != nil && res.OriginSnapshot != nil && !m.existingSnapshot {
+		b.Used++
+	}
+}
+
+// skip records the migrations not started for lack of budget.
+func (b *snapshotBudget) skip(migrations []*migration) {
+	for _, m := range migrations {
+		b.Skipped = append(b.Skipped, objectRef{Namespace: m.pvcNamespace, Name: m.pvcName})
+	}
+}
+
+// err returns the error of a run that left PVCs out, or nil.
+func (b *snapshotBudget) err() error {
+	if b == nil || len(b.Skipped) == 0 {
+		return nil
+	}
+	return fmt.Errorf("reached --max-total-snapshots %d, %d PVCs not attempted", b.Limit, len(b.Skipped))
+}
+
+// checkGroupBudget refuses a VolumeGroupSnapshot of more volumes than
+// --max-total-snapshots allows, since it snapshots them all at once.
+func checkGroupBudget(volumes int) error {
+	if maxTotalSnapshots == 0 || volumes <= maxTotalSnapshots {
+		return nil
+	}
+	return fmt.Errorf("VolumeGroupSnapshot of %d PVCs exceeds --max-total-snapshots %d, refusing to take it", volumes, maxTotalSnapshots)
+}
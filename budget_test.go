@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckGroupBudget(t *testing.T) {
+	if err := checkGroupBudget(5); err != nil {
+		t.Errorf("checkGroupBudget without a budget = %v", err)
+	}
+
+	setFlag(t, &maxTotalSnapshots, 3)
+	tests := []struct {
+		volumes int
+		refused bool
+	}{
+		{1, false},
+		{3, false},
+		{4, true},
+	}
+	for _, tt := range tests {
+		if err := checkGroupBudget(tt.volumes); (err != nil) != tt.refused {
+			t.Errorf("checkGroupBudget(%d) = %v, want refused %v", tt.volumes, err, tt.refused)
+		}
+	}
+}
+
+func TestSnapshotBudgetRecord(t *testing.T) {
+	var none *snapshotBudget
+	none.record(newMigration("apps", "data"), &migrationResult{OriginSnapshot: &objectRef{}})
+	if none.exhausted() || none.err() != nil {
+		t.Errorf("a run without --max-total-snapshots has a budget")
+	}
+
+	setFlag(t, &maxTotalSnapshots, 1)
+	b := newSnapshotBudget()
+	// Failed before taking a snapshot
+	b.record(newMigration("apps", "data"), &migrationResult{})
+	existing := newMigration("apps", "logs")
+	existing.existingSnapshot = true
+	b.record(existing, &migrationResult{OriginSnapshot: &objectRef{}})
+	if b.exhausted() {
+		t.Fatalf("budget used by migrations that created no snapshot: %+v", b)
+	}
+	b.record(newMigration("apps", "cache"), &migrationResult{OriginSnapshot: &objectRef{}})
+	if !b.exhausted() {
+		t.Errorf("budget not used up by a created snapshot: %+v", b)
+	}
+}
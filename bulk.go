@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// confirmThreshold is the number of PVCs above which a bulk migration asks for
+// confirmation unless --yes is given.
+const confirmThreshold = 10
+
+// listMigrations lists the origin PVCs matching --selector, in the source
+// namespace or cluster-wide with --all-namespaces, and resolves a migration for
+// each of them.
+func listMigrations(ctx context.Context, client kubernetes.Interface) ([]*migration, error) {
+	namespace := pvcNamespace
+	if allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	fmt.Fprintf(stepOut, "Listing PVCs matching selector %q...\n", selector)
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+
+	migrations := make([]*migration, 0, len(pvcs.Items))
+	for _, pvc := range pvcs.Items {
+		migrations = append(migrations, newMigration(pvc.Namespace, pvc.Name))
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		if migrations[i].pvcNamespace != migrations[j].pvcNamespace {
+			return migrations[i].pvcNamespace < migrations[j].pvcNamespace
+		}
+		return migrations[i].pvcName < migrations[j].pvcName
+	})
+
+	return migrations, nil
+}
+
+// statefulSetMigrations resolves a migration for every PVC of --statefulset:
+// one per replica and volume claim template, named
+// <template>-<statefulset>-<ordinal>.
+func statefulSetMigrations(ctx context.Context, client kubernetes.Interface) ([]*migration, error) {
+	sts, err := client.AppsV1().StatefulSets(pvcNamespace).Get(ctx, statefulSet, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get StatefulSet %s/%s: %w", pvcNamespace, statefulSet, err)
+	}
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return nil, fmt.Errorf("StatefulSet %s/%s has no volumeClaimTemplates", pvcNamespace, statefulSet)
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	fmt.Fprintf(stepOut, "StatefulSet %s/%s has %d replicas, run ID %s:\n", sts.Namespace, sts.Name, replicas, runID)
+
+	var migrations []*migration
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			name := fmt.Sprintf("%s-%s-%d", template.Name, sts.Name, ordinal)
+			if _, err := client.CoreV1().PersistentVolumeClaims(sts.Namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+				return nil, fmt.Errorf("failed to get PVC %s of replica %d: %w", name, ordinal, err)
+			}
+			fmt.Fprintf(stepOut, "  %s\n", name)
+			migrations = append(migrations, newMigration(sts.Namespace, name))
+		}
+	}
+
+	return migrations, nil
+}
+
+// podMigrations resolves a migration for every PVC mounted by --pod.
+func podMigrations(ctx context.Context, client kubernetes.Interface) ([]*migration, error) {
+	pod, err := client.CoreV1().Pods(pvcNamespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Pod %s/%s: %w", pvcNamespace, podName, err)
+	}
+	return claimMigrations(ctx, client, "Pod "+pod.Namespace+"/"+pod.Name, pod.Namespace, pod.Spec.Volumes)
+}
+
+// deploymentMigrations resolves a migration for every PVC mounted by the pod
+// template of --deployment, which all its pods share.
+func deploymentMigrations(ctx context.Context, client kubernetes.Interface) ([]*migration, error) {
+	deploy, err := client.AppsV1().Deployments(pvcNamespace).Get(ctx, deployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Deployment %s/%s: %w", pvcNamespace, deployment, err)
+	}
+	return claimMigrations(ctx, client, "Deployment "+deploy.Namespace+"/"+deploy.Name, deploy.Namespace, deploy.Spec.Template.Spec.Volumes)
+}
+
+// claimMigrations resolves a migration for every PVC referenced by the
+// volumes of a workload, and lists them.
+func claimMigrations(ctx context.Context, client kubernetes.Interface, workload, namespace string, volumes []corev1.Volume) ([]*migration, error) {
+	var claims []string
+	seen := make(map[string]bool)
+	for _, v := range volumes {
+		if v.PersistentVolumeClaim == nil || seen[v.PersistentVolumeClaim.ClaimName] {
+			continue
+		}
+		seen[v.PersistentVolumeClaim.ClaimName] = true
+		claims = append(claims, v.PersistentVolumeClaim.ClaimName)
+	}
+	if len(claims) == 0 {
+		fmt.Fprintf(stepOut, "%s mounts no PVCs\n", workload)
+		return nil, nil
+	}
+
+	fmt.Fprintf(stepOut, "%s mounts %d PVCs, run ID %s:\n", workload, len(claims), runID)
+	migrations := make([]*migration, 0, len(claims))
+	for _, name := range claims {
+		if _, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to get PVC %s of %s: %w", name, workload, err)
+		}
+		fmt.Fprintf(stepOut, "  %s\n", name)
+		migrations = append(migrations, newMigration(namespace, name))
+	}
+	return migrations, nil
+}
+
+// printMigrationPlan prints the number of PVCs to migrate and a per-namespace
+// breakdown.
+func printMigrationPlan(migrations []*migration) {
+	counts := make(map[string]int)
+	targets := make(map[string]string)
+	for _, m := range migrations {
+		counts[m.pvcNamespace]++
+		targets[m.pvcNamespace] = m.destNamespace
+	}
+
+	namespaces := make([]string, 0, len(counts))
+	for ns := range counts {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	fmt.Fprintf(stepOut, "Found %d PVCs in %d namespaces:\n", len(migrations), len(namespaces))
+	for _, ns := range namespaces {
+		fmt.Fprintf(stepOut, "  %s: %d PVCs -> %s\n", ns, counts[ns], targets[ns])
+	}
+	if maxTotalSnapshots > 0 && len(migrations) > maxTotalSnapshots {
+		fmt.Fprintf(stepOut, "⚠ Warning: --max-total-snapshots %d is less than the %d PVCs, the PVCs past the budget will not be attempted\n", maxTotalSnapshots, len(migrations))
+	}
+}
+
+// confirm asks the user a yes/no question on stdin.
+func confirm(prompt string) (bool, error) {
+	fmt.Fprintf(stepOut, "%s [y/N]: ", prompt)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Destination snapshot class selection modes accepted by --select-dest-class-by
+const (
+	classSelectCopy   = "copy"
+	classSelectDriver = "driver"
+)
+
+// destSnapshotClasses returns the snapshot class to set on a destination's
+// content and on its snapshot. By default the content copies the origin
+// content's class and the snapshot uses --snapshot-class; a fleet file class,
+// --dest-snapshot-class, a --class-map-file entry for the driver,
+// --select-dest-class-by=driver or --dest-snapshot-class-selector replace both.
+func destSnapshotClasses(ctx context.Context, d *destination, driver string) (string, string, error) {
+	var (
+		class string
+		err   error
+	)
+	switch {
+	case d.snapshotClass != "":
+		class = d.snapshotClass
+		err = checkDestClassDriver(ctx, d, class, driver)
+	case destSnapClass != "":
+		class = destSnapClass
+		err = checkDestClassDriver(ctx, d, class, driver)
+	case classMapping.destClass(d.name, driver) != "":
+		class = classMapping.destClass(d.name, driver)
+		err = checkMappedClass(ctx, d.snap, "destination cluster "+d.name, class, driver)
+	case destClassSelector != "":
+		class, err = selectClassByLabels(ctx, d, destClassSelector, driver)
+	case selectDestClass == classSelectDriver:
+		class, err = selectClassByDriver(ctx, d, driver)
+	default:
+		return "", snapshotClass, nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return class, class, nil
+}
+
+// originSnapshotClass returns the class of the origin snapshot of pvc:
+// --snapshot-class if set, otherwise the class --class-map-file maps for the
+// driver of the PVC's volume, otherwise "" for the cluster default.
+func originSnapshotClass(ctx context.Context, c *clusterClients, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if (snapshotClass == "" && classMapping == nil) || pvc.Spec.VolumeName == "" {
+		return snapshotClass, nil
+	}
+	pv, err := c.originK8s.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get PersistentVolume of PVC %s/%s: %w", pvc.Namespace, pvc.Name, err)
+	}
+	if pv.Spec.CSI == nil {
+		return snapshotClass, nil
+	}
+	return originClassForDriver(ctx, c.originSnap, pvc, pv.Spec.CSI.Driver)
+}
+
+// originClassForDriver returns --snapshot-class if set, otherwise the validated
+// class --class-map-file maps for driver in the origin cluster, or "". Either
+// must belong to driver, the driver of pvc's volume.
+func originClassForDriver(ctx context.Context, client snapshotclient.Interface, pvc *corev1.PersistentVolumeClaim, driver string) (string, error) {
+	if snapshotClass != "" {
+		if driver == "" {
+			return snapshotClass, nil
+		}
+		classDriver, err := snapshotClassDriver(ctx, client, "the origin cluster", snapshotClass)
+		if err != nil {
+			return "", err
+		}
+		if classDriver != driver {
+			return "", fmt.Errorf("snapshot class %s uses driver %s but PVC %s/%s is provisioned by driver %s", snapshotClass, classDriver, pvc.Namespace, pvc.Name, driver)
+		}
+		return snapshotClass, nil
+	}
+	class := classMapping.originClass(driver)
+	if class == "" {
+		return "", nil
+	}
+	if err := checkMappedClass(ctx, client, "the origin cluster", class, driver); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(stepOut, "Using origin VolumeSnapshotClass %s for driver %s from the class map\n", class, driver)
+	return class, nil
+}
+
+// checkDestClassDriver fails early when a destination class given on the
+// command line or in a fleet file belongs to another driver than the snapshot,
+// instead of leaving the destination snapshot unable to bind.
+func checkDestClassDriver(ctx context.Context, d *destination, name, driver string) error {
+	classDriver, err := snapshotClassDriver(ctx, d.snap, "destination cluster "+d.name, name)
+	if err != nil {
+		return err
+	}
+	if classDriver != driver {
+		return fmt.Errorf("snapshot class %s uses driver %s in destination cluster %s but the snapshot is of driver %s", name, classDriver, d.name, driver)
+	}
+	return nil
+}
+
+// snapshotClassDriver returns the driver of the named VolumeSnapshotClass in
+// cluster.
+func snapshotClassDriver(ctx context.Context, client snapshotclient.Interface, cluster, name string) (string, error) {
+	class, err := client.SnapshotV1().VolumeSnapshotClasses().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("snapshot class %s does not exist in %s", name, cluster)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get VolumeSnapshotClass %s in %s: %w", name, cluster, err)
+	}
+	return class.Driver, nil
+}
+
+// selectClassByDriver picks a destination VolumeSnapshotClass for the driver:
+// the default class if there is one, otherwise the first by name.
+func selectClassByDriver(ctx context.Context, d *destination, driver string) (string, error) {
+	classes, err := listClasses(ctx, d.snap, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to list destination VolumeSnapshotClasses: %w", err)
+	}
+
+	var candidates []string
+	chosen := ""
+	for _, class := range classes {
+		if class.Driver != driver {
+			continue
+		}
+		candidates = append(candidates, class.Name)
+		if class.Default && chosen == "" {
+			chosen = class.Name
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no VolumeSnapshotClass for driver %s in destination cluster %s", driver, d.name)
+	}
+	if chosen == "" {
+		chosen = candidates[0]
+	}
+
+	fmt.Fprintf(stepOut, "%sDestination VolumeSnapshotClasses for driver %s: %s, using %s\n", d.prefix, driver, strings.Join(candidates, ", "), chosen)
+	return chosen, nil
+}
+
+// selectClassByLabels picks the first destination VolumeSnapshotClass by name
+// that matches the label selector and uses the origin driver.
+func selectClassByLabels(ctx context.Context, d *destination, selector, driver string) (string, error) {
+	classes, err := listClasses(ctx, d.snap, selector)
+	if err != nil {
+		return "", fmt.Errorf("failed to list destination VolumeSnapshotClasses: %w", err)
+	}
+	if len(classes) == 0 {
+		return "", fmt.Errorf("no VolumeSnapshotClass matches %q in destination cluster %s", selector, d.name)
+	}
+
+	var others []string
+	for _, class := range classes {
+		if class.Driver == driver {
+			fmt.Fprintf(stepOut, "%sDestination VolumeSnapshotClass %s matches %q, using it\n", d.prefix, class.Name, selector)
+			return class.Name, nil
+		}
+		others = append(others, fmt.Sprintf("%s (%s)", class.Name, class.Driver))
+	}
+
+	return "", fmt.Errorf("VolumeSnapshotClasses matching %q in destination cluster %s use other drivers than %s: %s", selector, d.name, driver, strings.Join(others, ", "))
+}
+
+// listClasses lists the VolumeSnapshotClasses matching a label selector,
+// sorted by name.
+func listClasses(ctx context.Context, client snapshotclient.Interface, selector string) ([]classInfo, error) {
+	list, err := client.SnapshotV1().VolumeSnapshotClasses().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	classes := make([]classInfo, 0, len(list.Items))
+	for _, c := range list.Items {
+		classes = append(classes, classInfo{
+			Name:           c.Name,
+			Driver:         c.Driver,
+			DeletionPolicy: string(c.DeletionPolicy),
+			Default:        c.Annotations[defaultSnapshotClassAnnotation] == "true",
+		})
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+
+	return classes, nil
+}
+
+// resolveSnapshotClass returns the named VolumeSnapshotClass, or the default
+// class for the driver when name is empty. It returns nil if there is no
+// default class for the driver.
+func resolveSnapshotClass(ctx context.Context, client snapshotclient.Interface, name, driver string) (*snapshotv1.VolumeSnapshotClass, error) {
+	if name != "" {
+		class, err := client.SnapshotV1().VolumeSnapshotClasses().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VolumeSnapshotClass %s: %w", name, err)
+		}
+		return class, nil
+	}
+
+	classes, err := client.SnapshotV1().VolumeSnapshotClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotClasses: %w", err)
+	}
+
+	for i := range classes.Items {
+		class := &classes.Items[i]
+		if class.Driver == driver && class.Annotations[defaultSnapshotClassAnnotation] == "true" {
+			return class, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// volumeAttributesClassVersions are the group versions that may serve
+// VolumeAttributesClass, newest first.
+var volumeAttributesClassVersions = []string{"storage.k8s.io/v1", "storage.k8s.io/v1beta1", "storage.k8s.io/v1alpha1"}
+
+// volumeAttributesClassVersion returns the group version serving
+// VolumeAttributesClass in a cluster, or "" if the feature is not enabled.
+func volumeAttributesClassVersion(client kubernetes.Interface) string {
+	for _, gv := range volumeAttributesClassVersions {
+		resources, err := client.Discovery().ServerResourcesForGroupVersion(gv)
+		if err != nil {
+			continue
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == "volumeattributesclasses" {
+				return gv
+			}
+		}
+	}
+	return ""
+}
+
+// resolveVolumeAttributesClass returns the VolumeAttributesClass for a
+// destination PVC: --dest-volume-attributes-class, which must exist in the
+// destination, or the source PVC's class. It returns nil when neither is set
+// or the destination does not support VolumeAttributesClass.
+func resolveVolumeAttributesClass(ctx context.Context, d *destination, sourcePVC *corev1.PersistentVolumeClaim) (*string, error) {
+	name := sourcePVC.Spec.VolumeAttributesClassName
+	if destVolumeAttributesClass != "" {
+		name = &destVolumeAttributesClass
+	}
+	if name == nil {
+		return nil, nil
+	}
+
+	gv := volumeAttributesClassVersion(d.k8s)
+	if gv == "" {
+		fmt.Fprintf(stepOut, "%s⚠ Warning: destination cluster does not support VolumeAttributesClass, not setting %s on the PVC\n", d.prefix, *name)
+		return nil, nil
+	}
+
+	if destVolumeAttributesClass != "" {
+		err := d.k8s.CoreV1().RESTClient().Get().AbsPath("/apis", gv, "volumeattributesclasses", *name).Do(ctx).Error()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get destination VolumeAttributesClass %s: %w", *name, err)
+		}
+	}
+
+	return name, nil
+}
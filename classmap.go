@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// classMap is the schema of a --class-map-file: the VolumeSnapshotClass to
+// use for each CSI driver in the origin cluster, in every destination, and in
+// named destinations, which take precedence over the shared destination map.
+type classMap struct {
+	Origin       map[string]string            `json:"origin,omitempty"`
+	Destination  map[string]string            `json:"destination,omitempty"`
+	Destinations map[string]map[string]string `json:"destinations,omitempty"`
+}
+
+// classMapping is the loaded --class-map-file, nil when the flag is unset.
+var classMapping *classMap
+
+// loadClassMap reads and validates a --class-map-file.
+func loadClassMap(path string) (*classMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read class map file: %w", err)
+	}
+
+	var m classMap
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid class map file %s: %w", path, err)
+	}
+
+	if err := checkClassMapEntries(m.Origin); err != nil {
+		return nil, fmt.Errorf("invalid class map file %s: origin: %w", path, err)
+	}
+	if err := checkClassMapEntries(m.Destination); err != nil {
+		return nil, fmt.Errorf("invalid class map file %s: destination: %w", path, err)
+	}
+	for name, classes := range m.Destinations {
+		if name == "" {
+			return nil, fmt.Errorf("invalid class map file %s: destinations: empty destination name", path)
+		}
+		if err := checkClassMapEntries(classes); err != nil {
+			return nil, fmt.Errorf("invalid class map file %s: destination %q: %w", path, name, err)
+		}
+	}
+	return &m, nil
+}
+
+// checkClassMapEntries rejects a driver or class name left empty.
+func checkClassMapEntries(classes map[string]string) error {
+	for driver, class := range classes {
+		if driver == "" {
+			return fmt.Errorf("empty driver name")
+		}
+		if class == "" {
+			return fmt.Errorf("driver %s has an empty class name", driver)
+		}
+	}
+	return nil
+}
+
+// originClass returns the class mapped for driver in the origin cluster, or
+// "" if there is none.
+func (m *classMap) originClass(driver string) string {
+	if m == nil {
+		return ""
+	}
+	return m.Origin[driver]
+}
+
+// destClass returns the class mapped for driver in the named destination,
+// falling back to the shared destination map, or "" if there is none.
+func (m *classMap) destClass(dest, driver string) string {
+	if m == nil {
+		return ""
+	}
+	if class, ok := m.Destinations[dest][driver]; ok {
+		return class
+	}
+	return m.Destination[driver]
+}
+
+// checkMappedClass verifies that the class mapped for driver exists in the
+// cluster and belongs to that driver.
+func checkMappedClass(ctx context.Context, client snapshotclient.Interface, cluster, name, driver string) error {
+	class, err := client.SnapshotV1().VolumeSnapshotClasses().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("VolumeSnapshotClass %s mapped for driver %s does not exist in %s", name, driver, cluster)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get VolumeSnapshotClass %s in %s: %w", name, cluster, err)
+	}
+	if class.Driver != driver {
+		return fmt.Errorf("VolumeSnapshotClass %s mapped for driver %s in %s uses driver %s", name, driver, cluster, class.Driver)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadClassMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `origin:
+  ebs.csi.aws.com: ebs-snapclass
+destination:
+  ebs.csi.aws.com: ebs-dr-snapclass
+destinations:
+  dr-west:
+    ebs.csi.aws.com: ebs-west-snapclass
+`,
+		},
+		{name: "unknown field", content: "clusters: {}\n", wantErr: "unknown field"},
+		{name: "empty class", content: "origin:\n  ebs.csi.aws.com: \"\"\n", wantErr: "origin: driver ebs.csi.aws.com has an empty class name"},
+		{name: "empty driver", content: "destination:\n  \"\": ebs-snapclass\n", wantErr: "destination: empty driver name"},
+		{name: "empty destination class", content: "destinations:\n  dr-west:\n    ebs.csi.aws.com: \"\"\n", wantErr: `destination "dr-west"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "classes.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadClassMap(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("loadClassMap: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("loadClassMap = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClassMapLookup(t *testing.T) {
+	m := &classMap{
+		Origin:       map[string]string{"ebs.csi.aws.com": "ebs-snapclass"},
+		Destination:  map[string]string{"ebs.csi.aws.com": "ebs-dr-snapclass"},
+		Destinations: map[string]map[string]string{"dr-west": {"ebs.csi.aws.com": "ebs-west-snapclass"}},
+	}
+	tests := []struct {
+		got, want string
+	}{
+		{m.originClass("ebs.csi.aws.com"), "ebs-snapclass"},
+		{m.originClass("pd.csi.storage.gke.io"), ""},
+		{m.destClass("dr-west", "ebs.csi.aws.com"), "ebs-west-snapclass"},
+		{m.destClass("dr-east", "ebs.csi.aws.com"), "ebs-dr-snapclass"},
+		{m.destClass("dr-west", "pd.csi.storage.gke.io"), ""},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("lookup %d = %q, want %q", i, tt.got, tt.want)
+		}
+	}
+
+	var unset *classMap
+	if got := unset.originClass("ebs.csi.aws.com") + unset.destClass("dr-west", "ebs.csi.aws.com"); got != "" {
+		t.Errorf("unset class map = %q, want no classes", got)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// cleanupContext returns the context to clean up after a failure with. The
+// run's context may be done by then, through --timeout, --deadline or Ctrl-C,
+// so cleanup gets a fresh --timeout-grace budget of its own instead.
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), timeoutGrace)
+}
+
+// reportGraceExhausted tells when cleanup ran out of its --timeout-grace,
+// which leaves the resources it did not get to in place.
+func reportGraceExhausted(ctx context.Context) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Fprintf(stepOut, "  ✗ Cleanup did not finish within --timeout-grace (%s), check for resources left behind\n", timeoutGrace)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Results of a cleanup action
+const (
+	cleanupDeleted = "deleted"
+	cleanupFailed  = "failed"
+	cleanupKept    = "kept"
+	cleanupStuck   = "stuck"
+)
+
+// cleanupRecord is one line of the --cleanup-report file. Resources with a
+// failed, kept or stuck result are left behind in the cluster.
+type cleanupRecord struct {
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster"`
+	Context   string    `json:"context,omitempty"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+	// Finalizers are those a stuck content is waiting on
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+var cleanupReportMu sync.Mutex
+
+// recordCleanup reports the outcome of deleting a resource during cleanup in
+// a cluster, named as in the output, of the given kubeconfig context.
+func recordCleanup(cluster, context, kind, namespace, name string, err error) {
+	record := cleanupRecord{Cluster: cluster, Context: context, Kind: kind, Namespace: namespace, Name: name, Result: cleanupDeleted}
+	if err != nil {
+		record.Result = cleanupFailed
+		record.Error = err.Error()
+	}
+	writeCleanupRecord(record)
+}
+
+// recordContentCleanup reports the outcome of deleting a VolumeSnapshotContent,
+// directly or through its snapshot: stuck, with its finalizers, when it is
+// still there at the end of --wait-for-content-deletion.
+func recordContentCleanup(cluster, context, name string, err error) {
+	var stuck *contentStuckError
+	if !errors.As(err, &stuck) {
+		recordCleanup(cluster, context, "VolumeSnapshotContent", "", name, err)
+		return
+	}
+	writeCleanupRecord(cleanupRecord{
+		Cluster:    cluster,
+		Context:    context,
+		Kind:       "VolumeSnapshotContent",
+		Name:       name,
+		Result:     cleanupStuck,
+		Error:      err.Error(),
+		Finalizers: stuck.content.Finalizers,
+	})
+}
+
+// recordCleanupKept reports a resource cleanup deliberately left in place.
+func recordCleanupKept(cluster, context, kind, namespace, name string) {
+	writeCleanupRecord(cleanupRecord{Cluster: cluster, Context: context, Kind: kind, Namespace: namespace, Name: name, Result: cleanupKept})
+}
+
+func writeCleanupRecord(record cleanupRecord) {
+	if cleanupReport == "" {
+		return
+	}
+	record.Time = time.Now().UTC()
+
+	cleanupReportMu.Lock()
+	defer cleanupReportMu.Unlock()
+
+	if err := appendJSONLine(cleanupReport, record); err != nil {
+		fmt.Fprintf(stepOut, "  ⚠ Warning: Failed to write cleanup report: %v\n", err)
+	}
+}
+
+// appendJSONLine appends v to a JSON Lines file, creating it if needed.
+func appendJSONLine(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
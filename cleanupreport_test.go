@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCleanupReportNamesDestination(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "cleanup.jsonl")
+	setFlag(t, &cleanupReport, report)
+	setFlag(t, &originContext, "prod")
+	ctx := context.Background()
+
+	newDest := func(name, context string) *destination {
+		client := snapfake.NewSimpleClientset(
+			&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "data-snap"}},
+			&snapshotv1.VolumeSnapshotContent{ObjectMeta: metav1.ObjectMeta{Name: "snapcontent-apps-data-snap"}},
+		)
+		return &destination{name: name, context: context, snap: client}
+	}
+	east, west := newDest("dr-east", "east"), newDest("dr-west", "west")
+	west.snap.(*snapfake.Clientset).PrependReactor("delete", "volumesnapshotcontents", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	origin := snapfake.NewSimpleClientset(&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "data-snap"}})
+
+	captureStdout(t, func() {
+		for _, d := range []*destination{east, west} {
+			st := &destState{contentName: "snapcontent-apps-data-snap", contentCreated: true, snapshotCreated: true}
+			cleanupOnFailure(ctx, failedInDest, originCleanup{}, &destCleanup{d: d, st: st, namespace: "apps", name: "data-snap"})
+		}
+		cleanupOnFailure(ctx, failedInOrigin, originCleanup{snap: origin, created: true, namespace: "apps", name: "data-snap"}, nil)
+	})
+
+	got := readCleanupReport(t, report)
+
+	want := []cleanupRecord{
+		{Cluster: "dr-east", Context: "east", Kind: "VolumeSnapshot", Namespace: "apps", Name: "data-snap", Result: cleanupDeleted},
+		{Cluster: "dr-east", Context: "east", Kind: "VolumeSnapshotContent", Name: "snapcontent-apps-data-snap", Result: cleanupDeleted},
+		{Cluster: "dr-west", Context: "west", Kind: "VolumeSnapshot", Namespace: "apps", Name: "data-snap", Result: cleanupDeleted},
+		{Cluster: "dr-west", Context: "west", Kind: "VolumeSnapshotContent", Name: "snapcontent-apps-data-snap", Result: cleanupFailed, Error: "forbidden"},
+		{Cluster: "origin", Context: "prod", Kind: "VolumeSnapshot", Namespace: "apps", Name: "data-snap", Result: cleanupDeleted},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		got[i].Time = want[i].Time
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCleanupReportStuckContent(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "cleanup.jsonl")
+	setFlag(t, &cleanupReport, report)
+	setFlag(t, &contentDeletionWait, 20*time.Millisecond)
+	ctx := context.Background()
+
+	now := metav1.Now()
+	client := snapfake.NewSimpleClientset(&snapshotv1.VolumeSnapshotContent{ObjectMeta: metav1.ObjectMeta{
+		Name:              "snapcontent-apps-data-snap",
+		DeletionTimestamp: &now,
+		Finalizers:        []string{"snapshot.storage.kubernetes.io/volumesnapshotcontent-bound-protection"},
+	}})
+	// The finalizer keeps the content in Terminating
+	client.PrependReactor("delete", "volumesnapshotcontents", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	d := &destination{name: "dr", context: "dr", snap: client}
+	st := &destState{contentName: "snapcontent-apps-data-snap", contentCreated: true}
+	captureStdout(t, func() {
+		cleanupOnFailure(ctx, failedInDest, originCleanup{}, &destCleanup{d: d, st: st, namespace: "apps", name: "data-snap"})
+	})
+
+	got := readCleanupReport(t, report)
+	if len(got) != 1 {
+		t.Fatalf("got %d records, want 1: %+v", len(got), got)
+	}
+	r := got[0]
+	if r.Result != cleanupStuck || !strings.Contains(r.Error, "stuck in Terminating") {
+		t.Errorf("record = %+v, want the content stuck", r)
+	}
+	if !reflect.DeepEqual(r.Finalizers, []string{"snapshot.storage.kubernetes.io/volumesnapshotcontent-bound-protection"}) {
+		t.Errorf("finalizers = %v", r.Finalizers)
+	}
+
+	// The origin content is deleted by the snapshot controller along with its
+	// snapshot, which here never happens
+	contentName := "snapcontent-apps-origin"
+	origin := snapfake.NewSimpleClientset(
+		&snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "origin"},
+			Status:     &snapshotv1.VolumeSnapshotStatus{BoundVolumeSnapshotContentName: &contentName},
+		},
+		&snapshotv1.VolumeSnapshotContent{
+			ObjectMeta: metav1.ObjectMeta{Name: contentName},
+			Spec:       snapshotv1.VolumeSnapshotContentSpec{DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete},
+		},
+	)
+	captureStdout(t, func() {
+		cleanupOnFailure(ctx, failedInOrigin, originCleanup{snap: origin, created: true, namespace: "apps", name: "origin"}, nil)
+	})
+	got = readCleanupReport(t, report)
+	if len(got) != 3 || got[1].Kind != "VolumeSnapshot" || got[1].Result != cleanupDeleted {
+		t.Fatalf("records = %+v, want the origin snapshot deleted", got)
+	}
+	if r := got[2]; r.Cluster != "origin" || r.Name != contentName || r.Result != cleanupStuck {
+		t.Errorf("origin content record = %+v, want it stuck", r)
+	}
+}
+
+// readCleanupReport returns the records of a --cleanup-report file.
+func readCleanupReport(t *testing.T, path string) []cleanupRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []cleanupRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r cleanupRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// Values of --cleanup-scope
+const (
+	cleanupScopeDest   = "dest"
+	cleanupScopeOrigin = "origin"
+	cleanupScopeBoth   = "both"
+)
+
+// validateCleanupScope checks --cleanup-scope and its combination with
+// --cleanup-origin-on-dest-failure.
+func validateCleanupScope() error {
+	switch cleanupScope {
+	case cleanupScopeDest, cleanupScopeOrigin, cleanupScopeBoth:
+	default:
+		return fmt.Errorf("invalid --cleanup-scope value %q: must be %q, %q or %q", cleanupScope, cleanupScopeDest, cleanupScopeOrigin, cleanupScopeBoth)
+	}
+	if cleanupScope == cleanupScopeDest && cleanupOriginOnDestFailure {
+		return fmt.Errorf("--cleanup-origin-on-dest-failure cannot be used with --cleanup-scope %s, which never deletes the origin snapshot", cleanupScopeDest)
+	}
+	return nil
+}
+
+// cleansOrigin reports whether cleanup after a failure may delete resources
+// in the origin cluster.
+func cleansOrigin() bool {
+	return cleanupScope != cleanupScopeDest
+}
+
+// cleansDest reports whether cleanup after a failure may delete resources in
+// the destination clusters.
+func cleansDest() bool {
+	return cleanupScope != cleanupScopeOrigin
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCleanupScope(t *testing.T) {
+	tests := []struct {
+		scope                string
+		wantOrigin, wantDest bool // whether each side is left in place
+	}{
+		{cleanupScopeBoth, false, false},
+		{cleanupScopeDest, true, false},
+		{cleanupScopeOrigin, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			setFlag(t, &cleanupScope, tt.scope)
+			ctx := context.Background()
+			origin := snapfake.NewSimpleClientset(&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "data-snap"}})
+			dest := snapfake.NewSimpleClientset(
+				&snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "data-snap"}},
+				&snapshotv1.VolumeSnapshotContent{ObjectMeta: metav1.ObjectMeta{Name: "snapcontent-data-snap"}},
+			)
+
+			captureStdout(t, func() {
+				cleanupOnFailure(ctx, failedInDest,
+					originCleanup{snap: origin, created: true, namespace: "apps", name: "data-snap"},
+					&destCleanup{
+						d:         &destination{name: "dr", snap: dest},
+						st:        &destState{contentName: "snapcontent-data-snap", contentCreated: true, snapshotCreated: true},
+						namespace: "apps",
+						name:      "data-snap",
+					})
+			})
+
+			exists := func(err error) bool {
+				if err != nil && !apierrors.IsNotFound(err) {
+					t.Fatal(err)
+				}
+				return err == nil
+			}
+			_, err := origin.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{})
+			if got := exists(err); got != tt.wantOrigin {
+				t.Errorf("origin snapshot exists = %v, want %v", got, tt.wantOrigin)
+			}
+			_, err = dest.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{})
+			if got := exists(err); got != tt.wantDest {
+				t.Errorf("destination snapshot exists = %v, want %v", got, tt.wantDest)
+			}
+			_, err = dest.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-data-snap", metav1.GetOptions{})
+			if got := exists(err); got != tt.wantDest {
+				t.Errorf("destination content exists = %v, want %v", got, tt.wantDest)
+			}
+		})
+	}
+}
+
+func TestValidateCleanupScope(t *testing.T) {
+	setFlag(t, &cleanupScope, "all")
+	if err := validateCleanupScope(); err == nil {
+		t.Error("validateCleanupScope accepted an unknown scope")
+	}
+	setFlag(t, &cleanupScope, cleanupScopeDest)
+	setFlag(t, &cleanupOriginOnDestFailure, true)
+	if err := validateCleanupScope(); err == nil {
+		t.Error("validateCleanupScope accepted --cleanup-origin-on-dest-failure with the dest scope")
+	}
+}
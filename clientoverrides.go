@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
+)
+
+// clientOverrides are the connection settings given on the command line for
+// the origin or the destination clusters, on top of their kubeconfig.
+type clientOverrides struct {
+	// tlsServerName is the name verified against the API server certificate,
+	// for clusters behind a proxy or gateway whose certificate does not
+	// match the endpoint. It does not change where the client connects.
+	tlsServerName string
+	// impersonateUser and impersonateGroups are the identity the requests are
+	// made as, like kubectl --as and --as-group.
+	impersonateUser   string
+	impersonateGroups []string
+	// readOnly refuses every request that could change the cluster.
+	readOnly bool
+}
+
+// originOverrides returns the overrides of the origin cluster.
+func originOverrides() clientOverrides {
+	return clientOverrides{
+		tlsServerName:     originTLSServerName,
+		impersonateUser:   originAs,
+		impersonateGroups: originAsGroups,
+		readOnly:          originReadOnly,
+	}
+}
+
+// destOverrides returns the overrides of the destination clusters.
+func destOverrides() clientOverrides {
+	return clientOverrides{
+		tlsServerName:     destTLSServerName,
+		impersonateUser:   destAs,
+		impersonateGroups: destAsGroups,
+	}
+}
+
+// apply sets the overrides on a REST config.
+func (o clientOverrides) apply(config *rest.Config) {
+	if o.tlsServerName != "" {
+		config.TLSClientConfig.ServerName = o.tlsServerName
+	}
+	if o.impersonateUser != "" {
+		config.Impersonate = rest.ImpersonationConfig{
+			UserName: o.impersonateUser,
+			Groups:   o.impersonateGroups,
+		}
+	}
+	if o.readOnly {
+		config.Wrap(refuseWrites)
+	}
+}
+
+// kubectlFlags returns the kubectl flags matching the overrides.
+func (o clientOverrides) kubectlFlags() []string {
+	var flags []string
+	if o.tlsServerName != "" {
+		flags = append(flags, "--tls-server-name "+o.tlsServerName)
+	}
+	if o.impersonateUser != "" {
+		flags = append(flags, "--as "+o.impersonateUser)
+	}
+	for _, group := range o.impersonateGroups {
+		flags = append(flags, "--as-group "+group)
+	}
+	return flags
+}
+
+// validateClientFlags applies the --profile, then checks the connection flags
+// shared by every command.
+func validateClientFlags(cmd *cobra.Command, args []string) error {
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+	if err := validateImpersonation("origin", originAs, originAsGroups); err != nil {
+		return err
+	}
+	return validateImpersonation("dest", destAs, destAsGroups)
+}
+
+// validateImpersonation checks the --<side>-as and --<side>-as-group flags of
+// a side of the migration: groups need a user to impersonate, and a service
+// account must be given as system:serviceaccount:<namespace>:<name>.
+func validateImpersonation(side, user string, groups []string) error {
+	if len(groups) > 0 && user == "" {
+		return fmt.Errorf("--%s-as-group requires --%s-as", side, side)
+	}
+	if user != "" && strings.TrimSpace(user) != user {
+		return fmt.Errorf("invalid --%s-as %q: must not have leading or trailing spaces", side, user)
+	}
+	if sa, ok := strings.CutPrefix(user, serviceAccountUserPrefix); ok {
+		parts := strings.Split(sa, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid --%s-as %q: expected %s<namespace>:<name>", side, user, serviceAccountUserPrefix)
+		}
+	}
+	for _, group := range groups {
+		if group == "" || strings.TrimSpace(group) != group {
+			return fmt.Errorf("invalid --%s-as-group %q: must be a non-empty name without leading or trailing spaces", side, group)
+		}
+	}
+	return nil
+}
+
+// serviceAccountUserPrefix starts the user name of service accounts.
+const serviceAccountUserPrefix = "system:serviceaccount:"
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestClientOverridesTLSServerName(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"major":"1","minor":"29","gitVersion":"v1.29.0"}`))
+	}))
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	// The test certificate is for example.com and the loopback addresses,
+	// not localhost, like a gateway whose certificate does not match the
+	// endpoint
+	host := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name       string
+		serverName string
+		wantErr    bool
+	}{
+		{name: "certificate does not match the endpoint", wantErr: true},
+		{name: "server name override", serverName: "example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &rest.Config{Host: host, TLSClientConfig: rest.TLSClientConfig{CAData: ca}}
+			clientOverrides{tlsServerName: tt.serverName}.apply(config)
+			if config.Host != host {
+				t.Errorf("host = %q, want it unchanged", config.Host)
+			}
+			client, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Discovery().ServerVersion()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ServerVersion error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKubectlFlagsOverrides(t *testing.T) {
+	got := kubectlFlags("/tmp/kubeconfig", "dr", clientOverrides{tlsServerName: "api.internal"})
+	want := "--kubeconfig /tmp/kubeconfig --context dr --tls-server-name api.internal"
+	if got != want {
+		t.Errorf("kubectlFlags = %q, want %q", got, want)
+	}
+}
+
+func TestClientOverridesImpersonation(t *testing.T) {
+	var gotUser string
+	var gotGroups []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = r.Header.Get("Impersonate-User")
+		gotGroups = r.Header.Values("Impersonate-Group")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"major":"1","minor":"29","gitVersion":"v1.29.0"}`))
+	}))
+	defer srv.Close()
+
+	config := &rest.Config{Host: srv.URL}
+	clientOverrides{
+		impersonateUser:   "system:serviceaccount:dr:snapshift",
+		impersonateGroups: []string{"dr-operators", "auditors"},
+	}.apply(config)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		t.Fatalf("ServerVersion: %v", err)
+	}
+	if gotUser != "system:serviceaccount:dr:snapshift" {
+		t.Errorf("Impersonate-User = %q", gotUser)
+	}
+	if strings.Join(gotGroups, ",") != "dr-operators,auditors" {
+		t.Errorf("Impersonate-Group = %q", gotGroups)
+	}
+
+	flags := kubectlFlags("", "", clientOverrides{impersonateUser: "jane", impersonateGroups: []string{"dr-operators"}})
+	if want := "--as jane --as-group dr-operators"; flags != want {
+		t.Errorf("kubectlFlags = %q, want %q", flags, want)
+	}
+}
+
+func TestClientOverridesReadOnly(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"apps"}}`))
+	}))
+	defer srv.Close()
+
+	config := &rest.Config{Host: srv.URL}
+	clientOverrides{readOnly: true}.apply(config)
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := client.CoreV1().Namespaces().Get(ctx, "apps", metav1.GetOptions{}); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}}
+	if _, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); !errors.Is(err, errOriginReadOnly) {
+		t.Errorf("Create = %v, want %v", err, errOriginReadOnly)
+	}
+	if err := client.CoreV1().Namespaces().Delete(ctx, "apps", metav1.DeleteOptions{}); !errors.Is(err, errOriginReadOnly) {
+		t.Errorf("Delete = %v, want %v", err, errOriginReadOnly)
+	}
+	if strings.Join(methods, ",") != http.MethodGet {
+		t.Errorf("requests sent = %v, want only the GET", methods)
+	}
+}
+
+func TestValidateImpersonation(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		groups []string
+		want   string
+	}{
+		{name: "none"},
+		{name: "user", user: "jane@example.com"},
+		{name: "user and groups", user: "jane", groups: []string{"dr-operators", "auditors"}},
+		{name: "service account", user: "system:serviceaccount:dr:snapshift"},
+		{name: "groups without user", groups: []string{"dr-operators"}, want: "--origin-as-group requires --origin-as"},
+		{name: "service account without name", user: "system:serviceaccount:dr", want: "expected system:serviceaccount:<namespace>:<name>"},
+		{name: "service account with empty namespace", user: "system:serviceaccount::snapshift", want: "expected system:serviceaccount:<namespace>:<name>"},
+		{name: "padded user", user: " jane", want: "leading or trailing spaces"},
+		{name: "empty group", user: "jane", groups: []string{""}, want: "invalid --origin-as-group"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateImpersonation("origin", tt.user, tt.groups)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// commandsMu keeps the commands printed by concurrent destinations whole.
+var commandsMu sync.Mutex
+
+// kubectlFlags returns the kubectl flags that select a cluster and connect to
+// it like snapshift does.
+func kubectlFlags(kubeconfig, contextName string, o clientOverrides) string {
+	var flags []string
+	if kubeconfig != "" {
+		flags = append(flags, "--kubeconfig "+kubeconfig)
+	}
+	if contextName != "" {
+		flags = append(flags, "--context "+contextName)
+	}
+	flags = append(flags, o.kubectlFlags()...)
+	return strings.Join(flags, " ")
+}
+
+// originKubectlFlags returns the kubectl flags of the origin cluster.
+func originKubectlFlags() string {
+	return kubectlFlags(originKubeconfig, originContext, originOverrides())
+}
+
+// printApply prints, with --print-equivalent-commands, the kubectl apply of an
+// object rendered by snapshift, as a user could run it by hand against the
+// cluster selected by flags.
+func printApply(flags string, obj runtime.Object) {
+	if !printCommands {
+		return
+	}
+
+	manifest, err := yaml.Marshal(withTypeMeta(obj))
+	if err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Cannot render the equivalent command: %v\n", err)
+		return
+	}
+	printCommand(kubectl(flags, fmt.Sprintf("apply -f - <<'EOF'\n%sEOF", manifest)))
+}
+
+// withTypeMeta returns a copy of an object built by snapshift with its
+// apiVersion and kind set, which typed objects leave empty.
+func withTypeMeta(obj runtime.Object) runtime.Object {
+	obj = obj.DeepCopyObject()
+	switch o := obj.(type) {
+	case *snapshotv1.VolumeSnapshot:
+		o.SetGroupVersionKind(snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshot"))
+	case *snapshotv1.VolumeSnapshotContent:
+		o.SetGroupVersionKind(snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshotContent"))
+	case *corev1.PersistentVolumeClaim:
+		o.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+	}
+	return obj
+}
+
+// printCommand prints, with --print-equivalent-commands, a kubectl command
+// equivalent to what snapshift does next.
+func printCommand(command string) {
+	if !printCommands {
+		return
+	}
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	fmt.Fprintf(stepOut, "# Equivalent command:\n%s\n", command)
+}
+
+// kubectl returns a kubectl command line against the cluster selected by
+// flags.
+func kubectl(flags, args string) string {
+	if flags == "" {
+		return "kubectl " + args
+	}
+	return "kubectl " + flags + " " + args
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// contentDeletionPoll is how often a deleted content is checked for.
+const contentDeletionPoll = 2 * time.Second
+
+// deletingContent returns the content bound to a snapshot about to be deleted
+// when --wait-for-content-deletion is set and the content has the Delete
+// policy, so that the snapshot controller removes it, and the storage snapshot
+// with it, once the snapshot is gone. It returns "" otherwise.
+func deletingContent(ctx context.Context, client snapshotclient.Interface, namespace, name string) string {
+	if contentDeletionWait <= 0 {
+		return ""
+	}
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil || snapshot.Status == nil || snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return ""
+	}
+	content, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, *snapshot.Status.BoundVolumeSnapshotContentName, metav1.GetOptions{})
+	if err != nil || content.Spec.DeletionPolicy != snapshotv1.VolumeSnapshotContentDelete {
+		return ""
+	}
+	return content.Name
+}
+
+// waitContentDeleted waits, with --wait-for-content-deletion, for a deleted
+// VolumeSnapshotContent to be gone. It returns an error if the content is
+// still there at the end of the wait, naming the finalizers it is stuck on and
+// the storage snapshot that may be left behind on the storage backend.
+func waitContentDeleted(ctx context.Context, client snapshotclient.Interface, name string) error {
+	if contentDeletionWait <= 0 {
+		return nil
+	}
+
+	fmt.Fprintf(stepOut, "  Waiting for VolumeSnapshotContent %s to be gone...\n", name)
+	waitCtx, cancel := context.WithTimeout(ctx, contentDeletionWait)
+	defer cancel()
+	ticker := time.NewTicker(contentDeletionPoll)
+	defer ticker.Stop()
+
+	for {
+		content, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			fmt.Fprintf(stepOut, "  ✓ VolumeSnapshotContent %s is gone\n", name)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check VolumeSnapshotContent %s: %w", name, err)
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return &contentStuckError{content: content}
+		case <-ticker.C:
+		}
+	}
+}
+
+// contentStuckError is returned for a deleted content still there at the end
+// of --wait-for-content-deletion.
+type contentStuckError struct {
+	content *snapshotv1.VolumeSnapshotContent
+}
+
+// Error describes the content, the finalizers it is stuck on, and the storage
+// snapshot it may leave behind.
+func (e *contentStuckError) Error() string {
+	content := e.content
+	msg := fmt.Sprintf("VolumeSnapshotContent %s still exists after %s", content.Name, contentDeletionWait)
+	if content.DeletionTimestamp != nil {
+		msg += ", stuck in Terminating"
+		if len(content.Finalizers) > 0 {
+			msg += " on finalizers " + strings.Join(content.Finalizers, ", ")
+		}
+	}
+	if content.Status != nil && content.Status.Error != nil && content.Status.Error.Message != nil {
+		msg += ": " + *content.Status.Error.Message
+	}
+	if handle := contentHandle(content); handle != "" && content.Spec.DeletionPolicy == snapshotv1.VolumeSnapshotContentDelete {
+		msg += fmt.Sprintf("; storage snapshot %s may have to be deleted manually on the storage backend", handle)
+	}
+	return msg
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getOriginCSIDriver returns the origin CSIDriver object of a driver, or nil
+// with a warning if it cannot be read, in which case the driver configuration
+// is not compared.
+func getOriginCSIDriver(ctx context.Context, client kubernetes.Interface, name string) *storagev1.CSIDriver {
+	driver, err := client.StorageV1().CSIDrivers().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Cannot read origin CSIDriver %s, not comparing its configuration with the destination: %v\n", name, err)
+		return nil
+	}
+	return driver
+}
+
+// checkCSIDriver compares the destination CSIDriver with the origin one and
+// warns about differences that can break the restored volume, e.g. in
+// attachRequired or fsGroupPolicy. With --fail-on-driver-config-mismatch a
+// difference, or a driver missing from the destination, is an error.
+func checkCSIDriver(ctx context.Context, d *destination, origin *storagev1.CSIDriver, name string) error {
+	if origin == nil {
+		return nil
+	}
+
+	dest, err := d.k8s.StorageV1().CSIDrivers().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return driverMismatch(d, fmt.Sprintf("CSIDriver %s is not registered in the destination cluster", name))
+	}
+	if err != nil {
+		fmt.Fprintf(stepOut, "%s⚠ Warning: Cannot read destination CSIDriver %s, not comparing its configuration: %v\n", d.prefix, name, err)
+		return nil
+	}
+
+	var diffs []string
+	for _, f := range csiDriverFields(origin, dest) {
+		if f.source != f.dest {
+			diffs = append(diffs, fmt.Sprintf("%s is %s in the origin but %s in the destination", f.field, f.source, f.dest))
+		}
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	return driverMismatch(d, fmt.Sprintf("CSIDriver %s is configured differently: %s", name, strings.Join(diffs, "; ")))
+}
+
+func driverMismatch(d *destination, msg string) error {
+	if failOnDriverMismatch {
+		return fmt.Errorf("%s", msg)
+	}
+	fmt.Fprintf(stepOut, "%s⚠ Warning: %s\n", d.prefix, msg)
+	return nil
+}
+
+// csiDriverFields lists the CSIDriver settings that affect how a restored
+// volume is attached and mounted.
+func csiDriverFields(origin, dest *storagev1.CSIDriver) []fieldDiff {
+	return []fieldDiff{
+		{"attachRequired", boolOrUnset(origin.Spec.AttachRequired), boolOrUnset(dest.Spec.AttachRequired)},
+		{"fsGroupPolicy", fsGroupPolicyOrUnset(origin.Spec.FSGroupPolicy), fsGroupPolicyOrUnset(dest.Spec.FSGroupPolicy)},
+		{"podInfoOnMount", boolOrUnset(origin.Spec.PodInfoOnMount), boolOrUnset(dest.Spec.PodInfoOnMount)},
+		{"requiresRepublish", boolOrUnset(origin.Spec.RequiresRepublish), boolOrUnset(dest.Spec.RequiresRepublish)},
+		{"seLinuxMount", boolOrUnset(origin.Spec.SELinuxMount), boolOrUnset(dest.Spec.SELinuxMount)},
+		{"volumeLifecycleModes", lifecycleModesString(origin.Spec.VolumeLifecycleModes), lifecycleModesString(dest.Spec.VolumeLifecycleModes)},
+	}
+}
+
+func boolOrUnset(b *bool) string {
+	if b == nil {
+		return unset
+	}
+	return strconv.FormatBool(*b)
+}
+
+func fsGroupPolicyOrUnset(p *storagev1.FSGroupPolicy) string {
+	if p == nil {
+		return unset
+	}
+	return string(*p)
+}
+
+func lifecycleModesString(modes []storagev1.VolumeLifecycleMode) string {
+	if len(modes) == 0 {
+		return unset
+	}
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = string(mode)
+	}
+	return strings.Join(names, ",")
+}
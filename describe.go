@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	describeKubeconfig string
+	describeContext    string
+	describeSnapshot   string
+	describeOutput     string
+)
+
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Show a VolumeSnapshot with its content and source PVC",
+	Long: `describe prints a report of the VolumeSnapshot given with --snapshot-name in
+--namespace: its readiness, errors, creation time and restore size, then the
+VolumeSnapshotContent it is bound to, with its driver, snapshot handle and
+deletion policy, and the PVC it was taken from. It only reads from the cluster,
+and is meant for looking into a single snapshot that does not behave.`,
+	Args: cobra.NoArgs,
+	RunE: runDescribe,
+}
+
+func init() {
+	describeCmd.Flags().StringVar(&describeKubeconfig, "kubeconfig", "", "Path to the kubeconfig of the cluster holding the snapshot")
+	describeCmd.Flags().StringVar(&describeContext, "context", "", "Context of the cluster holding the snapshot")
+	describeCmd.Flags().StringVar(&describeSnapshot, "snapshot-name", "", "Name of the VolumeSnapshot to describe")
+	describeCmd.Flags().StringVarP(&describeOutput, "output", "o", outputText, "Output format: text or json")
+	rootCmd.AddCommand(describeCmd)
+}
+
+// snapshotReport is what describe shows of a snapshot and its chain.
+type snapshotReport struct {
+	Cluster   string       `json:"cluster"`
+	Snapshot  snapshotInfo `json:"snapshot"`
+	Content   *contentInfo `json:"content,omitempty"`
+	SourcePVC *pvcInfo     `json:"sourcePVC,omitempty"`
+	// Notes lists the links of the chain that could not be followed.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// snapshotInfo summarizes a VolumeSnapshot.
+type snapshotInfo struct {
+	Namespace          string     `json:"namespace"`
+	Name               string     `json:"name"`
+	Created            time.Time  `json:"created"`
+	Class              string     `json:"class,omitempty"`
+	SourcePVC          string     `json:"sourcePVC,omitempty"`
+	SourceContent      string     `json:"sourceContent,omitempty"`
+	BoundContent       string     `json:"boundContent,omitempty"`
+	ReadyToUse         bool       `json:"readyToUse"`
+	CreationTime       *time.Time `json:"creationTime,omitempty"`
+	RestoreSize        string     `json:"restoreSize,omitempty"`
+	Error              string     `json:"error,omitempty"`
+	ManagedBySnapshift bool       `json:"managedBySnapshift"`
+}
+
+// contentInfo summarizes a VolumeSnapshotContent.
+type contentInfo struct {
+	Name             string `json:"name"`
+	Driver           string `json:"driver"`
+	DriverRegistered bool   `json:"driverRegistered"`
+	SnapshotHandle   string `json:"snapshotHandle,omitempty"`
+	VolumeHandle     string `json:"volumeHandle,omitempty"`
+	DeletionPolicy   string `json:"deletionPolicy"`
+	Class            string `json:"class,omitempty"`
+	SnapshotRef      string `json:"snapshotRef"`
+	SnapshotRefUID   string `json:"snapshotRefUID,omitempty"`
+	ReadyToUse       bool   `json:"readyToUse"`
+	RestoreSize      string `json:"restoreSize,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// pvcInfo summarizes the PVC a snapshot was taken from.
+type pvcInfo struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	Phase        string `json:"phase"`
+	StorageClass string `json:"storageClass,omitempty"`
+	Capacity     string `json:"capacity,omitempty"`
+	Volume       string `json:"volume,omitempty"`
+}
+
+func runDescribe(cmd *cobra.Command, args []string) error {
+	prettySet = cmd.Flags().Changed("pretty")
+	if describeSnapshot == "" {
+		return fmt.Errorf("--snapshot-name is required")
+	}
+	if describeOutput != outputText && describeOutput != outputJSON {
+		return fmt.Errorf("invalid --output value %q: must be %q or %q", describeOutput, outputText, outputJSON)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	k8sClient, snapClient, err := createClients(describeKubeconfig, describeContext, clientOverrides{})
+	if err != nil {
+		return fmt.Errorf("failed to create clients: %w", err)
+	}
+
+	report, err := describeSnapshotChain(ctx, k8sClient, snapClient, pvcNamespace, describeSnapshot)
+	if err != nil {
+		return err
+	}
+	report.Cluster = clusterName(describeKubeconfig, describeContext)
+
+	if describeOutput == outputJSON {
+		return newJSONEncoder(os.Stdout).Encode(report)
+	}
+	printSnapshotReport(os.Stdout, report)
+	return nil
+}
+
+// describeSnapshotChain reads a snapshot, the content it is bound to and the
+// PVC it was taken from. Only a missing snapshot is an error; the links that
+// cannot be followed are noted in the report.
+func describeSnapshotChain(ctx context.Context, k8sClient kubernetes.Interface, snapClient snapshotclient.Interface, namespace, name string) (*snapshotReport, error) {
+	snapshot, err := snapClient.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VolumeSnapshot %s/%s: %w", namespace, name, err)
+	}
+	report := &snapshotReport{Snapshot: snapshotSummary(snapshot)}
+
+	// A pre-provisioned snapshot names its content before binding to it
+	contentName := report.Snapshot.BoundContent
+	if contentName == "" {
+		contentName = report.Snapshot.SourceContent
+		if contentName == "" {
+			report.Notes = append(report.Notes, "the snapshot is not bound to a VolumeSnapshotContent yet")
+		} else {
+			report.Notes = append(report.Notes, fmt.Sprintf("the snapshot is not bound to its pre-provisioned VolumeSnapshotContent %s yet", contentName))
+		}
+	}
+	if contentName != "" {
+		content, err := snapClient.SnapshotV1().VolumeSnapshotContents().Get(ctx, contentName, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			report.Notes = append(report.Notes, fmt.Sprintf("VolumeSnapshotContent %s does not exist", contentName))
+		case err != nil:
+			report.Notes = append(report.Notes, fmt.Sprintf("cannot read VolumeSnapshotContent %s: %v", contentName, err))
+		default:
+			info := contentSummary(content)
+			_, err := k8sClient.StorageV1().CSIDrivers().Get(ctx, info.Driver, metav1.GetOptions{})
+			info.DriverRegistered = err == nil
+			report.Content = &info
+		}
+	}
+
+	if s := snapshot.Spec.Source.PersistentVolumeClaimName; s != nil {
+		pvc, err := k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, *s, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			report.Notes = append(report.Notes, fmt.Sprintf("source PVC %s/%s does not exist anymore", namespace, *s))
+		case err != nil:
+			report.Notes = append(report.Notes, fmt.Sprintf("cannot read source PVC %s/%s: %v", namespace, *s, err))
+		default:
+			info := pvcSummary(pvc)
+			report.SourcePVC = &info
+		}
+	}
+	return report, nil
+}
+
+// snapshotSummary returns the fields describe shows of a VolumeSnapshot.
+func snapshotSummary(s *snapshotv1.VolumeSnapshot) snapshotInfo {
+	info := snapshotInfo{
+		Namespace:          s.Namespace,
+		Name:               s.Name,
+		Created:            s.CreationTimestamp.Time,
+		SourcePVC:          snapshotSourcePVC(s),
+		ManagedBySnapshift: s.Labels[managedByLabel] == managedBy,
+	}
+	if s.Spec.VolumeSnapshotClassName != nil {
+		info.Class = *s.Spec.VolumeSnapshotClassName
+	}
+	if s.Spec.Source.VolumeSnapshotContentName != nil {
+		info.SourceContent = *s.Spec.Source.VolumeSnapshotContentName
+	}
+	if st := s.Status; st != nil {
+		if st.BoundVolumeSnapshotContentName != nil {
+			info.BoundContent = *st.BoundVolumeSnapshotContentName
+		}
+		info.ReadyToUse = st.ReadyToUse != nil && *st.ReadyToUse
+		if st.CreationTime != nil {
+			t := st.CreationTime.Time
+			info.CreationTime = &t
+		}
+		if st.RestoreSize != nil {
+			info.RestoreSize = st.RestoreSize.String()
+		}
+		info.Error = snapshotErrorMessage(st.Error)
+	}
+	return info
+}
+
+// contentSummary returns the fields describe shows of a VolumeSnapshotContent.
+func contentSummary(c *snapshotv1.VolumeSnapshotContent) contentInfo {
+	ref := c.Spec.VolumeSnapshotRef
+	info := contentInfo{
+		Name:           c.Name,
+		Driver:         c.Spec.Driver,
+		SnapshotHandle: contentHandle(c),
+		DeletionPolicy: string(c.Spec.DeletionPolicy),
+		SnapshotRef:    ref.Namespace + "/" + ref.Name,
+		SnapshotRefUID: string(ref.UID),
+	}
+	if c.Spec.Source.VolumeHandle != nil {
+		info.VolumeHandle = *c.Spec.Source.VolumeHandle
+	}
+	if c.Spec.VolumeSnapshotClassName != nil {
+		info.Class = *c.Spec.VolumeSnapshotClassName
+	}
+	if st := c.Status; st != nil {
+		info.ReadyToUse = st.ReadyToUse != nil && *st.ReadyToUse
+		if st.RestoreSize != nil {
+			info.RestoreSize = resource.NewQuantity(*st.RestoreSize, resource.BinarySI).String()
+		}
+		info.Error = snapshotErrorMessage(st.Error)
+	}
+	return info
+}
+
+// pvcSummary returns the fields describe shows of a source PVC.
+func pvcSummary(pvc *corev1.PersistentVolumeClaim) pvcInfo {
+	info := pvcInfo{
+		Namespace: pvc.Namespace,
+		Name:      pvc.Name,
+		Phase:     string(pvc.Status.Phase),
+		Volume:    pvc.Spec.VolumeName,
+	}
+	if pvc.Spec.StorageClassName != nil {
+		info.StorageClass = *pvc.Spec.StorageClassName
+	}
+	if size, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		info.Capacity = size.String()
+	}
+	return info
+}
+
+// snapshotErrorMessage returns the message of a snapshot or content error,
+// with the time it was reported.
+func snapshotErrorMessage(e *snapshotv1.VolumeSnapshotError) string {
+	if e == nil || e.Message == nil {
+		return ""
+	}
+	if e.Time != nil {
+		return fmt.Sprintf("%s (at %s)", *e.Message, e.Time.UTC().Format(time.RFC3339))
+	}
+	return *e.Message
+}
+
+// printSnapshotReport prints a report in the style of kubectl describe.
+func printSnapshotReport(out io.Writer, r *snapshotReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	field := func(indent, name, value string) {
+		if value == "" {
+			value = "<none>"
+		}
+		fmt.Fprintf(w, "%s%s:\t%s\n", indent, name, value)
+	}
+
+	s := r.Snapshot
+	field("", "Name", s.Name)
+	field("", "Namespace", s.Namespace)
+	if r.Cluster != "" {
+		field("", "Cluster", r.Cluster)
+	}
+	field("", "Created", fmt.Sprintf("%s (%s ago)", s.Created.UTC().Format(time.RFC3339), time.Since(s.Created).Round(time.Second)))
+	field("", "Managed By Snapshift", fmt.Sprintf("%t", s.ManagedBySnapshift))
+	field("", "Class", s.Class)
+	field("", "Source PVC", s.SourcePVC)
+	field("", "Source Content", s.SourceContent)
+	field("", "Bound Content", s.BoundContent)
+	field("", "Ready To Use", fmt.Sprintf("%t", s.ReadyToUse))
+	if s.CreationTime != nil {
+		field("", "Creation Time", s.CreationTime.UTC().Format(time.RFC3339))
+	} else {
+		field("", "Creation Time", "")
+	}
+	field("", "Restore Size", s.RestoreSize)
+	field("", "Error", s.Error)
+
+	if c := r.Content; c != nil {
+		fmt.Fprintf(w, "VolumeSnapshotContent:\n")
+		driver := c.Driver
+		if !c.DriverRegistered {
+			driver += " (no CSIDriver registered in this cluster)"
+		}
+		field("  ", "Name", c.Name)
+		field("  ", "Driver", driver)
+		field("  ", "Snapshot Handle", c.SnapshotHandle)
+		field("  ", "Volume Handle", c.VolumeHandle)
+		field("  ", "Deletion Policy", c.DeletionPolicy)
+		field("  ", "Class", c.Class)
+		ref := c.SnapshotRef
+		if c.SnapshotRefUID != "" {
+			ref += " (UID " + c.SnapshotRefUID + ")"
+		}
+		field("  ", "Snapshot Ref", ref)
+		field("  ", "Ready To Use", fmt.Sprintf("%t", c.ReadyToUse))
+		field("  ", "Restore Size", c.RestoreSize)
+		field("  ", "Error", c.Error)
+	}
+
+	if p := r.SourcePVC; p != nil {
+		fmt.Fprintf(w, "Source PersistentVolumeClaim:\n")
+		field("  ", "Name", p.Namespace+"/"+p.Name)
+		field("  ", "Phase", p.Phase)
+		field("  ", "Storage Class", p.StorageClass)
+		field("  ", "Capacity", p.Capacity)
+		field("  ", "Volume", p.Volume)
+	}
+	w.Flush()
+
+	if len(r.Notes) > 0 {
+		fmt.Fprintf(out, "Notes:\n")
+		for _, n := range r.Notes {
+			fmt.Fprintf(out, "  ⚠ %s\n", n)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDescribeSnapshotChain(t *testing.T) {
+	ctx := context.Background()
+	pvcName, contentName, handle := "data", "snapcontent-1", "handle-1"
+	ready := true
+	restoreSize := int64(1 << 30)
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "apps",
+			Name:              "data-snap",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			Labels:            map[string]string{managedByLabel: managedBy},
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: &pvcName}},
+		Status: &snapshotv1.VolumeSnapshotStatus{
+			BoundVolumeSnapshotContentName: &contentName,
+			ReadyToUse:                     &ready,
+			RestoreSize:                    resource.NewQuantity(restoreSize, resource.BinarySI),
+		},
+	}
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{Name: contentName},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			VolumeSnapshotRef: corev1.ObjectReference{Namespace: "apps", Name: "data-snap", UID: "uid-1"},
+			Driver:            "csi.example.com",
+			DeletionPolicy:    snapshotv1.VolumeSnapshotContentRetain,
+		},
+		Status: &snapshotv1.VolumeSnapshotContentStatus{SnapshotHandle: &handle, ReadyToUse: &ready, RestoreSize: &restoreSize},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "data"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-1"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+
+	t.Run("full chain", func(t *testing.T) {
+		report, err := describeSnapshotChain(ctx, k8sfake.NewSimpleClientset(pvc), snapfake.NewSimpleClientset(snapshot, content), "apps", "data-snap")
+		if err != nil {
+			t.Fatalf("describeSnapshotChain: %v", err)
+		}
+		if report.Content == nil || report.Content.SnapshotHandle != handle || report.Content.RestoreSize != "1Gi" {
+			t.Errorf("content = %+v, want handle %s and restore size 1Gi", report.Content, handle)
+		}
+		if report.Content.DriverRegistered {
+			t.Errorf("driver reported as registered without a CSIDriver")
+		}
+		if report.SourcePVC == nil || report.SourcePVC.Volume != "pv-1" {
+			t.Errorf("source PVC = %+v, want bound to pv-1", report.SourcePVC)
+		}
+		if len(report.Notes) != 0 {
+			t.Errorf("notes = %v, want none", report.Notes)
+		}
+
+		var out bytes.Buffer
+		printSnapshotReport(&out, report)
+		for _, want := range []string{
+			`\nReady To Use: +true\n`,
+			`\nRestore Size: +1Gi\n`,
+			`\n  Snapshot Handle: +handle-1\n`,
+			`\n  Deletion Policy: +Retain\n`,
+			`\n  Snapshot Ref: +apps/data-snap \(UID uid-1\)\n`,
+			`csi\.example\.com \(no CSIDriver registered in this cluster\)`,
+			`Source PersistentVolumeClaim:\n  Name: +apps/data\n  Phase: +Bound\n`,
+		} {
+			if !regexp.MustCompile(want).MatchString(out.String()) {
+				t.Errorf("report does not match %q:\n%s", want, out.String())
+			}
+		}
+	})
+
+	t.Run("broken chain", func(t *testing.T) {
+		message := "failed to take snapshot"
+		failed := snapshot.DeepCopy()
+		failed.Status = &snapshotv1.VolumeSnapshotStatus{Error: &snapshotv1.VolumeSnapshotError{Message: &message}}
+		report, err := describeSnapshotChain(ctx, k8sfake.NewSimpleClientset(), snapfake.NewSimpleClientset(failed), "apps", "data-snap")
+		if err != nil {
+			t.Fatalf("describeSnapshotChain: %v", err)
+		}
+		if report.Snapshot.Error != message {
+			t.Errorf("error = %q, want %q", report.Snapshot.Error, message)
+		}
+		want := []string{"the snapshot is not bound to a VolumeSnapshotContent yet", "source PVC apps/data does not exist anymore"}
+		if strings.Join(report.Notes, "\n") != strings.Join(want, "\n") {
+			t.Errorf("notes = %q, want %q", report.Notes, want)
+		}
+	})
+
+	t.Run("missing snapshot", func(t *testing.T) {
+		if _, err := describeSnapshotChain(ctx, k8sfake.NewSimpleClientset(), snapfake.NewSimpleClientset(), "apps", "data-snap"); err == nil {
+			t.Error("describeSnapshotChain succeeded for a missing snapshot")
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// defaultKubeconfigSecretKey is the Secret key read when
+// --dest-kubeconfig-secret does not name one.
+const defaultKubeconfigSecretKey = "kubeconfig"
+
+// destination is a cluster the origin snapshot is replicated to.
+type destination struct {
+	name string
+	// prefix is prepended to progress output to tell concurrent destinations
+	// apart; it is empty when there is a single destination.
+	prefix string
+	k8s    kubernetes.Interface
+	snap   snapshotclient.Interface
+	// sameAsOrigin is set when the destination is the origin cluster itself.
+	sameAsOrigin bool
+	// kubectlFlags select the cluster in --print-equivalent-commands output.
+	kubectlFlags string
+	// context is the kubeconfig context of the cluster, empty for the
+	// current context.
+	context string
+
+	// Per-destination overrides from a fleet file
+	namespace      string
+	snapshotClass  string
+	storageClasses map[string]string
+}
+
+// migration returns the migration as seen by this destination, with its
+// namespace override applied, which the PVCs follow unless
+// --dest-pvc-namespace is set.
+func (d *destination) migration(m *migration) *migration {
+	if d.namespace == "" {
+		return m
+	}
+	dm := *m
+	if dm.destPVCNamespace == dm.destNamespace {
+		dm.destPVCNamespace = d.namespace
+	}
+	dm.destNamespace = d.namespace
+	return &dm
+}
+
+// mapStorageClass returns the source PVC with its storage class replaced by the
+// PVC template's, or else renamed by the destination's storage class mapping,
+// or else replaced by --dest-storage-class, if any.
+func (d *destination) mapStorageClass(pvc *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	if pvcTemplate != nil && pvcTemplate.Spec.StorageClassName != nil {
+		pvc = pvc.DeepCopy()
+		pvc.Spec.StorageClassName = pvcTemplate.Spec.StorageClassName
+		return pvc
+	}
+
+	mapped := destStorageClass
+	if pvc.Spec.StorageClassName != nil {
+		if class, ok := d.storageClasses[*pvc.Spec.StorageClassName]; ok {
+			mapped = class
+		}
+	}
+	if mapped == "" {
+		return pvc
+	}
+	pvc = pvc.DeepCopy()
+	pvc.Spec.StorageClassName = &mapped
+	return pvc
+}
+
+// destinationTarget is the kubeconfig and context of a destination cluster.
+type destinationTarget struct {
+	name       string
+	kubeconfig string
+	context    string
+	overrides  clientOverrides
+}
+
+// destinationTargets pairs the destination kubeconfigs and contexts by
+// position; a single kubeconfig or context applies to all destinations.
+func destinationTargets(kubeconfigs, contexts []string) ([]destinationTarget, error) {
+	count := len(contexts)
+	if len(kubeconfigs) > count {
+		count = len(kubeconfigs)
+	}
+	if count == 0 {
+		count = 1
+	}
+	if len(kubeconfigs) > 1 && len(contexts) > 1 && len(kubeconfigs) != len(contexts) {
+		return nil, fmt.Errorf("got %d --dest-kubeconfig and %d --dest-context values, they must match when both are repeated", len(kubeconfigs), len(contexts))
+	}
+
+	targets := make([]destinationTarget, 0, count)
+	seen := make(map[string]bool)
+	for i := 0; i < count; i++ {
+		t := destinationTarget{
+			kubeconfig: pick(kubeconfigs, i),
+			context:    pick(contexts, i),
+			overrides:  destOverrides(),
+		}
+
+		t.name = t.context
+		if t.name == "" {
+			t.name = t.kubeconfig
+		}
+		if t.name == "" {
+			t.name = "destination"
+		}
+		if seen[t.kubeconfig+"/"+t.context] {
+			return nil, fmt.Errorf("destination %s is specified more than once", t.name)
+		}
+		seen[t.kubeconfig+"/"+t.context] = true
+
+		targets = append(targets, t)
+	}
+
+	return targets, nil
+}
+
+// createDestinations connects to every destination cluster.
+func createDestinations(kubeconfigs, contexts []string) ([]*destination, error) {
+	targets, err := destinationTargets(kubeconfigs, contexts)
+	if err != nil {
+		return nil, err
+	}
+
+	dests := make([]*destination, 0, len(targets))
+	for _, t := range targets {
+		d := &destination{name: t.name, context: t.context, kubectlFlags: kubectlFlags(t.kubeconfig, t.context, t.overrides)}
+		if len(targets) > 1 {
+			d.prefix = fmt.Sprintf("[%s] ", t.name)
+		}
+
+		fmt.Fprintf(stepOut, "%sConnecting to destination cluster...\n", d.prefix)
+		k8sClient, snapClient, err := createClients(t.kubeconfig, t.context, t.overrides)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create destination cluster clients for %s: %w", t.name, err)
+		}
+		d.k8s = k8sClient
+		d.snap = snapClient
+
+		dests = append(dests, d)
+	}
+
+	return dests, nil
+}
+
+// destinationFromSecret connects to a destination cluster whose kubeconfig is
+// stored in a Secret of the origin cluster, referenced as namespace/name[/key].
+// Without a key, the "kubeconfig" key or the Secret's only key is used.
+func destinationFromSecret(ctx context.Context, originClient kubernetes.Interface, ref string) (*destination, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid --dest-kubeconfig-secret %q: expected namespace/name[/key]", ref)
+	}
+	namespace, name := parts[0], parts[1]
+
+	fmt.Fprintf(stepOut, "Reading destination kubeconfig from Secret %s/%s...\n", namespace, name)
+	secret, err := originClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination kubeconfig Secret %s/%s: %w", namespace, name, err)
+	}
+
+	key := defaultKubeconfigSecretKey
+	if len(parts) == 3 {
+		key = parts[2]
+	} else if _, ok := secret.Data[key]; !ok && len(secret.Data) == 1 {
+		for k := range secret.Data {
+			key = k
+		}
+	}
+
+	data, ok := secret.Data[key]
+	if !ok {
+		keys := make([]string, 0, len(secret.Data))
+		for k := range secret.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("secret %s/%s has no key %q (available keys: %s)", namespace, name, key, strings.Join(keys, ", "))
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s/%s key %q does not contain a valid kubeconfig: %w", namespace, name, key, err)
+	}
+
+	destOverrides().apply(config)
+	fmt.Fprintf(stepOut, "Connecting to destination cluster...\n")
+	k8sClient, snapClient, err := clientsForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination cluster clients: %w", err)
+	}
+
+	return &destination{
+		name:         fmt.Sprintf("secret %s/%s", namespace, name),
+		k8s:          k8sClient,
+		snap:         snapClient,
+		kubectlFlags: fmt.Sprintf("--kubeconfig <kubeconfig from Secret %s/%s>", namespace, name),
+	}, nil
+}
+
+// pick returns values[i], the only value if there is just one, or "".
+func pick(values []string, i int) string {
+	switch {
+	case len(values) == 1:
+		return values[0]
+	case i < len(values):
+		return values[i]
+	default:
+		return ""
+	}
+}
+
+// sameCluster reports whether two clients talk to the same cluster, by
+// comparing the UID of their kube-system namespace. Clusters that cannot be
+// compared are assumed to differ.
+func sameCluster(ctx context.Context, a, b kubernetes.Interface) bool {
+	nsA, err := a.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	nsB, err := b.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	return nsA.UID == nsB.UID
+}
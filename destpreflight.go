@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// destPreflight holds the outcome of the checks of one destination that only
+// need the origin driver, not the snapshot handle or size.
+type destPreflight struct {
+	originDriver          string
+	driver                string // driver of the destination content, after --dest-driver
+	volumeAttributesClass *string
+	contentClass          string
+	destClass             string
+	err                   error
+}
+
+// preflightDest resolves and checks what a destination needs to import a
+// snapshot of originDriver: the VolumeAttributesClass, the driver, the
+// StorageClass, the deletion Secret and the snapshot class. It only reads from
+// the destination.
+func preflightDest(ctx context.Context, d *destination, sourcePVC *corev1.PersistentVolumeClaim, originCSIDriver *storagev1.CSIDriver, originDriver string) (*destPreflight, error) {
+	pf := &destPreflight{originDriver: originDriver}
+	var err error
+
+	// Step 4.3: Resolve the destination VolumeAttributesClass, if any
+	if createPVC {
+		pf.volumeAttributesClass, err = resolveVolumeAttributesClass(ctx, d, sourcePVC)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 4.4: Apply the destination driver override, if any, and compare the
+	// driver configuration
+	if pf.driver, err = destContentDriver(ctx, d, originDriver); err != nil {
+		return nil, err
+	}
+	if err := checkCSIDriver(ctx, d, originCSIDriver, pf.driver); err != nil {
+		return nil, err
+	}
+	if createPVC && destVolumeName == "" {
+		if err := checkDestStorageClass(ctx, d, d.mapStorageClass(sourcePVC), pf.driver); err != nil {
+			return nil, err
+		}
+		if readOnly {
+			if err := checkReadOnlyDriver(d, pf.driver); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := checkDeletionSecret(ctx, d); err != nil {
+		return nil, err
+	}
+
+	// Step 4.6: Pick the destination snapshot class
+	if pf.contentClass, pf.destClass, err = destSnapshotClasses(ctx, d, pf.driver); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// destPreflights runs the preflight of every destination in the background,
+// while the origin snapshot is taken.
+type destPreflights struct {
+	originDriver    string
+	originCSIDriver *storagev1.CSIDriver
+	results         map[*destination]*destPreflight
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+}
+
+// startDestPreflights starts the preflight of every destination for a snapshot
+// of originDriver. Once every destination has failed there is nothing left to
+// replicate to, so cancelOrigin stops the origin steps with the failure as
+// cause.
+func startDestPreflights(ctx context.Context, c *clusterClients, sourcePVC *corev1.PersistentVolumeClaim, originDriver string, cancelOrigin context.CancelCauseFunc) *destPreflights {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &destPreflights{
+		originDriver:    originDriver,
+		originCSIDriver: getOriginCSIDriver(ctx, c.originK8s, originDriver),
+		results:         make(map[*destination]*destPreflight, len(c.dests)),
+		cancel:          cancel,
+	}
+
+	var failed atomic.Int32
+	for _, d := range c.dests {
+		p.results[d] = &destPreflight{originDriver: originDriver}
+		p.wg.Add(1)
+		go func(d *destination, result *destPreflight) {
+			defer p.wg.Done()
+			pf, err := preflightDest(ctx, d, sourcePVC, p.originCSIDriver, originDriver)
+			if err == nil {
+				*result = *pf
+				return
+			}
+			result.err = err
+			if ctx.Err() != nil {
+				// Stopped along with the migration
+				return
+			}
+			if int(failed.Add(1)) == len(c.dests) {
+				cancelOrigin(p.failure(c))
+			}
+		}(d, p.results[d])
+	}
+	return p
+}
+
+// failure returns the error of a single destination, or lists the errors of
+// every destination.
+func (p *destPreflights) failure(c *clusterClients) error {
+	if len(c.dests) == 1 {
+		return p.results[c.dests[0]].err
+	}
+	errs := make([]string, 0, len(c.dests))
+	for _, d := range c.dests {
+		errs = append(errs, fmt.Sprintf("%s: %v", d.name, p.results[d].err))
+	}
+	return fmt.Errorf("all %d destinations failed their preflight checks: %s", len(c.dests), strings.Join(errs, "; "))
+}
+
+// wait waits for every preflight and returns their results by destination.
+func (p *destPreflights) wait() map[*destination]*destPreflight {
+	p.wg.Wait()
+	return p.results
+}
+
+// stop cancels the preflights still running and waits for them to return.
+func (p *destPreflights) stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// pvcCSIDriver returns the CSI driver of the volume bound to pvc, or "" when
+// it is unbound, not a CSI volume, or cannot be read.
+func pvcCSIDriver(ctx context.Context, client kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) string {
+	if pvc.Spec.VolumeName == "" {
+		return ""
+	}
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil || pv.Spec.CSI == nil {
+		return ""
+	}
+	return pv.Spec.CSI.Driver
+}
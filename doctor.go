@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultSnapshotClassAnnotation marks the default VolumeSnapshotClass.
+const defaultSnapshotClassAnnotation = "snapshot.storage.kubernetes.io/is-default-class"
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the origin and destination clusters are ready for migration",
+	Long: `doctor runs read-only checks against the origin and destination clusters:
+connectivity, VolumeSnapshot CRDs, the snapshot controller, available
+VolumeSnapshotClasses, the RBAC permissions snapshift needs, and whether both
+sides share a CSI snapshot driver. Nothing is created or modified.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// doctorReport prints check results and counts failures.
+type doctorReport struct {
+	failed int
+}
+
+func (r *doctorReport) pass(format string, args ...interface{}) {
+	fmt.Printf("  ✓ "+format+"\n", args...)
+}
+
+func (r *doctorReport) warn(format string, args ...interface{}) {
+	fmt.Printf("  ⚠ "+format+"\n", args...)
+}
+
+func (r *doctorReport) fail(format string, args ...interface{}) {
+	r.failed++
+	fmt.Printf("  ✗ "+format+"\n", args...)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	report := &doctorReport{}
+
+	fmt.Printf("Origin cluster (%s):\n", clusterName(originKubeconfig, originContext))
+	originDrivers := diagnoseCluster(ctx, report, originKubeconfig, originContext, originOverrides(), originPermissions(pvcNamespace))
+
+	targets, err := destinationTargets(destKubeconfigs, destContexts)
+	if err != nil {
+		return err
+	}
+
+	namespace := destNamespace
+	if namespace == "" {
+		namespace = pvcNamespace
+	}
+
+	destDrivers := make([]map[string]bool, len(targets))
+	for i, t := range targets {
+		fmt.Printf("\nDestination cluster (%s):\n", clusterName(t.kubeconfig, t.context))
+		destDrivers[i] = diagnoseCluster(ctx, report, t.kubeconfig, t.context, t.overrides, destPermissions(namespace))
+	}
+
+	fmt.Printf("\nCompatibility:\n")
+	for i, t := range targets {
+		if originDrivers == nil || destDrivers[i] == nil {
+			report.fail("Cannot compare snapshot drivers with %s, see errors above", t.name)
+			continue
+		}
+
+		var shared []string
+		for driver := range originDrivers {
+			if destDrivers[i][driver] {
+				shared = append(shared, driver)
+			}
+		}
+		sort.Strings(shared)
+
+		if len(shared) == 0 {
+			report.fail("Origin and %s share no snapshot driver", t.name)
+		} else {
+			report.pass("Origin and %s share snapshot drivers: %s", t.name, strings.Join(shared, ", "))
+		}
+	}
+
+	if report.failed > 0 {
+		return fmt.Errorf("%d checks failed", report.failed)
+	}
+
+	fmt.Printf("\n✓ All checks passed\n")
+	return nil
+}
+
+// diagnoseCluster runs the read-only checks against one cluster and returns the
+// drivers of its VolumeSnapshotClasses, or nil if they could not be listed.
+func diagnoseCluster(ctx context.Context, r *doctorReport, kubeconfig, contextName string, o clientOverrides, perms []permission) map[string]bool {
+	k8sClient, snapClient, err := createClients(kubeconfig, contextName, o)
+	if err != nil {
+		r.fail("Load kubeconfig: %v", err)
+		return nil
+	}
+
+	version, err := k8sClient.Discovery().ServerVersion()
+	if err != nil {
+		r.fail("Connect to API server: %v", err)
+		return nil
+	}
+	r.pass("Connected (Kubernetes %s)", version.GitVersion)
+
+	if err := checkSnapshotCRDs(k8sClient); err != nil {
+		r.fail("VolumeSnapshot CRDs: %v", err)
+	} else {
+		r.pass("VolumeSnapshot CRDs installed")
+	}
+
+	controller, err := findSnapshotController(ctx, k8sClient)
+	switch {
+	case err != nil:
+		r.warn("Snapshot controller: could not check (%v)", err)
+	case controller == "":
+		r.warn("Snapshot controller: no running snapshot-controller Deployment found")
+	default:
+		r.pass("Snapshot controller running (%s)", controller)
+	}
+
+	var drivers map[string]bool
+	classes, err := snapClient.SnapshotV1().VolumeSnapshotClasses().List(ctx, metav1.ListOptions{})
+	switch {
+	case err != nil:
+		r.fail("VolumeSnapshotClasses: %v", err)
+	case len(classes.Items) == 0:
+		r.fail("No VolumeSnapshotClasses found")
+		drivers = map[string]bool{}
+	default:
+		r.pass("%d VolumeSnapshotClasses found", len(classes.Items))
+		drivers = make(map[string]bool)
+		for _, class := range classes.Items {
+			drivers[class.Driver] = true
+			suffix := ""
+			if class.Annotations[defaultSnapshotClassAnnotation] == "true" {
+				suffix = ", default"
+			}
+			fmt.Printf("      %s (driver %s, deletionPolicy %s%s)\n", class.Name, class.Driver, class.DeletionPolicy, suffix)
+		}
+	}
+
+	denied, err := checkPermissions(ctx, k8sClient, perms)
+	switch {
+	case err != nil:
+		r.fail("RBAC: %v", err)
+	case len(denied) > 0:
+		for _, p := range denied {
+			r.fail("RBAC: cannot %s", p)
+		}
+	default:
+		r.pass("RBAC: all %d required permissions granted", len(perms))
+	}
+
+	return drivers
+}
+
+// clusterName describes the cluster selected by a kubeconfig and context.
+func clusterName(kubeconfig, contextName string) string {
+	switch {
+	case contextName != "":
+		return contextName
+	case kubeconfig != "":
+		return kubeconfig
+	default:
+		return "current context"
+	}
+}
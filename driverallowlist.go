@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkDriverAllowed returns an error if --driver-allowlist is set and does
+// not list driver.
+func checkDriverAllowed(driver string) error {
+	if len(driverAllowlist) == 0 {
+		return nil
+	}
+	for _, allowed := range driverAllowlist {
+		if driver == allowed {
+			return nil
+		}
+	}
+	if driver == "" {
+		driver = "<none>"
+	}
+	return fmt.Errorf("CSI driver %s is not in --driver-allowlist (%s)", driver, strings.Join(driverAllowlist, ", "))
+}
+
+// checkPVCDriverAllowed checks the CSI driver of the PersistentVolume bound to
+// pvc against --driver-allowlist. An unbound PVC is left to the check of the
+// snapshot content.
+func checkPVCDriverAllowed(ctx context.Context, client kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) error {
+	if len(driverAllowlist) == 0 || pvc.Spec.VolumeName == "" {
+		return nil
+	}
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PersistentVolume of PVC %s/%s: %w", pvc.Namespace, pvc.Name, err)
+	}
+	driver := ""
+	if pv.Spec.CSI != nil {
+		driver = pv.Spec.CSI.Driver
+	}
+	if err := checkDriverAllowed(driver); err != nil {
+		return fmt.Errorf("PVC %s/%s: %w", pvc.Namespace, pvc.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dryRunHandle stands in for the snapshot handle, which is only known once the
+// origin snapshot has been taken.
+const dryRunHandle = "<origin snapshot handle>"
+
+// dryRunTransformedHandle stands in for the destination handle with
+// --handle-transform, which is not run on the placeholder.
+const dryRunTransformedHandle = "<origin snapshot handle rewritten by --handle-transform>"
+
+// unset is shown for fields that are not set.
+const unset = "<unset>"
+
+// fieldDiff compares a field of a source object with the destination object
+// snapshift would create from it.
+type fieldDiff struct {
+	field  string
+	source string
+	dest   string
+}
+
+func (d fieldDiff) status() string {
+	switch {
+	case d.source == d.dest:
+		return "copied"
+	case d.dest == unset:
+		return "not copied"
+	default:
+		return "overridden"
+	}
+}
+
+// dryRunMigration renders the objects a migration would create, using the
+// same builders as the real run, and prints how their fields compare with the
+// source. Nothing is created.
+func dryRunMigration(ctx context.Context, c *clusterClients, m *migration) error {
+	sourcePVC, err := c.originK8s.CoreV1().PersistentVolumeClaims(m.pvcNamespace).Get(ctx, m.pvcName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get source PVC: %w", err)
+	}
+
+	// The origin content is not known yet, so predict it from the source PV
+	// driver and the snapshot class the origin snapshot will use
+	driver := ""
+	if sourcePVC.Spec.VolumeName != "" {
+		pv, err := c.originK8s.CoreV1().PersistentVolumes().Get(ctx, sourcePVC.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get source PersistentVolume: %w", err)
+		}
+		if pv.Spec.CSI != nil {
+			driver = pv.Spec.CSI.Driver
+		}
+	}
+
+	if err := checkPVCDriverAllowed(ctx, c.originK8s, sourcePVC); err != nil {
+		return err
+	}
+	if err := checkSnapshotSize(fmt.Sprintf("PVC %s/%s request", sourcePVC.Namespace, sourcePVC.Name), sourcePVC.Spec.Resources.Requests[corev1.ResourceStorage]); err != nil {
+		return err
+	}
+
+	originClassName, err := originClassForDriver(ctx, c.originSnap, sourcePVC, driver)
+	if err != nil {
+		return err
+	}
+	originClass, err := resolveSnapshotClass(ctx, c.originSnap, originClassName, driver)
+	if err != nil {
+		return err
+	}
+	originContent := &snapshotv1.VolumeSnapshotContent{
+		Spec: snapshotv1.VolumeSnapshotContentSpec{Driver: driver},
+	}
+	if originClass != nil {
+		originContent.Spec.VolumeSnapshotClassName = &originClass.Name
+		originContent.Spec.DeletionPolicy = originClass.DeletionPolicy
+	}
+
+	originSnapshot := buildSnapshot(m.pvcNamespace, m.snapshotName, m.pvcName, originClassName)
+	fmt.Fprintf(stepOut, "Dry run, nothing will be created:\n")
+	if preSnapshotJob != nil {
+		job := buildHookJob(preSnapshotJob, hookPreSnapshot, m)
+		fmt.Fprintf(stepOut, "  Origin pre-snapshot Job: %s/%s*\n", job.Namespace, job.GenerateName)
+	}
+	fmt.Fprintf(stepOut, "  Origin VolumeSnapshot: %s/%s\n", originSnapshot.Namespace, originSnapshot.Name)
+	if postSnapshotJob != nil {
+		job := buildHookJob(postSnapshotJob, hookPostSnapshot, m)
+		fmt.Fprintf(stepOut, "  Origin post-snapshot Job: %s/%s*\n", job.Namespace, job.GenerateName)
+	}
+
+	printApply(originKubectlFlags(), originSnapshot)
+
+	originDriver := getOriginCSIDriver(ctx, c.originK8s, driver)
+
+	originSnapshotClass := unset
+	if originClass != nil {
+		originSnapshotClass = originClass.Name
+	}
+
+	for _, d := range c.dests {
+		if err := dryRunDest(ctx, d, d.migration(m), sourcePVC, originContent, originDriver, originSnapshotClass); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dryRunDest renders and compares the objects a migration would create in one
+// destination.
+func dryRunDest(ctx context.Context, d *destination, m *migration, sourcePVC *corev1.PersistentVolumeClaim, originContent *snapshotv1.VolumeSnapshotContent, originDriver *storagev1.CSIDriver, originSnapshotClass string) error {
+	var (
+		destVolume *corev1.PersistentVolume
+		err        error
+	)
+	if destVolumeName != "" {
+		destVolume, err = d.k8s.CoreV1().PersistentVolumes().Get(ctx, destVolumeName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get destination PersistentVolume %s: %w", destVolumeName, err)
+		}
+	}
+
+	var volumeAttributesClass *string
+	if createPVC {
+		volumeAttributesClass, err = resolveVolumeAttributesClass(ctx, d, sourcePVC)
+		if err != nil {
+			return err
+		}
+	}
+
+	content, err := destContentSource(ctx, d, originContent)
+	if err != nil {
+		return err
+	}
+	if err := checkCSIDriver(ctx, d, originDriver, content.Spec.Driver); err != nil {
+		return err
+	}
+	if createPVC && destVolume == nil {
+		if err := checkDestStorageClass(ctx, d, d.mapStorageClass(sourcePVC), content.Spec.Driver); err != nil {
+			return err
+		}
+	}
+	if err := checkDeletionSecret(ctx, d); err != nil {
+		return err
+	}
+	if createPVC {
+		if err := checkStorageQuota(ctx, d, m.destPVCNamespace, d.mapStorageClass(sourcePVC), templateStorageSize(sourcePVC.Spec.Resources.Requests[corev1.ResourceStorage])); err != nil {
+			return err
+		}
+	}
+
+	contentClass, destClass, err := destSnapshotClasses(ctx, d, content.Spec.Driver)
+	if err != nil {
+		return err
+	}
+
+	destHandle := dryRunHandle
+	if transformHandle != nil {
+		destHandle = dryRunTransformedHandle
+	}
+	contentName := destContentName(m)
+	destContent := buildVolumeSnapshotContent(contentName, m.destNamespace, m.destSnapshotName, destHandle, contentClass, content)
+	destSnapshot := buildPreBoundSnapshot(m.destNamespace, m.destSnapshotName, contentName, destClass, m.pvcNamespace+"/"+m.pvcName)
+
+	fmt.Fprintf(stepOut, "  %sDestination VolumeSnapshotContent: %s\n", d.prefix, destContent.Name)
+	fmt.Fprintf(stepOut, "  %sDestination VolumeSnapshot: %s/%s\n", d.prefix, destSnapshot.Namespace, destSnapshot.Name)
+	var copyContent *snapshotv1.VolumeSnapshotContent
+	var copySnapshot *snapshotv1.VolumeSnapshot
+	if m.copiesSnapshot() {
+		copyContentName := fmt.Sprintf("snapcontent-%s-%s", m.destPVCNamespace, m.destSnapshotName)
+		copyContent = buildVolumeSnapshotContent(copyContentName, m.destPVCNamespace, m.destSnapshotName, destHandle, contentClass, content)
+		copySnapshot = buildPreBoundSnapshot(m.destPVCNamespace, m.destSnapshotName, copyContentName, destClass, m.pvcNamespace+"/"+m.pvcName)
+		fmt.Fprintf(stepOut, "  %sDestination VolumeSnapshotContent copy: %s\n", d.prefix, copyContent.Name)
+		fmt.Fprintf(stepOut, "  %sDestination VolumeSnapshot copy: %s/%s\n", d.prefix, copySnapshot.Namespace, copySnapshot.Name)
+	}
+	if createPVC {
+		for _, name := range m.destPVCNames() {
+			fmt.Fprintf(stepOut, "  %sDestination PVC: %s/%s\n", d.prefix, m.destPVCNamespace, name)
+		}
+	}
+	printApply(d.kubectlFlags, destContent)
+	printApply(d.kubectlFlags, destSnapshot)
+	if copyContent != nil {
+		printApply(d.kubectlFlags, copyContent)
+		printApply(d.kubectlFlags, copySnapshot)
+	}
+
+	printFieldDiffs(d.prefix+"VolumeSnapshot", []fieldDiff{
+		{"volumeSnapshotClassName", originSnapshotClass, derefOr(destSnapshot.Spec.VolumeSnapshotClassName, unset)},
+	})
+
+	printFieldDiffs(d.prefix+"VolumeSnapshotContent", []fieldDiff{
+		{"driver", originContent.Spec.Driver, destContent.Spec.Driver},
+		{"volumeSnapshotClassName", derefOr(originContent.Spec.VolumeSnapshotClassName, unset), derefOr(destContent.Spec.VolumeSnapshotClassName, unset)},
+		{"deletionPolicy", orUnset(string(originContent.Spec.DeletionPolicy)), orUnset(string(destContent.Spec.DeletionPolicy))},
+		{"snapshotHandle", dryRunHandle, derefOr(destContent.Spec.Source.SnapshotHandle, unset)},
+	})
+
+	if createPVC {
+		storageSize := sourcePVC.Spec.Resources.Requests[corev1.ResourceStorage]
+		// Replicas only differ in name, so the first one stands for all in
+		// the comparison
+		var destPVC *corev1.PersistentVolumeClaim
+		for _, name := range m.destPVCNames() {
+			pvc := buildPVCFromSnapshot(m.destPVCNamespace, name, m.destSnapshotName, templateStorageSize(storageSize), d.mapStorageClass(sourcePVC), destVolume, volumeAttributesClass, provenanceAnnotations(m, dryRunHandle))
+			printApply(d.kubectlFlags, pvc)
+			if destPVC == nil {
+				destPVC = pvc
+			}
+		}
+		destSize := destPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+		printFieldDiffs(d.prefix+"PersistentVolumeClaim", []fieldDiff{
+			{"storageClassName", derefOr(sourcePVC.Spec.StorageClassName, unset), derefOr(destPVC.Spec.StorageClassName, unset)},
+			{"accessModes", accessModesString(sourcePVC.Spec.AccessModes), accessModesString(destPVC.Spec.AccessModes)},
+			{"resources.requests.storage", storageSize.String(), destSize.String()},
+			{"volumeMode", volumeModeString(sourcePVC.Spec.VolumeMode), volumeModeString(destPVC.Spec.VolumeMode)},
+			{"volumeName", orUnset(sourcePVC.Spec.VolumeName), orUnset(destPVC.Spec.VolumeName)},
+			{"selector", selectorString(sourcePVC.Spec.Selector), selectorString(destPVC.Spec.Selector)},
+			{"volumeAttributesClassName", derefOr(sourcePVC.Spec.VolumeAttributesClassName, unset), derefOr(destPVC.Spec.VolumeAttributesClassName, unset)},
+		})
+		fmt.Fprintf(stepOut, "  The storage request may still grow to the snapshot restore size, which is only known once the snapshot is taken.\n")
+		if len(destPVC.Annotations) > 0 {
+			fmt.Fprintf(stepOut, "  Provenance annotations:\n")
+			for _, key := range []string{annotationSourcePVC, annotationSourceSnapshotHandle, annotationMigratedAt} {
+				fmt.Fprintf(stepOut, "    %s: %s\n", key, destPVC.Annotations[key])
+			}
+		}
+	}
+
+	return nil
+}
+
+// printFieldDiffs prints a source/destination comparison table.
+func printFieldDiffs(kind string, diffs []fieldDiff) {
+	fmt.Fprintf(stepOut, "\n%s:\n", kind)
+	w := tabwriter.NewWriter(stepOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  FIELD\tSOURCE\tDESTINATION\tSTATUS\t\n")
+	for _, d := range diffs {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", d.field, d.source, d.dest, d.status())
+	}
+	w.Flush()
+}
+
+func derefOr(s *string, fallback string) string {
+	if s == nil {
+		return fallback
+	}
+	return *s
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return unset
+	}
+	return s
+}
+
+func accessModesString(modes []corev1.PersistentVolumeAccessMode) string {
+	if len(modes) == 0 {
+		return unset
+	}
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = string(mode)
+	}
+	return strings.Join(names, ",")
+}
+
+func volumeModeString(mode *corev1.PersistentVolumeMode) string {
+	if mode == nil {
+		return unset
+	}
+	return string(*mode)
+}
+
+func selectorString(selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return unset
+	}
+	return metav1.FormatLabelSelector(selector)
+}
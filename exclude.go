@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// excludedPVC is a PVC given with --exclude-pvc, resolved against --namespace.
+type excludedPVC struct {
+	ref       string
+	namespace string
+	name      string
+}
+
+// parseExclusions validates --exclude-pvc and --exclude-selector, and
+// resolves the PVCs given with --exclude-pvc.
+func parseExclusions() ([]excludedPVC, error) {
+	if excludeSelector != "" {
+		if _, err := labels.Parse(excludeSelector); err != nil {
+			return nil, fmt.Errorf("invalid --exclude-selector %q: %w", excludeSelector, err)
+		}
+	}
+	excluded := make([]excludedPVC, 0, len(excludePVCs))
+	for _, ref := range excludePVCs {
+		namespace, name, err := parsePVCRef("exclude-pvc", ref, pvcNamespace)
+		if err != nil {
+			return nil, err
+		}
+		excluded = append(excluded, excludedPVC{ref: ref, namespace: namespace, name: name})
+	}
+	return excluded, nil
+}
+
+// excludeMigrations removes the PVCs given with --exclude-pvc and those
+// matching --exclude-selector from the migrations of a bulk run, and lists
+// the PVCs excluded and included. An exclusion that matched none of the
+// migrations is reported, as it is likely a typo.
+func excludeMigrations(ctx context.Context, client kubernetes.Interface, migrations []*migration) ([]*migration, error) {
+	if len(excludePVCs) == 0 && excludeSelector == "" {
+		return migrations, nil
+	}
+	excludedPVCs, err := parseExclusions()
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]bool, len(excludedPVCs))
+	for _, e := range excludedPVCs {
+		named[e.namespace+"/"+e.name] = true
+	}
+
+	labeled := make(map[string]bool)
+	if excludeSelector != "" {
+		namespace := pvcNamespace
+		if allNamespaces {
+			namespace = metav1.NamespaceAll
+		}
+		pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: excludeSelector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PVCs matching --exclude-selector: %w", err)
+		}
+		for _, pvc := range pvcs.Items {
+			labeled[pvc.Namespace+"/"+pvc.Name] = true
+		}
+	}
+
+	var included, excluded []*migration
+	matched := make(map[string]bool)
+	selectorMatched := false
+	for _, m := range migrations {
+		key := m.pvcNamespace + "/" + m.pvcName
+		if named[key] {
+			matched[key] = true
+		}
+		if labeled[key] {
+			selectorMatched = true
+		}
+		if named[key] || labeled[key] {
+			excluded = append(excluded, m)
+		} else {
+			included = append(included, m)
+		}
+	}
+
+	for _, e := range excludedPVCs {
+		if !matched[e.namespace+"/"+e.name] {
+			fmt.Fprintf(stepOut, "⚠ Warning: --exclude-pvc %s matched none of the PVCs to migrate\n", e.ref)
+		}
+	}
+	if excludeSelector != "" && !selectorMatched {
+		fmt.Fprintf(stepOut, "⚠ Warning: --exclude-selector %q matched none of the PVCs to migrate\n", excludeSelector)
+	}
+	printPVCList("Excluding", excluded)
+	printPVCList("Including", included)
+	return included, nil
+}
+
+// printPVCList prints the PVCs of migrations under a heading.
+func printPVCList(heading string, migrations []*migration) {
+	fmt.Fprintf(stepOut, "%s %d PVCs:\n", heading, len(migrations))
+	for _, m := range migrations {
+		fmt.Fprintf(stepOut, "  %s/%s\n", m.pvcNamespace, m.pvcName)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExcludeMigrations(t *testing.T) {
+	setFlag(t, &pvcNamespace, "apps")
+	setFlag(t, &excludePVCs, []string{"logs", "apps/typo"})
+	setFlag(t, &excludeSelector, "tier=cache")
+	pvc := func(name string, labels map[string]string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: name, Labels: labels}}
+	}
+	client := k8sfake.NewSimpleClientset(
+		pvc("data", nil),
+		pvc("logs", nil),
+		pvc("cache", map[string]string{"tier": "cache"}),
+	)
+	migrations := []*migration{newMigration("apps", "cache"), newMigration("apps", "data"), newMigration("apps", "logs")}
+
+	var included []*migration
+	var err error
+	out := captureStdout(t, func() {
+		included, err = excludeMigrations(context.Background(), client, migrations)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(included) != 1 || included[0].pvcName != "data" {
+		t.Errorf("included = %v, want only apps/data", included)
+	}
+	for _, want := range []string{
+		"⚠ Warning: --exclude-pvc apps/typo matched none of the PVCs to migrate",
+		"Excluding 2 PVCs:\n  apps/cache\n  apps/logs\n",
+		"Including 1 PVCs:\n  apps/data\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "--exclude-selector") {
+		t.Errorf("output warns about an --exclude-selector that matched:\n%s", out)
+	}
+
+	// A selector matching none of the migrations is reported too
+	setFlag(t, &excludePVCs, nil)
+	setFlag(t, &excludeSelector, "tier=none")
+	out = captureStdout(t, func() {
+		included, err = excludeMigrations(context.Background(), client, migrations)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(included) != len(migrations) {
+		t.Errorf("included %d PVCs, want all %d", len(included), len(migrations))
+	}
+	if !strings.Contains(out, `--exclude-selector "tier=none" matched none of the PVCs to migrate`) {
+		t.Errorf("output misses the unmatched selector warning:\n%s", out)
+	}
+}
+
+func TestParseExclusions(t *testing.T) {
+	setFlag(t, &pvcNamespace, "apps")
+	setFlag(t, &excludePVCs, []string{"data", "db/wal"})
+	excluded, err := parseExclusions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []excludedPVC{{ref: "data", namespace: "apps", name: "data"}, {ref: "db/wal", namespace: "db", name: "wal"}}
+	if len(excluded) != len(want) || excluded[0] != want[0] || excluded[1] != want[1] {
+		t.Errorf("parseExclusions() = %+v, want %+v", excluded, want)
+	}
+
+	setFlag(t, &excludePVCs, []string{"apps/Data"})
+	if _, err := parseExclusions(); err == nil || !strings.Contains(err.Error(), "--exclude-pvc") {
+		t.Errorf("parseExclusions() = %v, want an invalid --exclude-pvc error", err)
+	}
+
+	setFlag(t, &excludePVCs, nil)
+	setFlag(t, &excludeSelector, "tier in (")
+	if _, err := parseExclusions(); err == nil || !strings.Contains(err.Error(), "invalid --exclude-selector") {
+		t.Errorf("parseExclusions() = %v, want an invalid --exclude-selector error", err)
+	}
+}
@@ -0,0 +1,342 @@
+//go:build fakebackend
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// The fake backend simulates a CSI driver and the snapshot controller on top
+// of the fake clientsets, so a migration can run end to end without a cluster.
+// It is only built with -tags fakebackend.
+
+// fakeDriver is the CSI driver name used by fake volumes and snapshots.
+const fakeDriver = "fake.csi.snapshift.io"
+
+// fakeStorageClass is the storage class of fake volumes.
+const fakeStorageClass = "fake"
+
+var (
+	snapshotsResource = snapshotv1.SchemeGroupVersion.WithResource("volumesnapshots")
+	contentsResource  = snapshotv1.SchemeGroupVersion.WithResource("volumesnapshotcontents")
+)
+
+// fakeCluster is a simulated cluster with a working snapshot controller.
+type fakeCluster struct {
+	k8s  *k8sfake.Clientset
+	snap *snapfake.Clientset
+}
+
+// newFakeCluster creates a simulated cluster holding the given objects.
+// Snapshot objects go to the snapshot clientset, everything else to the
+// Kubernetes clientset.
+func newFakeCluster(objects ...runtime.Object) *fakeCluster {
+	var k8sObjects, snapObjects []runtime.Object
+	for _, obj := range objects {
+		switch obj.(type) {
+		case *snapshotv1.VolumeSnapshot, *snapshotv1.VolumeSnapshotContent, *snapshotv1.VolumeSnapshotClass:
+			snapObjects = append(snapObjects, obj)
+		default:
+			k8sObjects = append(k8sObjects, obj)
+		}
+	}
+
+	// The fake driver is registered like a real CSI driver, with the storage
+	// class of fake volumes
+	k8sObjects = append(k8sObjects,
+		&storagev1.CSIDriver{ObjectMeta: metav1.ObjectMeta{Name: fakeDriver}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: fakeStorageClass}, Provisioner: fakeDriver},
+	)
+
+	c := &fakeCluster{
+		k8s:  k8sfake.NewSimpleClientset(k8sObjects...),
+		snap: snapfake.NewSimpleClientset(snapObjects...),
+	}
+
+	// Discovery reports the snapshot CRDs as installed
+	c.k8s.Resources = append(c.k8s.Resources, &metav1.APIResourceList{
+		GroupVersion: snapshotGroupVersion,
+		APIResources: []metav1.APIResource{
+			{Name: "volumesnapshots", Namespaced: true, Kind: "VolumeSnapshot"},
+			{Name: "volumesnapshotcontents", Kind: "VolumeSnapshotContent"},
+			{Name: "volumesnapshotclasses", Kind: "VolumeSnapshotClass"},
+		},
+	})
+
+	// Snapshots are reconciled when they are read, so they become ready on
+	// the first poll after being created
+	c.snap.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		if err := c.reconcileSnapshot(get.GetNamespace(), get.GetName()); err != nil {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+
+	// Deleting a snapshot deletes its content when the content has the
+	// Delete policy, like the snapshot controller
+	c.snap.PrependReactor("delete", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		del := action.(k8stesting.DeleteAction)
+		c.deleteSnapshotContent(del.GetNamespace(), del.GetName())
+		return false, nil, nil
+	})
+
+	// Server-side apply creates the objects that do not exist yet
+	c.snap.PrependReactor("patch", "*", applyCreates(c.snap.Tracker()))
+	c.k8s.PrependReactor("patch", "*", applyCreates(c.k8s.Tracker()))
+
+	// Claims bind as soon as they are created
+	c.k8s.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pvc := action.(k8stesting.CreateAction).GetObject().(*corev1.PersistentVolumeClaim)
+		pvc.Status.Phase = corev1.ClaimBound
+		return false, nil, nil
+	})
+
+	return c
+}
+
+// applyCreates returns a reactor that creates the object of a server-side
+// apply patch when it does not exist, which the fake clientsets would report
+// as not found. Applying to an existing object is left to them.
+func applyCreates(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		if _, err := tracker.Get(patch.GetResource(), patch.GetNamespace(), patch.GetName()); !apierrors.IsNotFound(err) {
+			return false, nil, nil
+		}
+
+		var obj runtime.Object
+		switch patch.GetResource().Resource {
+		case "volumesnapshots":
+			obj = &snapshotv1.VolumeSnapshot{}
+		case "volumesnapshotcontents":
+			obj = &snapshotv1.VolumeSnapshotContent{}
+		case "persistentvolumeclaims":
+			obj = &corev1.PersistentVolumeClaim{}
+		default:
+			return false, nil, nil
+		}
+		if err := json.Unmarshal(patch.GetPatch(), obj); err != nil {
+			return true, nil, err
+		}
+		if pvc, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+			pvc.Status.Phase = corev1.ClaimBound
+		}
+		if err := tracker.Create(patch.GetResource(), obj, patch.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	}
+}
+
+// reconcileSnapshot does what the snapshot controller and the CSI driver
+// would: it takes a dynamic snapshot of a PVC, or binds a pre-provisioned
+// snapshot to its content, and marks both ready.
+func (c *fakeCluster) reconcileSnapshot(namespace, name string) error {
+	tracker := c.snap.Tracker()
+	obj, err := tracker.Get(snapshotsResource, namespace, name)
+	if err != nil {
+		// Let the get itself report the error
+		return nil
+	}
+	snapshot := obj.(*snapshotv1.VolumeSnapshot).DeepCopy()
+	if snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse {
+		return nil
+	}
+
+	var content *snapshotv1.VolumeSnapshotContent
+	switch {
+	case snapshot.Spec.Source.PersistentVolumeClaimName != nil:
+		content, err = c.takeSnapshot(snapshot)
+	case snapshot.Spec.Source.VolumeSnapshotContentName != nil:
+		content, err = c.bindContent(snapshot)
+	}
+	if err != nil || content == nil {
+		return err
+	}
+
+	ready := true
+	now := metav1.Now()
+	snapshot.Status = &snapshotv1.VolumeSnapshotStatus{
+		BoundVolumeSnapshotContentName: &content.Name,
+		CreationTime:                   &now,
+		ReadyToUse:                     &ready,
+		RestoreSize:                    restoreSize(content),
+	}
+	return tracker.Update(snapshotsResource, snapshot, namespace)
+}
+
+// takeSnapshot creates the ready VolumeSnapshotContent of a dynamic snapshot,
+// with a synthetic snapshot handle.
+func (c *fakeCluster) takeSnapshot(snapshot *snapshotv1.VolumeSnapshot) (*snapshotv1.VolumeSnapshotContent, error) {
+	pvcName := *snapshot.Spec.Source.PersistentVolumeClaimName
+	pvc, err := c.k8s.Tracker().Get(corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"), snapshot.Namespace, pvcName)
+	if err != nil {
+		// The source PVC is missing, so the snapshot stays pending
+		return nil, nil
+	}
+	size := pvc.(*corev1.PersistentVolumeClaim).Spec.Resources.Requests[corev1.ResourceStorage]
+	restore := size.Value()
+
+	ready := true
+	handle := fmt.Sprintf("fake-handle-%s-%s", snapshot.Namespace, snapshot.Name)
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("snapcontent-%s-%s", snapshot.Namespace, snapshot.Name),
+		},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			Driver:                  fakeDriver,
+			DeletionPolicy:          snapshotv1.VolumeSnapshotContentDelete,
+			VolumeSnapshotClassName: snapshot.Spec.VolumeSnapshotClassName,
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				VolumeHandle: stringPtr(fmt.Sprintf("fake-volume-%s-%s", snapshot.Namespace, pvcName)),
+			},
+			VolumeSnapshotRef: corev1.ObjectReference{
+				Kind:      "VolumeSnapshot",
+				Namespace: snapshot.Namespace,
+				Name:      snapshot.Name,
+			},
+		},
+		Status: &snapshotv1.VolumeSnapshotContentStatus{
+			SnapshotHandle: &handle,
+			ReadyToUse:     &ready,
+			RestoreSize:    &restore,
+		},
+	}
+
+	if err := c.snap.Tracker().Create(contentsResource, content, ""); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// bindContent marks the pre-provisioned content of a snapshot ready. The
+// snapshot stays pending until the content exists.
+func (c *fakeCluster) bindContent(snapshot *snapshotv1.VolumeSnapshot) (*snapshotv1.VolumeSnapshotContent, error) {
+	obj, err := c.snap.Tracker().Get(contentsResource, "", *snapshot.Spec.Source.VolumeSnapshotContentName)
+	if err != nil {
+		return nil, nil
+	}
+	content := obj.(*snapshotv1.VolumeSnapshotContent).DeepCopy()
+	if uid := content.Spec.VolumeSnapshotRef.UID; uid != "" && uid != snapshot.UID {
+		// The content is pinned to another snapshot of that name
+		return nil, nil
+	}
+	if content.Spec.Source.SnapshotHandle == nil {
+		return nil, fmt.Errorf("pre-provisioned VolumeSnapshotContent %s has no snapshot handle", content.Name)
+	}
+
+	ready := true
+	content.Status = &snapshotv1.VolumeSnapshotContentStatus{
+		SnapshotHandle: content.Spec.Source.SnapshotHandle,
+		ReadyToUse:     &ready,
+	}
+	if err := c.snap.Tracker().Update(contentsResource, content, ""); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// deleteSnapshotContent deletes the content bound to a snapshot about to be
+// deleted if its deletion policy is Delete.
+func (c *fakeCluster) deleteSnapshotContent(namespace, name string) {
+	obj, err := c.snap.Tracker().Get(snapshotsResource, namespace, name)
+	if err != nil {
+		return
+	}
+	snapshot := obj.(*snapshotv1.VolumeSnapshot)
+	if snapshot.Status == nil || snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return
+	}
+	contentName := *snapshot.Status.BoundVolumeSnapshotContentName
+	obj, err = c.snap.Tracker().Get(contentsResource, "", contentName)
+	if err != nil {
+		return
+	}
+	if obj.(*snapshotv1.VolumeSnapshotContent).Spec.DeletionPolicy == snapshotv1.VolumeSnapshotContentDelete {
+		_ = c.snap.Tracker().Delete(contentsResource, "", contentName)
+	}
+}
+
+func restoreSize(content *snapshotv1.VolumeSnapshotContent) *resource.Quantity {
+	if content.Status == nil || content.Status.RestoreSize == nil {
+		return nil
+	}
+	return resource.NewQuantity(*content.Status.RestoreSize, resource.BinarySI)
+}
+
+// newFakeClusterClients wires simulated clusters together for migratePVC.
+func newFakeClusterClients(origin *fakeCluster, dests ...*fakeCluster) *clusterClients {
+	c := &clusterClients{
+		originK8s:  origin.k8s,
+		originSnap: origin.snap,
+	}
+	for i, d := range dests {
+		name := fmt.Sprintf("fake-%d", i)
+		dest := &destination{name: name, k8s: d.k8s, snap: d.snap}
+		if len(dests) > 1 {
+			dest.prefix = "[" + name + "] "
+		}
+		c.dests = append(c.dests, dest)
+	}
+	return c
+}
+
+// fakeVolume returns a bound PVC and its CSI PersistentVolume on the fake
+// driver, for use as the source of a migration.
+func fakeVolume(namespace, name, size string) []runtime.Object {
+	quantity := resource.MustParse(size)
+	storageClass := fakeStorageClass
+	pvName := fmt.Sprintf("pv-%s-%s", namespace, name)
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			VolumeName:       pvName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: quantity},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: pvName},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:         corev1.ResourceList{corev1.ResourceStorage: quantity},
+			AccessModes:      pvc.Spec.AccessModes,
+			StorageClassName: storageClass,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:       fakeDriver,
+					VolumeHandle: fmt.Sprintf("fake-volume-%s-%s", namespace, name),
+				},
+			},
+			ClaimRef: &corev1.ObjectReference{Namespace: namespace, Name: name},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+
+	return []runtime.Object{pvc, pv}
+}
@@ -0,0 +1,1142 @@
+//go:build fakebackend
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMigratePVCCreatesDestPVC(t *testing.T) {
+	setFlag(t, &createPVC, true)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	res, err := migratePVC(ctx, c, m)
+	if err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	wantHandle := "fake-handle-apps-data-snap"
+	if res.SnapshotHandle != wantHandle {
+		t.Errorf("result handle = %q, want %q", res.SnapshotHandle, wantHandle)
+	}
+
+	content, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination content: %v", err)
+	}
+	if got := contentHandle(content); got != wantHandle {
+		t.Errorf("destination content handle = %q, want %q", got, wantHandle)
+	}
+	if content.Spec.DeletionPolicy != snapshotv1.VolumeSnapshotContentRetain {
+		t.Errorf("destination content policy = %s, want Retain", content.Spec.DeletionPolicy)
+	}
+
+	snapshot, err := dest.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination snapshot: %v", err)
+	}
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse {
+		t.Errorf("destination snapshot is not ready: %+v", snapshot.Status)
+	}
+
+	pvc, err := dest.k8s.CoreV1().PersistentVolumeClaims("apps").Get(ctx, "data", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination PVC: %v", err)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != "data-snap" {
+		t.Errorf("destination PVC data source = %+v, want snapshot data-snap", pvc.Spec.DataSource)
+	}
+}
+
+func TestFakeSnapshotDeleteRemovesDeleteContent(t *testing.T) {
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	if _, err := createSnapshot(ctx, origin.snap, "apps", "snap", "data", ""); err != nil {
+		t.Fatalf("createSnapshot: %v", err)
+	}
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "snap", metav1.GetOptions{}); err != nil {
+		t.Fatalf("get snapshot: %v", err)
+	}
+	if err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Delete(ctx, "snap", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete snapshot: %v", err)
+	}
+	_, err := origin.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-snap", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("content of the deleted snapshot: got err %v, want not found", err)
+	}
+}
+
+func TestFakeReconcileErrorFailsGet(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeCluster(&snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-handle"},
+	})
+	if _, err := createPreBoundSnapshot(ctx, c.snap, "apps", "snap", "no-handle", "", "apps/data"); err != nil {
+		t.Fatalf("createPreBoundSnapshot: %v", err)
+	}
+	if _, err := c.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "snap", metav1.GetOptions{}); err == nil {
+		t.Errorf("get of a snapshot whose content has no handle succeeded, want the reconcile error")
+	}
+}
+
+func TestDryRunRendersEachReplica(t *testing.T) {
+	setFlag(t, &createPVC, true)
+	setFlag(t, &replicas, 2)
+	setFlag(t, &printCommands, true)
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	c := newFakeClusterClients(origin, newFakeCluster())
+
+	m := newMigration("apps", "data")
+	out := captureStdout(t, func() {
+		if err := dryRunMigration(context.Background(), c, m); err != nil {
+			t.Fatalf("dryRunMigration: %v", err)
+		}
+	})
+
+	for _, name := range []string{"data-0", "data-1"} {
+		if !strings.Contains(out, "Destination PVC: apps/"+name) {
+			t.Errorf("dry run does not list PVC %s:\n%s", name, out)
+		}
+		if !strings.Contains(out, "name: "+name+"\n") {
+			t.Errorf("dry run does not render a manifest for PVC %s:\n%s", name, out)
+		}
+	}
+	if strings.Contains(out, "name: data\n") {
+		t.Errorf("dry run renders the unsuffixed PVC name:\n%s", out)
+	}
+	if !regexp.MustCompile(`FIELD +SOURCE +DESTINATION +STATUS`).MatchString(out) {
+		t.Errorf("comparison header has no STATUS column:\n%s", out)
+	}
+}
+
+func TestUpdateExistingSnapshotReplacesOutdatedPair(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	migrate := func(snapshotName string) error {
+		m := newMigration("apps", "data")
+		m.snapshotName = snapshotName
+		m.destSnapshotName = "data-dr"
+		_, err := migratePVC(ctx, c, m)
+		return err
+	}
+	if err := migrate("data-snap-1"); err != nil {
+		t.Fatalf("first migration: %v", err)
+	}
+	if err := migrate("data-snap-2"); err == nil {
+		t.Fatal("second migration without --update-existing-snapshot succeeded, want a conflict")
+	}
+
+	setFlag(t, &updateExistingSnapshot, true)
+	if err := migrate("data-snap-2"); err != nil {
+		t.Fatalf("second migration: %v", err)
+	}
+
+	wantHandle := "fake-handle-apps-data-snap-2"
+	content, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-dr", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination content: %v", err)
+	}
+	if got := contentHandle(content); got != wantHandle {
+		t.Errorf("destination content handle = %q, want %q", got, wantHandle)
+	}
+	snapshot, err := dest.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-dr", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination snapshot: %v", err)
+	}
+	if got := boundContentName(snapshot); got != content.Name {
+		t.Errorf("destination snapshot bound to %q, want %q", got, content.Name)
+	}
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse {
+		t.Errorf("destination snapshot is not ready: %+v", snapshot.Status)
+	}
+
+	// The origin snapshot of the first run still owns its storage snapshot
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap-1", metav1.GetOptions{}); err != nil {
+		t.Errorf("origin content of the first run: %v", err)
+	}
+}
+
+func TestMigratePVCTransformsHandle(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	transform, err := parseHandleTransform("^fake-handle-=dr-handle-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &transformHandle, transform)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	res, err := migratePVC(ctx, c, m)
+	if err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	if res.SnapshotHandle != "fake-handle-apps-data-snap" || res.DestSnapshotHandle != "dr-handle-apps-data-snap" {
+		t.Errorf("result handles = %q, %q", res.SnapshotHandle, res.DestSnapshotHandle)
+	}
+	content, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination content: %v", err)
+	}
+	if got := contentHandle(content); got != "dr-handle-apps-data-snap" {
+		t.Errorf("destination content handle = %q, want the rewritten handle", got)
+	}
+}
+
+func TestWaitTimeoutAction(t *testing.T) {
+	tests := []struct {
+		action   string
+		wantLeft bool
+	}{
+		{action: timeoutActionFail, wantLeft: false},
+		{action: timeoutActionLeave, wantLeft: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			setFlag(t, &pollInterval, 10*time.Millisecond)
+			setFlag(t, &waitTimeoutAction, tt.action)
+			origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+			dest := newFakeCluster()
+			// The destination snapshot controller never gets to the snapshot
+			dest.snap.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				get := action.(k8stesting.GetAction)
+				obj, err := dest.snap.Tracker().Get(snapshotsResource, get.GetNamespace(), get.GetName())
+				return true, obj, err
+			})
+			c := newFakeClusterClients(origin, dest)
+
+			m := newMigration("apps", "data")
+			m.snapshotName = "data-snap"
+			m.destSnapshotName = "data-snap"
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			_, err := migratePVC(ctx, c, m)
+			if !errors.Is(err, errWaitTimeout) {
+				t.Fatalf("err = %v, want a readiness timeout", err)
+			}
+			if got := leftPending(err); got != tt.wantLeft {
+				t.Errorf("leftPending = %v, want %v", got, tt.wantLeft)
+			}
+
+			bg := context.Background()
+			exists := func(err error) bool {
+				if err != nil && !apierrors.IsNotFound(err) {
+					t.Fatal(err)
+				}
+				return err == nil
+			}
+			_, err = dest.snap.SnapshotV1().VolumeSnapshots("apps").Get(bg, "data-snap", metav1.GetOptions{})
+			if got := exists(err); got != tt.wantLeft {
+				t.Errorf("destination snapshot exists = %v, want %v", got, tt.wantLeft)
+			}
+			_, err = dest.snap.SnapshotV1().VolumeSnapshotContents().Get(bg, "snapcontent-apps-data-snap", metav1.GetOptions{})
+			if got := exists(err); got != tt.wantLeft {
+				t.Errorf("destination content exists = %v, want %v", got, tt.wantLeft)
+			}
+			_, err = origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(bg, "data-snap", metav1.GetOptions{})
+			if got := exists(err); got != tt.wantLeft {
+				t.Errorf("origin snapshot exists = %v, want %v", got, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestMaxSnapshotSizeRefusesBeforeSnapshot(t *testing.T) {
+	limit, err := parseMaxSnapshotSize("1Gi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &maxSnapshotSize, limit)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(append(fakeVolume("apps", "small", "1Gi"), fakeVolume("apps", "big", "2Gi")...)...)
+	c := newFakeClusterClients(origin, newFakeCluster())
+
+	m := newMigration("apps", "big")
+	m.snapshotName = "big-snap"
+	if _, err := migratePVC(ctx, c, m); err == nil || !strings.Contains(err.Error(), "exceeds --max-snapshot-size") {
+		t.Fatalf("migratePVC = %v, want the size guard to refuse", err)
+	}
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "big-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("origin snapshot of the refused PVC: %v, want not found", err)
+	}
+
+	m = newMigration("apps", "small")
+	m.snapshotName = "small-snap"
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Errorf("PVC at the limit: %v", err)
+	}
+}
+
+func TestClassMapSelectsClasses(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &classMapping, &classMap{
+		Origin:      map[string]string{fakeDriver: "fast"},
+		Destination: map[string]string{fakeDriver: "dr-fast"},
+	})
+	ctx := context.Background()
+	class := func(name, driver string) *snapshotv1.VolumeSnapshotClass {
+		return &snapshotv1.VolumeSnapshotClass{
+			ObjectMeta:     metav1.ObjectMeta{Name: name},
+			Driver:         driver,
+			DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete,
+		}
+	}
+	origin := newFakeCluster(append(fakeVolume("apps", "data", "1Gi"), class("fast", fakeDriver))...)
+	dest := newFakeCluster(class("dr-fast", fakeDriver))
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	snapshot, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("origin snapshot: %v", err)
+	}
+	if got := snapshot.Spec.VolumeSnapshotClassName; got == nil || *got != "fast" {
+		t.Errorf("origin snapshot class = %v, want fast", got)
+	}
+	content, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination content: %v", err)
+	}
+	if got := content.Spec.VolumeSnapshotClassName; got == nil || *got != "dr-fast" {
+		t.Errorf("destination content class = %v, want dr-fast", got)
+	}
+
+	// A class of another driver is refused before the origin snapshot
+	origin = newFakeCluster(append(fakeVolume("apps", "data", "1Gi"), class("fast", "other.csi.example.com"))...)
+	c = newFakeClusterClients(origin, newFakeCluster())
+	if _, err := migratePVC(ctx, c, m); err == nil || !strings.Contains(err.Error(), "uses driver other.csi.example.com") {
+		t.Fatalf("migratePVC = %v, want the driver mismatch", err)
+	}
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("origin snapshot with a mismatched class: %v, want not found", err)
+	}
+
+	// A missing destination class fails that destination
+	origin = newFakeCluster(append(fakeVolume("apps", "data", "1Gi"), class("fast", fakeDriver))...)
+	c = newFakeClusterClients(origin, newFakeCluster())
+	if _, err := migratePVC(ctx, c, m); err == nil || !strings.Contains(err.Error(), "VolumeSnapshotClass dr-fast mapped for driver "+fakeDriver+" does not exist") {
+		t.Fatalf("migratePVC = %v, want the missing destination class", err)
+	}
+}
+
+func TestMigratePVCReadOnly(t *testing.T) {
+	setFlag(t, &createPVC, true)
+	setFlag(t, &readOnly, true)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	pvc, err := dest.k8s.CoreV1().PersistentVolumeClaims("apps").Get(ctx, "data", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination PVC: %v", err)
+	}
+	if len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != corev1.ReadOnlyMany {
+		t.Errorf("destination PVC access modes = %v, want ReadOnlyMany", pvc.Spec.AccessModes)
+	}
+	if pvc.Annotations[annotationReadOnly] != "true" {
+		t.Errorf("destination PVC annotations = %v, want %s", pvc.Annotations, annotationReadOnly)
+	}
+}
+
+func TestMigratePVCSameClusterOtherNamespace(t *testing.T) {
+	setFlag(t, &createPVC, true)
+	setFlag(t, &createNamespace, true)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	cluster := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	c := newFakeClusterClients(cluster, cluster)
+	c.dests[0].sameAsOrigin = true
+
+	// Clone the same snapshot into two namespaces of the origin cluster
+	for _, ns := range []string{"staging", "qa"} {
+		setFlag(t, &destNamespace, ns)
+		m := newMigration("apps", "data")
+		m.snapshotName = "data-snap"
+		m.destSnapshotName = "data-snap"
+		// The second clone replicates the snapshot taken by the first, like
+		// --source-snapshot
+		m.existingSnapshot = ns != "staging"
+		if _, err := migratePVC(ctx, c, m); err != nil {
+			t.Fatalf("migratePVC into %s: %v", ns, err)
+		}
+	}
+
+	for _, ns := range []string{"staging", "qa"} {
+		contentName := "snapcontent-" + ns + "-data-snap"
+		content, err := cluster.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, contentName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("content for %s: %v", ns, err)
+		}
+		if ref := content.Spec.VolumeSnapshotRef; ref.Namespace != ns || ref.Name != "data-snap" {
+			t.Errorf("content %s refers to %s/%s, want %s/data-snap", contentName, ref.Namespace, ref.Name, ns)
+		}
+		if contentHandle(content) != "fake-handle-apps-data-snap" {
+			t.Errorf("content %s handle = %s, want the origin snapshot's", contentName, contentHandle(content))
+		}
+		if content.Spec.DeletionPolicy != snapshotv1.VolumeSnapshotContentRetain {
+			t.Errorf("content %s deletion policy = %s, want Retain", contentName, content.Spec.DeletionPolicy)
+		}
+		snapshot, err := cluster.snap.SnapshotV1().VolumeSnapshots(ns).Get(ctx, "data-snap", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("snapshot in %s: %v", ns, err)
+		}
+		if src := snapshot.Spec.Source.VolumeSnapshotContentName; src == nil || *src != contentName {
+			t.Errorf("snapshot %s/data-snap is not pre-bound to %s", ns, contentName)
+		}
+		if _, err := cluster.k8s.CoreV1().PersistentVolumeClaims(ns).Get(ctx, "data", metav1.GetOptions{}); err != nil {
+			t.Errorf("PVC in %s: %v", ns, err)
+		}
+	}
+
+	// The origin snapshot keeps its own content
+	origin, err := cluster.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("origin content: %v", err)
+	}
+	if ref := origin.Spec.VolumeSnapshotRef; ref.Namespace != "apps" || ref.Name != "data-snap" {
+		t.Errorf("origin content refers to %s/%s", ref.Namespace, ref.Name)
+	}
+}
+
+func TestMigratePVCResumedReusesOriginSnapshot(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(append(fakeVolume("apps", "data", "1Gi"), fakeVolume("apps", "logs", "1Gi")...)...)
+	// A crashed run left the origin snapshot of apps/data
+	if _, err := createSnapshot(ctx, origin.snap, "apps", "data-snap", "data", ""); err != nil {
+		t.Fatal(err)
+	}
+	c := newFakeClusterClients(origin, newFakeCluster())
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	if _, err := migratePVC(ctx, c, m); err == nil || !apierrors.IsAlreadyExists(errors.Unwrap(err)) {
+		t.Fatalf("migratePVC without resuming = %v, want the snapshot to already exist", err)
+	}
+
+	m.resumed = true
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("resumed migratePVC: %v", err)
+	}
+
+	// A snapshot of another PVC under the same name is not taken over
+	m = newMigration("apps", "logs")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "logs-snap"
+	m.resumed = true
+	_, err := migratePVC(ctx, c, m)
+	if err == nil || !strings.Contains(err.Error(), "was not taken of PVC apps/logs") {
+		t.Fatalf("resumed migratePVC over another PVC's snapshot = %v", err)
+	}
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{}); err != nil {
+		t.Errorf("the other PVC's snapshot was deleted: %v", err)
+	}
+}
+
+func TestMigrateSourceSnapshotOnlyReadsOrigin(t *testing.T) {
+	setFlag(t, &createPVC, true)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &sourceSnapshot, "data-snap")
+	setFlag(t, &pvcNamespace, "apps")
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	if _, err := createSnapshot(ctx, origin.snap, "apps", "data-snap", "data", ""); err != nil {
+		t.Fatal(err)
+	}
+	// What --origin-read-only refuses
+	var writes []string
+	record := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		switch action.GetVerb() {
+		case "get", "list", "watch":
+		default:
+			writes = append(writes, action.GetVerb()+" "+action.GetResource().Resource)
+		}
+		return false, nil, nil
+	}
+	origin.k8s.PrependReactor("*", "*", record)
+	origin.snap.PrependReactor("*", "*", record)
+	c := newFakeClusterClients(origin, newFakeCluster())
+
+	m, err := sourceSnapshotMigration(ctx, c.originSnap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	if len(writes) > 0 {
+		t.Errorf("replicating an existing snapshot wrote to the origin: %v", writes)
+	}
+}
+
+func TestSnapshotClassDriverMismatch(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	class := func(name, driver string) *snapshotv1.VolumeSnapshotClass {
+		return &snapshotv1.VolumeSnapshotClass{
+			ObjectMeta:     metav1.ObjectMeta{Name: name},
+			Driver:         driver,
+			DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete,
+		}
+	}
+	m := newMigration("apps", "app-data")
+	m.snapshotName = "app-data-snap"
+	m.destSnapshotName = "app-data-snap"
+
+	setFlag(t, &snapshotClass, "foo")
+	origin := newFakeCluster(append(fakeVolume("apps", "app-data", "1Gi"), class("foo", "other.csi.example.com"))...)
+	c := newFakeClusterClients(origin, newFakeCluster())
+	_, err := migratePVC(ctx, c, m)
+	want := "snapshot class foo uses driver other.csi.example.com but PVC apps/app-data is provisioned by driver " + fakeDriver
+	if err == nil || err.Error() != want {
+		t.Fatalf("migratePVC = %v, want %q", err, want)
+	}
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "app-data-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("origin snapshot with a mismatched class: %v, want not found", err)
+	}
+
+	// The destination class is checked against the snapshot's driver
+	setFlag(t, &destSnapClass, "dr")
+	origin = newFakeCluster(append(fakeVolume("apps", "app-data", "1Gi"), class("foo", fakeDriver))...)
+	dest := newFakeCluster(class("dr", "other.csi.example.com"))
+	c = newFakeClusterClients(origin, dest)
+	_, err = migratePVC(ctx, c, m)
+	if err == nil || !strings.Contains(err.Error(), "snapshot class dr uses driver other.csi.example.com in destination cluster") {
+		t.Fatalf("migratePVC = %v, want the destination class mismatch", err)
+	}
+
+	dest = newFakeCluster(class("dr", fakeDriver))
+	c = newFakeClusterClients(origin, dest)
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC with matching classes: %v", err)
+	}
+}
+
+func TestPauseBeforeDest(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &pauseBeforeDest, true)
+
+	t.Run("continue", func(t *testing.T) {
+		presses := make(chan error)
+		paused := make(chan struct{})
+		setFlag(t, &enterPresses, func() <-chan error {
+			close(paused)
+			return presses
+		})
+		ctx := context.Background()
+		origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+		dest := newFakeCluster()
+		c := newFakeClusterClients(origin, dest)
+		m := newMigration("apps", "data")
+		m.snapshotName = "data-snap"
+		m.destSnapshotName = "data-snap"
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := migratePVC(ctx, c, m)
+			done <- err
+		}()
+		<-paused
+		if _, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Errorf("destination content while paused: %v, want not found", err)
+		}
+		presses <- nil
+		if err := <-done; err != nil {
+			t.Fatalf("migratePVC: %v", err)
+		}
+		if _, err := dest.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{}); err != nil {
+			t.Errorf("destination snapshot after continuing: %v", err)
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		setFlag(t, &enterPresses, func() <-chan error {
+			cancel()
+			return make(chan error)
+		})
+		origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+		dest := newFakeCluster()
+		c := newFakeClusterClients(origin, dest)
+		m := newMigration("apps", "data")
+		m.snapshotName = "data-snap"
+		m.destSnapshotName = "data-snap"
+
+		_, err := migratePVC(ctx, c, m)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("migratePVC = %v, want the pause aborted", err)
+		}
+		bg := context.Background()
+		if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(bg, "data-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Errorf("origin snapshot after aborting: %v, want cleaned up", err)
+		}
+		if _, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(bg, "snapcontent-apps-data-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Errorf("destination content after aborting: %v, want none", err)
+		}
+	})
+}
+
+func TestVerifyBoundHandleDetectsOtherData(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	// A controller recreated the content with another handle once it bound
+	dest.snap.PrependReactor("get", "volumesnapshotcontents", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := dest.snap.Tracker().Get(contentsResource, "", action.(k8stesting.GetAction).GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		content := obj.(*snapshotv1.VolumeSnapshotContent).DeepCopy()
+		if content.Status != nil && content.Status.SnapshotHandle != nil {
+			other := "fake-handle-other"
+			content.Status.SnapshotHandle = &other
+		}
+		return true, content, nil
+	})
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	_, err := migratePVC(ctx, c, m)
+	if err == nil {
+		t.Fatal("migratePVC succeeded with a destination content of another handle")
+	}
+	for _, want := range []string{"snapcontent-apps-data-snap", "fake-handle-other", "fake-handle-apps-data-snap"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not name %s", err, want)
+		}
+	}
+}
+
+func TestCleanupTimeoutGrace(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &timeoutGrace, 100*time.Millisecond)
+	setFlag(t, &contentDeletionWait, time.Minute)
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	// The destination snapshot never becomes ready, and its content is stuck
+	// deleting
+	dest.snap.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := dest.snap.Tracker().Get(snapshotsResource, get.GetNamespace(), get.GetName())
+		return true, obj, err
+	})
+	dest.snap.PrependReactor("delete", "volumesnapshotcontents", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var err error
+	out := captureStdout(t, func() { _, err = migratePVC(ctx, c, m) })
+	if err == nil {
+		t.Fatal("migratePVC succeeded despite the destination snapshot never becoming ready")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("migratePVC took %s, cleanup was not bounded by --timeout-grace", elapsed)
+	}
+
+	// Cleanup ran after the run's context was done
+	for _, want := range []string{"✓ Deleted destination snapshot", "✓ Deleted destination VolumeSnapshotContent", "did not finish within --timeout-grace (100ms)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output misses %q:\n%s", want, out)
+		}
+	}
+	if _, err := dest.snap.Tracker().Get(snapshotsResource, "apps", "data-snap"); !apierrors.IsNotFound(err) {
+		t.Errorf("destination snapshot after cleanup: %v, want not found", err)
+	}
+}
+
+func TestRetryStuckBinding(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &stuckBindingRetries, 2)
+	setFlag(t, &stuckBindingThreshold, 100*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	// The first content never gets bound, the recreated one does
+	var contentsCreated int
+	dest.snap.PrependReactor("create", "volumesnapshotcontents", func(k8stesting.Action) (bool, runtime.Object, error) {
+		contentsCreated++
+		return false, nil, nil
+	})
+	dest.snap.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if contentsCreated > 1 {
+			return false, nil, nil
+		}
+		get := action.(k8stesting.GetAction)
+		obj, err := dest.snap.Tracker().Get(snapshotsResource, get.GetNamespace(), get.GetName())
+		return true, obj, err
+	})
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	if contentsCreated != 2 {
+		t.Errorf("destination contents created = %d, want the stuck one and its replacement", contentsCreated)
+	}
+	contents, err := dest.snap.SnapshotV1().VolumeSnapshotContents().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents.Items) != 1 || contentHandle(&contents.Items[0]) != "fake-handle-apps-data-snap" {
+		t.Errorf("destination contents = %d, want only the bound replacement", len(contents.Items))
+	}
+}
+
+func TestManifestsOut(t *testing.T) {
+	dir := t.TempDir()
+	setFlag(t, &manifestsOut, dir)
+	setFlag(t, &createPVC, true)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	c := newFakeClusterClients(origin, newFakeCluster())
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"fake-0/volumesnapshotcontent/snapcontent-apps-data-snap.yaml": "snapshotHandle: fake-handle-apps-data-snap",
+		"fake-0/volumesnapshot/apps/data-snap.yaml":                    "volumeSnapshotContentName: snapcontent-apps-data-snap",
+		"fake-0/persistentvolumeclaim/apps/data.yaml":                  "name: data-snap",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Errorf("manifest %s: %v", path, err)
+			continue
+		}
+		if !strings.Contains(string(data), want) || strings.Contains(string(data), "resourceVersion") {
+			t.Errorf("manifest %s does not hold %q without server fields:\n%s", path, want, data)
+		}
+	}
+}
+
+func TestDestPreflightRunsWhileOriginSnapshotIsTaken(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &createPVC, true)
+	setFlag(t, &destSnapClass, "dr")
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster(&snapshotv1.VolumeSnapshotClass{
+		ObjectMeta:     metav1.ObjectMeta{Name: "dr"},
+		Driver:         fakeDriver,
+		DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete,
+	})
+
+	// Record the calls to both clusters in order
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(cluster string) k8stesting.ReactionFunc {
+		return func(action k8stesting.Action) (bool, runtime.Object, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, cluster+" "+action.GetVerb()+" "+action.GetResource().Resource)
+			return false, nil, nil
+		}
+	}
+	called := func(call string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range calls {
+			if c == call {
+				return true
+			}
+		}
+		return false
+	}
+	// The origin snapshot only becomes ready once the destination class was
+	// read, which never happens if the destination waits for the origin
+	origin.snap.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if called("dest get volumesnapshotclasses") {
+			return false, nil, nil
+		}
+		get := action.(k8stesting.GetAction)
+		obj, err := origin.snap.Tracker().Get(snapshotsResource, get.GetNamespace(), get.GetName())
+		return true, obj, err
+	})
+	origin.snap.PrependReactor("*", "*", record("origin"))
+	dest.snap.PrependReactor("*", "*", record("dest"))
+	dest.k8s.PrependReactor("*", "*", record("dest"))
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+
+	// Nothing is written to the destination before the handle is known
+	handleKnown := -1
+	for i, call := range calls {
+		if call == "origin get volumesnapshotcontents" && handleKnown < 0 {
+			handleKnown = i
+		}
+		verb := strings.Fields(call)[1]
+		if strings.HasPrefix(call, "dest ") && verb != "get" && verb != "list" && verb != "watch" && handleKnown < 0 {
+			t.Errorf("%q before the origin content was read, calls: %v", call, calls)
+		}
+	}
+	if handleKnown < 0 {
+		t.Fatalf("origin content never read, calls: %v", calls)
+	}
+}
+
+func TestDestPreflightFailureStopsOriginWait(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &destSnapClass, "missing")
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	// The origin snapshot never becomes ready
+	origin.snap.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := origin.snap.Tracker().Get(snapshotsResource, get.GetNamespace(), get.GetName())
+		return true, obj, err
+	})
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := migratePVC(ctx, c, m)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("migratePVC = %v, want the missing destination class", err)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the preflight failure rather than a cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("migratePVC took %s, the origin wait was not cancelled", elapsed)
+	}
+	if _, err := origin.snap.Tracker().Get(snapshotsResource, "apps", "data-snap"); !apierrors.IsNotFound(err) {
+		t.Errorf("origin snapshot after the preflight failure: %v, want not found", err)
+	}
+	for _, action := range dest.snap.Actions() {
+		if action.GetVerb() == "create" {
+			t.Errorf("destination %s %s after its preflight failed", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
+
+func TestPinBindingUID(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &pinBindingUID, true)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	// The API server gives every snapshot a new UID
+	uids := 0
+	dest.snap.PrependReactor("create", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		uids++
+		snapshot := action.(k8stesting.CreateAction).GetObject().(*snapshotv1.VolumeSnapshot)
+		snapshot.UID = types.UID(fmt.Sprintf("uid-%d", uids))
+		return false, nil, nil
+	})
+	c := newFakeClusterClients(origin, dest)
+
+	m := newMigration("apps", "data")
+	m.snapshotName = "data-snap"
+	m.destSnapshotName = "data-snap"
+	if _, err := migratePVC(ctx, c, m); err != nil {
+		t.Fatalf("migratePVC: %v", err)
+	}
+	snapshot, err := dest.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-apps-data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := content.Spec.VolumeSnapshotRef.UID; got != snapshot.UID {
+		t.Fatalf("content snapshot ref UID = %q, want the snapshot's %q", got, snapshot.UID)
+	}
+
+	// A later snapshot reusing the name does not bind to the pinned content
+	if err := dest.snap.Tracker().Delete(snapshotsResource, "apps", "data-snap"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createPreBoundSnapshot(ctx, dest.snap, "apps", "data-snap", content.Name, "", "apps/data"); err != nil {
+		t.Fatal(err)
+	}
+	reused, err := dest.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "data-snap", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.Status != nil && reused.Status.BoundVolumeSnapshotContentName != nil {
+		t.Errorf("snapshot reusing the name bound to the pinned content %s", *reused.Status.BoundVolumeSnapshotContentName)
+	}
+	d := c.dests[0]
+	if err := pinContentRef(ctx, d, "apps", "data-snap", content.Name); err == nil || !strings.Contains(err.Error(), "pinned to another snapshot") {
+		t.Errorf("pinContentRef = %v, want the content pinned to the earlier snapshot", err)
+	}
+}
+
+func TestSnapshotInProgress(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	pvcName := "data"
+	inProgress := func() *fakeCluster {
+		return newFakeCluster(append(fakeVolume("apps", "data", "1Gi"), &snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "apps",
+				Name:              "nightly",
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-90 * time.Second)),
+			},
+			Spec: snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: &pvcName}},
+		})...)
+	}
+	newM := func() *migration {
+		m := newMigration("apps", "data")
+		m.snapshotName = "data-snap"
+		m.destSnapshotName = "data-snap"
+		return m
+	}
+
+	t.Run("warns", func(t *testing.T) {
+		origin := inProgress()
+		c := newFakeClusterClients(origin, newFakeCluster())
+		var err error
+		out := captureStdout(t, func() { _, err = migratePVC(ctx, c, newM()) })
+		if err != nil {
+			t.Fatalf("migratePVC: %v", err)
+		}
+		if !regexp.MustCompile(`already has 1 snapshot\(s\) in progress:\n  nightly \(age 1m3\ds\)`).MatchString(out) {
+			t.Errorf("output misses the snapshot in progress and its age:\n%s", out)
+		}
+		if _, err := origin.snap.Tracker().Get(snapshotsResource, "apps", "data-snap"); err != nil {
+			t.Errorf("origin snapshot: %v, want one taken despite the warning", err)
+		}
+	})
+
+	t.Run("reuses", func(t *testing.T) {
+		setFlag(t, &reuseInProgress, true)
+		setFlag(t, &createPVC, true)
+		setFlag(t, &deleteSnapshots, true)
+		origin := inProgress()
+		dest := newFakeCluster()
+		c := newFakeClusterClients(origin, dest)
+		m := newM()
+		res, err := migratePVC(ctx, c, m)
+		if err != nil {
+			t.Fatalf("migratePVC: %v", err)
+		}
+		if want := "fake-handle-apps-nightly"; res.SnapshotHandle != want {
+			t.Errorf("handle = %q, want the reused snapshot's %q", res.SnapshotHandle, want)
+		}
+		if m.snapshotName != "nightly" {
+			t.Errorf("origin snapshot = %q, want nightly", m.snapshotName)
+		}
+		if _, err := origin.snap.Tracker().Get(snapshotsResource, "apps", "data-snap"); !apierrors.IsNotFound(err) {
+			t.Errorf("origin snapshot data-snap: %v, want none taken", err)
+		}
+		// The reused snapshot is not snapshift's to delete
+		if _, err := origin.snap.Tracker().Get(snapshotsResource, "apps", "nightly"); err != nil {
+			t.Errorf("reused snapshot after --delete-snapshots: %v", err)
+		}
+	})
+}
+
+func TestServerSideApplyReapplies(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &serverSideApply, true)
+	setFlag(t, &createPVC, true)
+	ctx := context.Background()
+	origin := newFakeCluster(fakeVolume("apps", "data", "1Gi")...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	for run := 1; run <= 2; run++ {
+		m := newMigration("apps", "data")
+		m.snapshotName = "data-snap"
+		m.destSnapshotName = "data-snap"
+		// The re-run replicates the origin snapshot of the first
+		m.existingSnapshot = run > 1
+		if _, err := migratePVC(ctx, c, m); err != nil {
+			t.Fatalf("run %d: migratePVC: %v", run, err)
+		}
+	}
+
+	applied := map[string]int{}
+	for _, action := range append(dest.snap.Actions(), dest.k8s.Actions()...) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			continue
+		}
+		applied[patch.GetResource().Resource]++
+	}
+	for _, resource := range []string{"volumesnapshotcontents", "volumesnapshots", "persistentvolumeclaims"} {
+		if applied[resource] != 2 {
+			t.Errorf("%s applied %d times, want once per run", resource, applied[resource])
+		}
+	}
+	for _, action := range append(dest.snap.Actions(), dest.k8s.Actions()...) {
+		if action.GetVerb() == "create" {
+			t.Errorf("unexpected create of %s with --server-side-apply", action.GetResource().Resource)
+		}
+	}
+	pvc, err := dest.k8s.CoreV1().PersistentVolumeClaims("apps").Get(ctx, "data", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != "data-snap" {
+		t.Errorf("PVC data source = %v, want snapshot data-snap", pvc.Spec.DataSource)
+	}
+}
+
+func TestMaxTotalSnapshotsStopsStartingPVCs(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &maxTotalSnapshots, 2)
+	ctx := context.Background()
+	objs := fakeVolume("apps", "a", "1Gi")
+	objs = append(objs, fakeVolume("apps", "b", "1Gi")...)
+	objs = append(objs, fakeVolume("apps", "c", "1Gi")...)
+	objs = append(objs, fakeVolume("apps", "d", "1Gi")...)
+	origin := newFakeCluster(objs...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	var migrations []*migration
+	for _, name := range []string{"a", "b", "c", "d"} {
+		m := newMigration("apps", name)
+		m.snapshotName = name + "-snap"
+		m.destSnapshotName = name + "-snap"
+		migrations = append(migrations, m)
+	}
+	// An existing snapshot does not use the budget
+	if _, err := createSnapshot(ctx, origin.snap, "apps", "a-snap", "a", ""); err != nil {
+		t.Fatal(err)
+	}
+	migrations[0].existingSnapshot = true
+
+	budget := newSnapshotBudget()
+	var results, failed []bulkResult
+	var interrupted error
+	captureStdout(t, func() {
+		results, failed, interrupted = migrateEach(ctx, c, migrations, nil, nil, budget)
+	})
+	if interrupted != nil || len(failed) != 0 {
+		t.Fatalf("migrateEach: interrupted %v, failed %d", interrupted, len(failed))
+	}
+	if len(results) != 3 || budget.Used != 2 {
+		t.Errorf("attempted %d PVCs creating %d snapshots, want 3 creating 2", len(results), budget.Used)
+	}
+	if len(budget.Skipped) != 1 || budget.Skipped[0].Name != "d" {
+		t.Errorf("skipped = %v, want apps/d", budget.Skipped)
+	}
+	if _, err := origin.snap.SnapshotV1().VolumeSnapshots("apps").Get(ctx, "d-snap", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("snapshot of the PVC past the budget: %v", err)
+	}
+	if err := budget.err(); err == nil || !strings.Contains(err.Error(), "1 PVCs not attempted") {
+		t.Errorf("budget error = %v", err)
+	}
+}
+
+func TestMigrateSamePVCNameInTwoNamespaces(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	ctx := context.Background()
+	objs := fakeVolume("a", "data", "1Gi")
+	objs = append(objs, fakeVolume("b", "data", "1Gi")...)
+	origin := newFakeCluster(objs...)
+	dest := newFakeCluster()
+	c := newFakeClusterClients(origin, dest)
+
+	// Default names, as with --all-namespaces
+	migrations := []*migration{newMigration("a", "data"), newMigration("b", "data")}
+	if err := applySnapshotNameTemplates(migrations); err != nil {
+		t.Fatalf("applySnapshotNameTemplates: %v", err)
+	}
+	var failed []bulkResult
+	captureStdout(t, func() {
+		_, failed, _ = migrateEach(ctx, c, migrations, nil, nil, nil)
+	})
+	for _, r := range failed {
+		t.Errorf("%s/%s: %v", r.m.pvcNamespace, r.m.pvcName, r.err)
+	}
+
+	for _, ns := range []string{"a", "b"} {
+		name := "data-snapshot-" + runID
+		content, err := dest.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, "snapcontent-"+ns+"-"+name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("destination content of %s/data: %v", ns, err)
+		}
+		if want := "fake-handle-" + ns + "-" + name; content.Spec.Source.SnapshotHandle == nil || *content.Spec.Source.SnapshotHandle != want {
+			t.Errorf("content of %s/data has handle %v, want %s", ns, content.Spec.Source.SnapshotHandle, want)
+		}
+	}
+
+	// Mapped into one namespace, the two would share a content
+	setFlag(t, &namespaceMap, map[string]string{"a": "dr", "b": "dr"})
+	migrations = []*migration{newMigration("a", "data"), newMigration("b", "data")}
+	if err := applySnapshotNameTemplates(migrations); err == nil || !strings.Contains(err.Error(), "would both be bound to VolumeSnapshotContent snapcontent-dr-data-snapshot-") {
+		t.Errorf("applySnapshotNameTemplates = %v, want the shared content refused", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// fleet is the schema of a --fleet file.
+type fleet struct {
+	Destinations []fleetDestination `json:"destinations"`
+}
+
+// fleetDestination is one destination cluster of a fleet file, with its
+// overrides of the global destination flags.
+type fleetDestination struct {
+	Name           string            `json:"name"`
+	Kubeconfig     string            `json:"kubeconfig,omitempty"`
+	Context        string            `json:"context,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	SnapshotClass  string            `json:"snapshotClass,omitempty"`
+	StorageClasses map[string]string `json:"storageClasses,omitempty"`
+}
+
+// loadFleet reads and validates a fleet file. Unknown fields are rejected so a
+// misspelled override is not silently ignored.
+func loadFleet(path string) (*fleet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet file: %w", err)
+	}
+
+	var f fleet
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid fleet file %s: %w", path, err)
+	}
+
+	if len(f.Destinations) == 0 {
+		return nil, fmt.Errorf("invalid fleet file %s: no destinations", path)
+	}
+	seen := make(map[string]bool)
+	for i, d := range f.Destinations {
+		if d.Name == "" {
+			return nil, fmt.Errorf("invalid fleet file %s: destination %d has no name", path, i+1)
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("invalid fleet file %s: duplicate destination name %q", path, d.Name)
+		}
+		seen[d.Name] = true
+		if d.Kubeconfig == "" && d.Context == "" {
+			return nil, fmt.Errorf("invalid fleet file %s: destination %q needs a kubeconfig or a context", path, d.Name)
+		}
+		for from, to := range d.StorageClasses {
+			if from == "" || to == "" {
+				return nil, fmt.Errorf("invalid fleet file %s: destination %q has an empty storage class mapping", path, d.Name)
+			}
+		}
+	}
+
+	return &f, nil
+}
+
+// fleetDestinations connects to every destination cluster of a fleet file.
+func fleetDestinations(path string) ([]*destination, error) {
+	f, err := loadFleet(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dests := make([]*destination, 0, len(f.Destinations))
+	for _, fd := range f.Destinations {
+		d := &destination{
+			name:           fd.Name,
+			context:        fd.Context,
+			namespace:      fd.Namespace,
+			snapshotClass:  fd.SnapshotClass,
+			storageClasses: fd.StorageClasses,
+			kubectlFlags:   kubectlFlags(fd.Kubeconfig, fd.Context, destOverrides()),
+		}
+		if len(f.Destinations) > 1 {
+			d.prefix = fmt.Sprintf("[%s] ", fd.Name)
+		}
+
+		fmt.Fprintf(stepOut, "%sConnecting to destination cluster...\n", d.prefix)
+		k8sClient, snapClient, err := createClients(fd.Kubeconfig, fd.Context, destOverrides())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create destination cluster clients for %s: %w", fd.Name, err)
+		}
+		d.k8s = k8sClient
+		d.snap = snapClient
+
+		dests = append(dests, d)
+	}
+
+	return dests, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	groupsnapshotv1alpha1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumegroupsnapshot/v1alpha1"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// newerGroupSnapshotVersions are VolumeGroupSnapshot API versions served by
+// external-snapshotter 8 and later, which snapshift's client does not speak.
+var newerGroupSnapshotVersions = []string{"v1beta1", "v1beta2", "v1"}
+
+// requireGroupSnapshotAPI fails with a clear error when the origin cluster
+// does not serve VolumeGroupSnapshots in the v1alpha1 version snapshift uses.
+func requireGroupSnapshotAPI(client kubernetes.Interface) error {
+	gv := groupsnapshotv1alpha1.SchemeGroupVersion
+	if servesGroupSnapshots(client, gv.String()) {
+		return nil
+	}
+	for _, version := range newerGroupSnapshotVersions {
+		newer := gv.Group + "/" + version
+		if servesGroupSnapshots(client, newer) {
+			return fmt.Errorf("the origin cluster serves VolumeGroupSnapshots as %s only, but snapshift supports %s (external-snapshotter 7); migrate the PVCs one by one with --selector", newer, gv)
+		}
+	}
+	return fmt.Errorf("the origin cluster does not serve VolumeGroupSnapshots (%s); install the group snapshot CRDs and enable the snapshot controller's group snapshot feature, or migrate the PVCs one by one with --selector", gv)
+}
+
+// servesGroupSnapshots reports whether discovery lists VolumeGroupSnapshots
+// in a group version.
+func servesGroupSnapshots(client kubernetes.Interface, groupVersion string) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "volumegroupsnapshots" {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateVolumeGroup takes one VolumeGroupSnapshot of the PVCs matching
+// --volume-group-selector in the source namespace, so they are captured at
+// the same point in time, and replicates the handle of each member snapshot
+// to the destinations like a single PVC migration.
+func migrateVolumeGroup(ctx context.Context, c *clusterClients) (err error) {
+	namespace := pvcNamespace
+	groupName := fmt.Sprintf("snapshift-group-%s", runID)
+
+	if err := requireGroupSnapshotAPI(c.originK8s); err != nil {
+		return err
+	}
+
+	pvcs, err := groupPVCs(ctx, c.originK8s, namespace)
+	if err != nil {
+		return err
+	}
+	if err := checkGroupBudget(len(pvcs)); err != nil {
+		return err
+	}
+
+	// Step 1: Create the VolumeGroupSnapshot in the origin cluster
+	fmt.Fprintf(stepOut, "Creating VolumeGroupSnapshot %s/%s of %d PVCs in origin cluster...\n", namespace, groupName, len(pvcs))
+	if err := createGroupSnapshot(ctx, c.originSnap, namespace, groupName); err != nil {
+		return fmt.Errorf("failed to create origin VolumeGroupSnapshot: %w", err)
+	}
+
+	destsStarted := false
+	defer func() {
+		if err != nil && !destsStarted {
+			fmt.Fprintf(stepOut, "\n⚠ Operation failed, cleaning up created resources...\n")
+			fmt.Fprintf(stepOut, "  Deleting origin VolumeGroupSnapshot %s/%s...\n", namespace, groupName)
+			cleanupCtx, cancel := cleanupContext()
+			defer cancel()
+			err := c.originSnap.GroupsnapshotV1alpha1().VolumeGroupSnapshots(namespace).Delete(cleanupCtx, groupName, metav1.DeleteOptions{})
+			if err != nil {
+				fmt.Fprintf(stepOut, "  ✗ Failed to delete origin VolumeGroupSnapshot: %v\n", err)
+			} else {
+				fmt.Fprintf(stepOut, "  ✓ Deleted origin VolumeGroupSnapshot\n")
+			}
+		}
+	}()
+
+	// Step 2: Wait for it and its member snapshots to be ready
+	fmt.Fprintf(stepOut, "Waiting for origin VolumeGroupSnapshot to be ready...\n")
+	group, err := waitForGroupSnapshotReady(ctx, c.originSnap, namespace, groupName)
+	if err != nil {
+		return fmt.Errorf("failed waiting for origin VolumeGroupSnapshot: %w", err)
+	}
+	if len(group.Status.VolumeSnapshotRefList) == 0 {
+		return fmt.Errorf("origin VolumeGroupSnapshot %s/%s has no member snapshots", namespace, groupName)
+	}
+
+	// Step 3: Resolve the handle and source PVC of every member
+	migrations := make([]*migration, 0, len(group.Status.VolumeSnapshotRefList))
+	origins := make([]*originState, 0, len(group.Status.VolumeSnapshotRefList))
+	for _, ref := range group.Status.VolumeSnapshotRefList {
+		m, origin, err := groupMember(ctx, c, namespace, ref.Name, pvcs)
+		if err != nil {
+			return err
+		}
+		if err := avoidNameCollision(c, m); err != nil {
+			return err
+		}
+		fmt.Fprintf(stepOut, "  %s -> %s (handle %s)\n", m.pvcName, m.snapshotName, origin.snapshotHandle)
+		migrations = append(migrations, m)
+		origins = append(origins, origin)
+	}
+
+	// Step 4: Replicate every member to every destination
+	destsStarted = true
+	var failed []string
+	for i, m := range migrations {
+		fmt.Fprintf(stepOut, "\n==> [%d/%d] %s/%s\n", i+1, len(migrations), m.pvcNamespace, m.pvcName)
+		if _, _, err := replicateToDests(ctx, c, m, origins[i], nil); err != nil {
+			fmt.Fprintf(stepOut, "✗ Failed to replicate %s/%s: %v\n", m.pvcNamespace, m.pvcName, err)
+			failed = append(failed, m.pvcName)
+			continue
+		}
+		annotateSourcePVC(ctx, c, m)
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintf(stepOut, "\nOrigin VolumeGroupSnapshot %s/%s was kept for a retry\n", namespace, groupName)
+		return fmt.Errorf("failed to replicate %d/%d group members: %s", len(failed), len(migrations), strings.Join(failed, ", "))
+	}
+
+	fmt.Fprintf(stepOut, "\n✓ Successfully completed group snapshot migration!\n")
+	fmt.Fprintf(stepOut, "  Origin VolumeGroupSnapshot: %s/%s\n", namespace, groupName)
+	for _, m := range migrations {
+		for _, d := range c.dests {
+			dm := d.migration(m)
+			fmt.Fprintf(stepOut, "  %sDestination snapshot: %s/%s\n", d.prefix, dm.destNamespace, dm.destSnapshotName)
+			if createPVC {
+				fmt.Fprintf(stepOut, "  %sDestination PVC: %s/%s\n", d.prefix, dm.destPVCNamespace, dm.destPVCName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// groupPVCs lists the PVCs matching --volume-group-selector, keyed by the CSI
+// volume handle of their PersistentVolume.
+func groupPVCs(ctx context.Context, client kubernetes.Interface, namespace string) (map[string]*corev1.PersistentVolumeClaim, error) {
+	list, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: volumeGroupSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no PVCs in %s match %q", namespace, volumeGroupSelector)
+	}
+
+	pvcs := make(map[string]*corev1.PersistentVolumeClaim, len(list.Items))
+	for i := range list.Items {
+		pvc := &list.Items[i]
+		if pvc.Spec.VolumeName == "" {
+			return nil, fmt.Errorf("PVC %s/%s is not bound", namespace, pvc.Name)
+		}
+		pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get PersistentVolume of PVC %s: %w", pvc.Name, err)
+		}
+		if pv.Spec.CSI == nil {
+			return nil, fmt.Errorf("PVC %s/%s is not a CSI volume", namespace, pvc.Name)
+		}
+		if err := checkDriverAllowed(pv.Spec.CSI.Driver); err != nil {
+			return nil, fmt.Errorf("PVC %s/%s: %w", namespace, pvc.Name, err)
+		}
+		if err := checkSnapshotSize(fmt.Sprintf("PVC %s/%s request", namespace, pvc.Name), pvc.Spec.Resources.Requests[corev1.ResourceStorage]); err != nil {
+			return nil, err
+		}
+		pvcs[pv.Spec.CSI.VolumeHandle] = pvc
+	}
+	return pvcs, nil
+}
+
+// groupMember resolves a member snapshot of the group into the migration and
+// origin state a single PVC migration would have.
+func groupMember(ctx context.Context, c *clusterClients, namespace, name string, pvcs map[string]*corev1.PersistentVolumeClaim) (*migration, *originState, error) {
+	snapshot, err := c.originSnap.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get member VolumeSnapshot %s: %w", name, err)
+	}
+	if snapshot.Status == nil || snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return nil, nil, fmt.Errorf("member VolumeSnapshot %s does not have a bound VolumeSnapshotContent", name)
+	}
+	content, err := c.originSnap.SnapshotV1().VolumeSnapshotContents().Get(ctx, *snapshot.Status.BoundVolumeSnapshotContentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get VolumeSnapshotContent of member %s: %w", name, err)
+	}
+	if content.Status == nil || content.Status.SnapshotHandle == nil {
+		return nil, nil, fmt.Errorf("VolumeSnapshotContent %s of member %s does not have a snapshot handle", content.Name, name)
+	}
+
+	pvc := memberPVC(snapshot, content, pvcs)
+	if pvc == nil {
+		return nil, nil, fmt.Errorf("cannot tell which PVC member VolumeSnapshot %s was taken from", name)
+	}
+
+	if content.Spec.DeletionPolicy == snapshotv1.VolumeSnapshotContentDelete {
+		if protectOrigin {
+			if err := retainContent(ctx, c.originSnap, content.Name); err != nil {
+				return nil, nil, fmt.Errorf("failed to protect VolumeSnapshotContent %s: %w", content.Name, err)
+			}
+		} else {
+			fmt.Fprintf(stepOut, "⚠ Warning: member VolumeSnapshotContent %s has DeletionPolicy Delete, use --protect-origin to switch it to Retain\n", content.Name)
+		}
+	}
+
+	storageSize := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if createPVC {
+		storageSize, err = resolvePVCSize(storageSize, snapshot.Status.RestoreSize, strictSize)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	destHandle, err := destSnapshotHandle(ctx, *content.Status.SnapshotHandle)
+	if err != nil {
+		return nil, nil, fmt.Errorf("member VolumeSnapshot %s: %w", name, err)
+	}
+
+	m := newMigration(namespace, pvc.Name)
+	m.snapshotName = snapshot.Name
+	m.destSnapshotName = snapshot.Name
+
+	origin := &originState{
+		sourcePVC:      pvc,
+		content:        content,
+		snapshotHandle: *content.Status.SnapshotHandle,
+		destHandle:     destHandle,
+		storageSize:    storageSize,
+		csiDriver:      getOriginCSIDriver(ctx, c.originK8s, content.Spec.Driver),
+	}
+	return m, origin, nil
+}
+
+// memberPVC returns the source PVC of a member snapshot, from its source if
+// set, otherwise from the volume handle of its content.
+func memberPVC(snapshot *snapshotv1.VolumeSnapshot, content *snapshotv1.VolumeSnapshotContent, pvcs map[string]*corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
+	if name := snapshot.Spec.Source.PersistentVolumeClaimName; name != nil {
+		for _, pvc := range pvcs {
+			if pvc.Name == *name {
+				return pvc
+			}
+		}
+	}
+	if handle := content.Spec.Source.VolumeHandle; handle != nil {
+		return pvcs[*handle]
+	}
+	return nil
+}
+
+func createGroupSnapshot(ctx context.Context, client snapshotclient.Interface, namespace, name string) error {
+	selector, err := metav1.ParseToLabelSelector(volumeGroupSelector)
+	if err != nil {
+		return fmt.Errorf("invalid --volume-group-selector: %w", err)
+	}
+
+	group := &groupsnapshotv1alpha1.VolumeGroupSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: groupsnapshotv1alpha1.VolumeGroupSnapshotSpec{
+			Source: groupsnapshotv1alpha1.VolumeGroupSnapshotSource{
+				Selector: *selector,
+			},
+		},
+	}
+	if volumeGroupSnapshotClass != "" {
+		group.Spec.VolumeGroupSnapshotClassName = &volumeGroupSnapshotClass
+	}
+
+	_, err = client.GroupsnapshotV1alpha1().VolumeGroupSnapshots(namespace).Create(ctx, group, metav1.CreateOptions{})
+	return err
+}
+
+func waitForGroupSnapshotReady(ctx context.Context, client snapshotclient.Interface, namespace, name string) (*groupsnapshotv1alpha1.VolumeGroupSnapshot, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+			group, err := client.GroupsnapshotV1alpha1().VolumeGroupSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+
+			if group.Status != nil {
+				if group.Status.Error != nil && group.Status.Error.Message != nil {
+					return nil, fmt.Errorf("group snapshot error: %s", *group.Status.Error.Message)
+				}
+				if group.Status.ReadyToUse != nil && *group.Status.ReadyToUse {
+					return group, nil
+				}
+			}
+
+			fmt.Fprintf(stepOut, "  Group snapshot status: ReadyToUse=false\n")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRequireGroupSnapshotAPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		served  []string
+		wantErr string
+	}{
+		{name: "v1alpha1", served: []string{"groupsnapshot.storage.k8s.io/v1alpha1"}},
+		{name: "v1alpha1 and v1beta1", served: []string{"groupsnapshot.storage.k8s.io/v1alpha1", "groupsnapshot.storage.k8s.io/v1beta1"}},
+		{name: "v1beta1 only", served: []string{"groupsnapshot.storage.k8s.io/v1beta1"}, wantErr: "serves VolumeGroupSnapshots as groupsnapshot.storage.k8s.io/v1beta1 only"},
+		{name: "not served", wantErr: "does not serve VolumeGroupSnapshots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := k8sfake.NewSimpleClientset()
+			for _, gv := range tt.served {
+				client.Resources = append(client.Resources, &metav1.APIResourceList{
+					GroupVersion: gv,
+					APIResources: []metav1.APIResource{{Name: "volumegroupsnapshots", Namespaced: true, Kind: "VolumeGroupSnapshot"}},
+				})
+			}
+			err := requireGroupSnapshotAPI(client)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
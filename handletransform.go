@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// handleExecPrefix marks a --handle-transform run as an external command
+// instead of a regular expression rewrite.
+const handleExecPrefix = "exec:"
+
+// handleTransformer rewrites the origin snapshot handle into the handle the
+// destination contents use, for storage where the same snapshot has another
+// identifier in the destination account.
+type handleTransformer func(ctx context.Context, handle string) (string, error)
+
+// parseHandleTransform parses --handle-transform. A value of the form
+// exec:<command> runs the command with sh, passing the origin handle on stdin
+// and in SNAPSHIFT_SNAPSHOT_HANDLE, and uses its trimmed output. Any other
+// value is a from=to rewrite, replacing matches of the regular expression
+// before the first = with the replacement after it, which may refer to
+// submatches as $1 or ${name}. An empty value leaves handles unchanged.
+func parseHandleTransform(spec string) (handleTransformer, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if command, ok := strings.CutPrefix(spec, handleExecPrefix); ok {
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("invalid --handle-transform %q: no command after %s", spec, handleExecPrefix)
+		}
+		return func(ctx context.Context, handle string) (string, error) {
+			return execHandleTransform(ctx, command, handle)
+		}, nil
+	}
+
+	from, to, ok := strings.Cut(spec, "=")
+	if !ok || from == "" {
+		return nil, fmt.Errorf("invalid --handle-transform %q: expected from=to or %s<command>", spec, handleExecPrefix)
+	}
+	re, err := regexp.Compile(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --handle-transform %q: %w", spec, err)
+	}
+	return func(_ context.Context, handle string) (string, error) {
+		if !re.MatchString(handle) {
+			return "", fmt.Errorf("--handle-transform pattern %q does not match snapshot handle %s", from, handle)
+		}
+		return re.ReplaceAllString(handle, to), nil
+	}, nil
+}
+
+func execHandleTransform(ctx context.Context, command, handle string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(handle + "\n")
+	cmd.Env = append(os.Environ(), "SNAPSHIFT_SNAPSHOT_HANDLE="+handle)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("--handle-transform command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("--handle-transform command failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// destSnapshotHandle returns the handle for the destination contents of an
+// origin snapshot handle, rewritten by --handle-transform if set.
+func destSnapshotHandle(ctx context.Context, handle string) (string, error) {
+	if transformHandle == nil {
+		return handle, nil
+	}
+	transformed, err := transformHandle(ctx, handle)
+	if err != nil {
+		return "", err
+	}
+	if transformed == "" {
+		return "", fmt.Errorf("--handle-transform turned snapshot handle %s into an empty handle", handle)
+	}
+	if transformed != handle {
+		fmt.Fprintf(stepOut, "Destination snapshot handle: %s\n", transformed)
+	}
+	return transformed, nil
+}
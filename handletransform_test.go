@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseHandleTransform(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		handle   string
+		want     string
+		parseErr string
+		runErr   string
+	}{
+		{
+			name:   "rewrite account and region",
+			spec:   `^arn:aws:ec2:([a-z0-9-]+):111111111111:(.*)$=arn:aws:ec2:eu-west-1:222222222222:$2`,
+			handle: "arn:aws:ec2:us-east-1:111111111111:snapshot/snap-0abc",
+			want:   "arn:aws:ec2:eu-west-1:222222222222:snapshot/snap-0abc",
+		},
+		{name: "equals in replacement", spec: "^snap-=id=snap-", handle: "snap-1", want: "id=snap-1"},
+		{name: "no match", spec: "^vol-=snap-", handle: "snap-1", runErr: "does not match"},
+		{name: "empty result", spec: "^.*$=", handle: "snap-1", runErr: "empty handle"},
+		{name: "command", spec: "exec:sed s/east/west/", handle: "us-east-1/snap", want: "us-west-1/snap"},
+		{name: "command from environment", spec: `exec:echo "copy-$SNAPSHIFT_SNAPSHOT_HANDLE"`, handle: "snap-1", want: "copy-snap-1"},
+		{name: "command failure", spec: "exec:echo no such snapshot >&2; exit 3", handle: "snap-1", runErr: "no such snapshot"},
+		{name: "command printing nothing", spec: "exec:true", handle: "snap-1", runErr: "empty handle"},
+		{name: "missing separator", spec: "snap-", parseErr: "expected from=to"},
+		{name: "empty pattern", spec: "=snap-", parseErr: "expected from=to"},
+		{name: "invalid pattern", spec: "(=snap-", parseErr: "missing closing )"},
+		{name: "empty command", spec: "exec: ", parseErr: "no command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := parseHandleTransform(tt.spec)
+			if tt.parseErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.parseErr) {
+					t.Fatalf("parse error = %v, want it to contain %q", err, tt.parseErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			setFlag(t, &transformHandle, transform)
+			got, err := destSnapshotHandle(context.Background(), tt.handle)
+			if tt.runErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.runErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.runErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("transform: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("handle = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestSnapshotHandleUnchanged(t *testing.T) {
+	setFlag(t, &transformHandle, nil)
+	got, err := destSnapshotHandle(context.Background(), "snap-1")
+	if err != nil || got != "snap-1" {
+		t.Errorf("destSnapshotHandle = %q, %v, want the handle unchanged", got, err)
+	}
+}
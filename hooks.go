@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Hook stages, also used as the value of the hook label on hook Jobs
+const (
+	hookPreSnapshot  = "pre-snapshot"
+	hookPostSnapshot = "post-snapshot"
+)
+
+// hookLabel marks the Jobs snapshift creates for hooks.
+const hookLabel = "snapshift.io/hook"
+
+// Hook Job templates loaded from --pre-snapshot-job and --post-snapshot-job
+var preSnapshotJob, postSnapshotJob *batchv1.Job
+
+// loadHookJob reads a Job manifest used as a hook template.
+func loadHookJob(path string) (*batchv1.Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hook Job manifest: %w", err)
+	}
+
+	var job batchv1.Job
+	if err := yaml.UnmarshalStrict(data, &job); err != nil {
+		return nil, fmt.Errorf("invalid hook Job manifest %s: %w", path, err)
+	}
+	if job.Kind != "" && job.Kind != "Job" {
+		return nil, fmt.Errorf("invalid hook Job manifest %s: kind is %s, not Job", path, job.Kind)
+	}
+	if len(job.Spec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("invalid hook Job manifest %s: no containers", path)
+	}
+
+	return &job, nil
+}
+
+// buildHookJob renders a hook Job for one migration from its template. The Job
+// name is generated from the template's name, and it runs in the source PVC's
+// namespace unless the template sets one. Its containers receive the PVC and
+// snapshot names as environment variables.
+func buildHookJob(template *batchv1.Job, stage string, m *migration) *batchv1.Job {
+	job := template.DeepCopy()
+
+	base := job.Name
+	if base == "" {
+		base = "snapshift-" + stage
+	}
+	job.Name = ""
+	job.GenerateName = base + "-"
+	if job.Namespace == "" {
+		job.Namespace = m.pvcNamespace
+	}
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[hookLabel] = stage
+
+	pod := &job.Spec.Template.Spec
+	if pod.RestartPolicy == "" {
+		pod.RestartPolicy = corev1.RestartPolicyNever
+	}
+	env := []corev1.EnvVar{
+		{Name: "SNAPSHIFT_HOOK", Value: stage},
+		{Name: "SNAPSHIFT_PVC_NAMESPACE", Value: m.pvcNamespace},
+		{Name: "SNAPSHIFT_PVC_NAME", Value: m.pvcName},
+		{Name: "SNAPSHIFT_SNAPSHOT_NAME", Value: m.snapshotName},
+	}
+	for i := range pod.Containers {
+		pod.Containers[i].Env = append(pod.Containers[i].Env, env...)
+	}
+
+	return job
+}
+
+// cleanupHookTimeout bounds the post-snapshot Job run on the way out of a
+// failed or interrupted migration.
+const cleanupHookTimeout = 2 * time.Minute
+
+// runHookJob creates a hook Job in the origin cluster and waits for it to
+// complete. A successful Job is deleted unless --keep-hook-jobs is set; a
+// failed one is kept for its logs. A Job still running when ctx is done is
+// deleted too, so it does not keep running after the migration gave up on it.
+func runHookJob(ctx context.Context, client kubernetes.Interface, template *batchv1.Job, stage string, m *migration) error {
+	job := buildHookJob(template, stage, m)
+	job, err := client.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create %s Job: %w", stage, err)
+	}
+	fmt.Fprintf(stepOut, "Created %s Job %s/%s, waiting for it to complete...\n", stage, job.Namespace, job.Name)
+
+	if err := waitForJobComplete(ctx, client, job.Namespace, job.Name); err != nil {
+		if ctx.Err() != nil {
+			abortHookJob(client, stage, job)
+		}
+		return fmt.Errorf("%s Job %s/%s: %w", stage, job.Namespace, job.Name, err)
+	}
+	fmt.Fprintf(stepOut, "✓ %s Job %s/%s completed\n", stage, job.Namespace, job.Name)
+
+	if !keepHookJobs {
+		if err := deleteHookJob(client, job); err != nil {
+			fmt.Fprintf(stepOut, "⚠ Warning: Failed to delete %s Job %s/%s: %v\n", stage, job.Namespace, job.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// abortHookJob deletes a hook Job whose wait was interrupted, along with its
+// pods, and records it in the cleanup report. With --keep-hook-jobs the Job
+// is only reported, as it may still be running.
+func abortHookJob(client kubernetes.Interface, stage string, job *batchv1.Job) {
+	if keepHookJobs {
+		fmt.Fprintf(stepOut, "⚠ Warning: Keeping interrupted %s Job %s/%s, which may still be running\n", stage, job.Namespace, job.Name)
+		recordCleanupKept("origin", originContext, "Job", job.Namespace, job.Name)
+		return
+	}
+	err := deleteHookJob(client, job)
+	recordCleanup("origin", originContext, "Job", job.Namespace, job.Name, err)
+	if err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Failed to delete interrupted %s Job %s/%s, which may still be running: %v\n", stage, job.Namespace, job.Name, err)
+		return
+	}
+	fmt.Fprintf(stepOut, "Deleted interrupted %s Job %s/%s\n", stage, job.Namespace, job.Name)
+}
+
+// deleteHookJob deletes a hook Job and, in the background, its pods. It does
+// not use the migration's context, which may already be done.
+func deleteHookJob(client kubernetes.Interface, job *batchv1.Job) error {
+	propagation := metav1.DeletePropagationBackground
+	return client.BatchV1().Jobs(job.Namespace).Delete(context.Background(), job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+}
+
+// waitForJobComplete waits until a Job has completed, failing if it fails.
+func waitForJobComplete(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			job, err := client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			for _, cond := range job.Status.Conditions {
+				if cond.Status != corev1.ConditionTrue {
+					continue
+				}
+				switch cond.Type {
+				case batchv1.JobComplete:
+					return nil
+				case batchv1.JobFailed:
+					return fmt.Errorf("failed: %s", cond.Message)
+				}
+			}
+
+			fmt.Fprintf(stepOut, "  Job status: Active=%d, Succeeded=%d, Failed=%d\n", job.Status.Active, job.Status.Succeeded, job.Status.Failed)
+		}
+	}
+}
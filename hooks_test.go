@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func hookJobTemplate() *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "flush", Labels: map[string]string{"app": "db"}},
+		Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "flush", Image: "busybox", Env: []corev1.EnvVar{{Name: "MODE", Value: "fast"}}},
+			{Name: "sidecar", Image: "busybox"},
+		}}}},
+	}
+}
+
+func TestBuildHookJob(t *testing.T) {
+	m := &migration{pvcNamespace: "apps", pvcName: "data", snapshotName: "data-snap"}
+	want := []corev1.EnvVar{
+		{Name: "SNAPSHIFT_HOOK", Value: hookPreSnapshot},
+		{Name: "SNAPSHIFT_PVC_NAMESPACE", Value: "apps"},
+		{Name: "SNAPSHIFT_PVC_NAME", Value: "data"},
+		{Name: "SNAPSHIFT_SNAPSHOT_NAME", Value: "data-snap"},
+	}
+
+	template := hookJobTemplate()
+	job := buildHookJob(template, hookPreSnapshot, m)
+	if job.Name != "" || job.GenerateName != "flush-" || job.Namespace != "apps" {
+		t.Errorf("job name = %q, generateName = %q, namespace = %q", job.Name, job.GenerateName, job.Namespace)
+	}
+	if job.Labels[hookLabel] != hookPreSnapshot || job.Labels["app"] != "db" {
+		t.Errorf("labels = %v", job.Labels)
+	}
+	if job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("restart policy = %q, want Never", job.Spec.Template.Spec.RestartPolicy)
+	}
+	containers := job.Spec.Template.Spec.Containers
+	if got := containers[0].Env; !reflect.DeepEqual(got, append([]corev1.EnvVar{{Name: "MODE", Value: "fast"}}, want...)) {
+		t.Errorf("first container env = %v", got)
+	}
+	if got := containers[1].Env; !reflect.DeepEqual(got, want) {
+		t.Errorf("second container env = %v", got)
+	}
+	if !reflect.DeepEqual(template, hookJobTemplate()) {
+		t.Error("template was modified")
+	}
+
+	// A template without a name or with a namespace and restart policy
+	template.Name = ""
+	template.Namespace = "hooks"
+	template.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+	job = buildHookJob(template, hookPostSnapshot, m)
+	if job.GenerateName != "snapshift-post-snapshot-" || job.Namespace != "hooks" || job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyOnFailure {
+		t.Errorf("job generateName = %q, namespace = %q, restart policy = %q", job.GenerateName, job.Namespace, job.Spec.Template.Spec.RestartPolicy)
+	}
+}
+
+func TestRunHookJob(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	setFlag(t, &originContext, "prod")
+
+	tests := []struct {
+		name       string
+		condition  batchv1.JobConditionType
+		keep       bool
+		wantErr    string
+		wantKept   bool
+		wantReport []cleanupRecord
+	}{
+		{name: "completed", condition: batchv1.JobComplete},
+		{name: "completed, kept", condition: batchv1.JobComplete, keep: true, wantKept: true},
+		{name: "failed", condition: batchv1.JobFailed, wantErr: "failed: BackoffLimitExceeded", wantKept: true},
+		{
+			name:       "interrupted",
+			wantErr:    context.DeadlineExceeded.Error(),
+			wantReport: []cleanupRecord{{Cluster: "origin", Context: "prod", Kind: "Job", Namespace: "apps", Name: "flush-1", Result: cleanupDeleted}},
+		},
+		{
+			name:       "interrupted, kept",
+			keep:       true,
+			wantErr:    context.DeadlineExceeded.Error(),
+			wantKept:   true,
+			wantReport: []cleanupRecord{{Cluster: "origin", Context: "prod", Kind: "Job", Namespace: "apps", Name: "flush-1", Result: cleanupKept}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := filepath.Join(t.TempDir(), "cleanup.jsonl")
+			setFlag(t, &cleanupReport, report)
+			setFlag(t, &keepHookJobs, tt.keep)
+
+			client := k8sfake.NewSimpleClientset()
+			// The fake does not generate names; the Job gets the status it
+			// ends with right away
+			client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+				job.Name = job.GenerateName + "1"
+				if tt.condition != "" {
+					job.Status.Conditions = []batchv1.JobCondition{{Type: tt.condition, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+				} else {
+					job.Status.Active = 1
+				}
+				return false, nil, nil
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			var err error
+			captureStdout(t, func() {
+				err = runHookJob(ctx, client, hookJobTemplate(), hookPreSnapshot, &migration{pvcNamespace: "apps", pvcName: "data", snapshotName: "data-snap"})
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runHookJob() = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "apps/flush-1")) {
+				t.Fatalf("runHookJob() = %v, want an error naming the Job and containing %q", err, tt.wantErr)
+			}
+			if strings.Contains(tt.wantErr, "deadline") && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("runHookJob() = %v, want context.DeadlineExceeded", err)
+			}
+
+			_, err = client.BatchV1().Jobs("apps").Get(context.Background(), "flush-1", metav1.GetOptions{})
+			if kept := err == nil; kept != tt.wantKept {
+				if !kept && !apierrors.IsNotFound(err) {
+					t.Fatal(err)
+				}
+				t.Errorf("Job kept = %v, want %v", kept, tt.wantKept)
+			}
+
+			var records []cleanupRecord
+			if tt.wantReport != nil {
+				records = readCleanupReport(t, report)
+				for i := range records {
+					records[i].Time = time.Time{}
+				}
+			}
+			if !reflect.DeepEqual(records, tt.wantReport) {
+				t.Errorf("cleanup report = %+v, want %+v", records, tt.wantReport)
+			}
+		})
+	}
+}
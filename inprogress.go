@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// inProgressSnapshots returns the snapshots of a PVC that are not ready yet,
+// oldest first, leaving out the snapshot named skip.
+func inProgressSnapshots(ctx context.Context, client snapshotclient.Interface, namespace, pvcName, skip string) ([]snapshotv1.VolumeSnapshot, error) {
+	list, err := client.SnapshotV1().VolumeSnapshots(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshots in namespace %s: %w", namespace, err)
+	}
+
+	var snapshots []snapshotv1.VolumeSnapshot
+	for _, s := range list.Items {
+		source := s.Spec.Source.PersistentVolumeClaimName
+		if source == nil || *source != pvcName || s.Name == skip || s.DeletionTimestamp != nil {
+			continue
+		}
+		if s.Status != nil && s.Status.ReadyToUse != nil && *s.Status.ReadyToUse {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreationTimestamp.Before(&snapshots[j].CreationTimestamp)
+	})
+	return snapshots, nil
+}
+
+// checkInProgressSnapshots warns about snapshots of the source PVC that are
+// still being taken, since another one adds load on the storage backend and
+// an old one may be stuck. With --reuse-in-progress it returns the newest one
+// without an error, to be waited for instead of taking another, or "".
+func checkInProgressSnapshots(ctx context.Context, client snapshotclient.Interface, m *migration) string {
+	snapshots, err := inProgressSnapshots(ctx, client, m.pvcNamespace, m.pvcName, m.snapshotName)
+	if err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Cannot check for snapshots of PVC %s/%s in progress: %v\n", m.pvcNamespace, m.pvcName, err)
+		return ""
+	}
+	if len(snapshots) == 0 {
+		return ""
+	}
+
+	fmt.Fprintf(stepOut, "⚠ Warning: PVC %s/%s already has %d snapshot(s) in progress:\n", m.pvcNamespace, m.pvcName, len(snapshots))
+	reuse := ""
+	for _, s := range snapshots {
+		age := time.Since(s.CreationTimestamp.Time).Round(time.Second)
+		if s.Status != nil && s.Status.Error != nil && s.Status.Error.Message != nil {
+			fmt.Fprintf(stepOut, "  %s (age %s): %s\n", s.Name, age, *s.Status.Error.Message)
+			continue
+		}
+		fmt.Fprintf(stepOut, "  %s (age %s)\n", s.Name, age)
+		reuse = s.Name
+	}
+	if !reuseInProgress {
+		fmt.Fprintf(stepOut, "  Use --reuse-in-progress to wait for the newest one instead of taking another snapshot\n")
+		return ""
+	}
+	if reuse == "" {
+		fmt.Fprintf(stepOut, "  None of them can be reused, taking another snapshot\n")
+		return ""
+	}
+	fmt.Fprintf(stepOut, "Reusing snapshot %s/%s in progress instead of taking another\n", m.pvcNamespace, reuse)
+	return reuse
+}
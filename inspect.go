@@ -0,0 +1,262 @@
+package main
+
+import (
+	"io"
+)
+
+// plan is how snapshift resolved its flags, printed by --inspect-plan. It is
+// built without contacting any cluster, so PVCs matched by a selector,
+// StatefulSet or volume group, and the PVC of a source snapshot, are not
+// listed.
+type plan struct {
+	RunID        string        `json:"runID"`
+	Origin       planCluster   `json:"origin"`
+	Destinations []planCluster `json:"destinations"`
+	Source       planSource    `json:"source"`
+	// Migration is only resolved for a single --pvc or --source-snapshot
+	Migration *planMigration  `json:"migration,omitempty"`
+	Naming    planNaming      `json:"naming"`
+	Snapshots planSnapshots   `json:"snapshots"`
+	PVC       *planPVC        `json:"pvc,omitempty"`
+	Run       planRunSettings `json:"run"`
+}
+
+type planCluster struct {
+	Name             string            `json:"name"`
+	Kubeconfig       string            `json:"kubeconfig,omitempty"`
+	Context          string            `json:"context,omitempty"`
+	KubeconfigSecret string            `json:"kubeconfigSecret,omitempty"`
+	Namespace        string            `json:"namespace,omitempty"`
+	SnapshotClass    string            `json:"snapshotClass,omitempty"`
+	StorageClasses   map[string]string `json:"storageClasses,omitempty"`
+	As               string            `json:"as,omitempty"`
+	AsGroups         []string          `json:"asGroups,omitempty"`
+}
+
+type planSource struct {
+	Namespace                string `json:"namespace"`
+	PVC                      string `json:"pvc,omitempty"`
+	SourceSnapshot           string `json:"sourceSnapshot,omitempty"`
+	Selector                 string `json:"selector,omitempty"`
+	AllNamespaces            bool   `json:"allNamespaces,omitempty"`
+	StatefulSet              string `json:"statefulSet,omitempty"`
+	Pod                      string `json:"pod,omitempty"`
+	Deployment               string `json:"deployment,omitempty"`
+	VolumeGroupSelector      string `json:"volumeGroupSelector,omitempty"`
+	VolumeGroupSnapshotClass string `json:"volumeGroupSnapshotClass,omitempty"`
+}
+
+type planMigration struct {
+	SnapshotName     string   `json:"snapshotName"`
+	DestNamespace    string   `json:"destNamespace"`
+	DestSnapshotName string   `json:"destSnapshotName"`
+	DestPVCNamespace string   `json:"destPVCNamespace,omitempty"`
+	DestPVCNames     []string `json:"destPVCNames,omitempty"`
+	// CopySnapshot is set when the destination snapshot is copied to the
+	// PVC namespace
+	CopySnapshot bool `json:"copySnapshot,omitempty"`
+}
+
+type planNaming struct {
+	OriginSnapshotNameTemplate string            `json:"originSnapshotNameTemplate,omitempty"`
+	DestSnapshotNameTemplate   string            `json:"destSnapshotNameTemplate,omitempty"`
+	DestNamespace              string            `json:"destNamespace,omitempty"`
+	NamespaceMap               map[string]string `json:"namespaceMap,omitempty"`
+}
+
+type planSnapshots struct {
+	SnapshotClass             string   `json:"snapshotClass"`
+	DestSnapshotClass         string   `json:"destSnapshotClass"`
+	SelectDestClassBy         string   `json:"selectDestClassBy"`
+	DestSnapshotClassSelector string   `json:"destSnapshotClassSelector,omitempty"`
+	DestDriver                string   `json:"destDriver,omitempty"`
+	DestDeletionSecret        string   `json:"destDeletionSecret,omitempty"`
+	ProtectOrigin             bool     `json:"protectOrigin"`
+	WaitFor                   string   `json:"waitFor"`
+	WaitForContentReady       bool     `json:"waitForContentReady"`
+	AssumeReady               bool     `json:"assumeReady"`
+	CompareContents           bool     `json:"compareContents"`
+	DeleteSnapshots           bool     `json:"deleteSnapshots"`
+	DriverAllowlist           []string `json:"driverAllowlist,omitempty"`
+}
+
+type planPVC struct {
+	Replicas              int    `json:"replicas"`
+	StorageClass          string `json:"storageClass"`
+	VolumeName            string `json:"volumeName,omitempty"`
+	VolumeAttributesClass string `json:"volumeAttributesClass"`
+	Template              string `json:"template,omitempty"`
+	StrictSize            bool   `json:"strictSize"`
+	RecordProvenance      bool   `json:"recordProvenance"`
+	WaitBound             bool   `json:"waitBound"`
+	ScheduleProbePod      bool   `json:"scheduleProbePod"`
+}
+
+type planRunSettings struct {
+	DryRun          bool   `json:"dryRun"`
+	SnapshotOnly    bool   `json:"snapshotOnly"`
+	Reverse         bool   `json:"reverse"`
+	CreateNamespace bool   `json:"createNamespace"`
+	Timeout         string `json:"timeout,omitempty"`
+	Deadline        string `json:"deadline,omitempty"`
+	MaxRetries      int    `json:"maxRetries"`
+	FailFast        bool   `json:"failFast"`
+}
+
+// printPlan resolves the plan of a run from the validated flags and prints it
+// as JSON to w.
+func printPlan(w io.Writer) error {
+	p := plan{
+		RunID: runID,
+		Origin: planCluster{
+			Name:       clusterName(originKubeconfig, originContext),
+			Kubeconfig: originKubeconfig,
+			Context:    originContext,
+			As:         originAs,
+			AsGroups:   originAsGroups,
+		},
+		Destinations: []planCluster{},
+		Source: planSource{
+			Namespace:                pvcNamespace,
+			PVC:                      pvcName,
+			SourceSnapshot:           sourceSnapshot,
+			Selector:                 selector,
+			AllNamespaces:            allNamespaces,
+			StatefulSet:              statefulSet,
+			Pod:                      podName,
+			Deployment:               deployment,
+			VolumeGroupSelector:      volumeGroupSelector,
+			VolumeGroupSnapshotClass: volumeGroupSnapshotClass,
+		},
+		Naming: planNaming{
+			OriginSnapshotNameTemplate: snapshotTmpl,
+			DestSnapshotNameTemplate:   destSnapshotTmpl,
+			DestNamespace:              destNamespace,
+			NamespaceMap:               namespaceMap,
+		},
+		Snapshots: planSnapshots{
+			SnapshotClass:             orDefault(snapshotClass),
+			DestSnapshotClass:         orDefault(destSnapClass),
+			SelectDestClassBy:         selectDestClass,
+			DestSnapshotClassSelector: destClassSelector,
+			DestDriver:                destDriver,
+			DestDeletionSecret:        deletionSecret,
+			ProtectOrigin:             protectOrigin,
+			WaitFor:                   waitFor,
+			WaitForContentReady:       waitContentReady,
+			AssumeReady:               assumeReady,
+			CompareContents:           compareContents,
+			DeleteSnapshots:           deleteSnapshots,
+			DriverAllowlist:           driverAllowlist,
+		},
+		Run: planRunSettings{
+			DryRun:          dryRun,
+			SnapshotOnly:    snapshotOnly,
+			Reverse:         reverse,
+			CreateNamespace: createNamespace,
+			Deadline:        deadline,
+			MaxRetries:      maxRetries,
+			FailFast:        failFast,
+		},
+	}
+	if deadline == "" {
+		p.Run.Timeout = timeout.String()
+	}
+	if p.Snapshots.DestSnapshotClass == "default" && selectDestClass == classSelectCopy && destClassSelector == "" {
+		p.Snapshots.DestSnapshotClass = "origin class"
+	}
+
+	switch {
+	case snapshotOnly:
+		// No destination is touched
+	case destKubeSecret != "":
+		p.Destinations = append(p.Destinations, planCluster{Name: "destination", KubeconfigSecret: destKubeSecret})
+	case fleetFile != "":
+		f, err := loadFleet(fleetFile)
+		if err != nil {
+			return err
+		}
+		for _, fd := range f.Destinations {
+			p.Destinations = append(p.Destinations, planCluster{
+				Name:           fd.Name,
+				Kubeconfig:     fd.Kubeconfig,
+				Context:        fd.Context,
+				Namespace:      fd.Namespace,
+				SnapshotClass:  fd.SnapshotClass,
+				StorageClasses: fd.StorageClasses,
+			})
+		}
+	default:
+		targets, err := destinationTargets(destKubeconfigs, destContexts)
+		if err != nil {
+			return err
+		}
+		for _, t := range targets {
+			p.Destinations = append(p.Destinations, planCluster{Name: t.name, Kubeconfig: t.kubeconfig, Context: t.context})
+		}
+	}
+	for i := range p.Destinations {
+		p.Destinations[i].As = destAs
+		p.Destinations[i].AsGroups = destAsGroups
+	}
+
+	if pvcName != "" || sourceSnapshot != "" {
+		m := newMigration(pvcNamespace, pvcName)
+		if sourceSnapshot != "" {
+			m.snapshotName = sourceSnapshot
+			if destSnapshotName == "" {
+				m.destSnapshotName = sourceSnapshot
+			}
+		}
+		if pvcName != "" {
+			if err := applySnapshotNameTemplates([]*migration{m}); err != nil {
+				return err
+			}
+		}
+		p.Migration = &planMigration{
+			SnapshotName:     m.snapshotName,
+			DestNamespace:    m.destNamespace,
+			DestSnapshotName: m.destSnapshotName,
+		}
+		if createPVC && m.destPVCName != "" {
+			p.Migration.DestPVCNamespace = m.destPVCNamespace
+			p.Migration.DestPVCNames = m.destPVCNames()
+			p.Migration.CopySnapshot = m.copiesSnapshot()
+		}
+	}
+
+	if createPVC {
+		p.PVC = &planPVC{
+			Replicas:              replicas,
+			StorageClass:          "source PVC's",
+			VolumeName:            destVolumeName,
+			VolumeAttributesClass: "source PVC's",
+			Template:              pvcTemplateFile,
+			StrictSize:            strictSize,
+			RecordProvenance:      recordProvenance,
+			WaitBound:             waitPVC,
+			ScheduleProbePod:      scheduleProbePod,
+		}
+		switch {
+		case pvcTemplate != nil && pvcTemplate.Spec.StorageClassName != nil:
+			p.PVC.StorageClass = *pvcTemplate.Spec.StorageClassName
+		case destStorageClass != "":
+			p.PVC.StorageClass = destStorageClass
+		case destVolumeName != "":
+			p.PVC.StorageClass = "dest volume's"
+		}
+		if destVolumeAttributesClass != "" {
+			p.PVC.VolumeAttributesClass = destVolumeAttributesClass
+		}
+	}
+
+	return newJSONEncoder(w).Encode(p)
+}
+
+// orDefault returns name, or "default" for an unset class.
+func orDefault(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
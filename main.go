@@ -2,35 +2,175 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
 	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 var (
 	originKubeconfig string
-	destKubeconfig   string
+	destKubeconfigs  []string
 	originContext    string
-	destContext      string
+	destContexts     []string
 	pvcName          string
 	pvcNamespace     string
 	snapshotName     string
+	snapshotTmpl     string
 	destSnapshotName string
+	destSnapshotTmpl string
 	createPVC        bool
 	destPVCName      string
 	destNamespace    string
+	destPVCNamespace string
+	copySnapshot     bool
 	createNamespace  bool
 	deleteSnapshots  bool
 	snapshotClass    string
 	timeout          time.Duration
+	timeoutGrace     time.Duration
+	strictSize       bool
+	waitFor          string
+	selector         string
+	allNamespaces    bool
+	namespaceMap     map[string]string
+	assumeYes        bool
+	otelEndpoint     string
+	destVolumeName   string
+	destStorageClass string
+	pvcTemplateFile  string
+	sourceSnapshot   string
+	scheduleProbePod bool
+	protectOrigin    bool
+	originReadOnly   bool
+	destKubeSecret   string
+	deletionSecret   string
+	throttle         time.Duration
+	snapshotRateSpec string
+	dryRun           bool
+	inspectOnly      bool
+	pretty           bool
+	prettySet        bool
+	destSnapClass    string
+	selectDestClass  string
+	snapshotOnly     bool
+
+	cleanupOriginOnDestFailure bool
+	destVolumeAttributesClass  string
+	assumeReady                bool
+	skipWaitOrigin             bool
+	compareContents            bool
+	printCommands              bool
+	failFast                   bool
+	waitContentReady           bool
+	readinessStableFor         time.Duration
+	pinBindingUID              bool
+	reuseInProgress            bool
+	serverSideApply            bool
+	maxAPICalls                int
+	recordProvenance           bool
+	annotateSource             bool
+	statefulSet                string
+	podName                    string
+	deployment                 string
+	deadline                   string
+	cleanupReport              string
+	fleetFile                  string
+	destDriver                 string
+	preSnapshotJobFile         string
+	postSnapshotJobFile        string
+	keepHookJobs               bool
+	pushgateway                string
+	reverse                    bool
+	failOnDriverMismatch       bool
+	summaryOnly                bool
+	maxRetries                 int
+	retryBaseDelay             time.Duration
+	retryMaxDelay              time.Duration
+	retryFactor                float64
+	volumeGroupSelector        string
+	volumeGroupSnapshotClass   string
+	destClassSelector          string
+	replicas                   int
+	waitPVC                    bool
+	progressBar                bool
+	contentDeletionWait        time.Duration
+	resultFile                 string
+	bindingTimeout             time.Duration
+	resultsOutput              string
+	checkQuota                 bool
+	updateExistingSnapshot     bool
+	driverAllowlist            []string
+	handleTransform            string
+	transformHandle            handleTransformer
+	waitTimeoutAction          string
+	cleanupScope               string
+	originTLSServerName        string
+	destTLSServerName          string
+	originAs                   string
+	originAsGroups             []string
+	destAs                     string
+	destAsGroups               []string
+	maxSnapshotSizeSpec        string
+	classMapFile               string
+	readOnly                   bool
+	summaryOut                 string
+	pauseBeforeDest            bool
+	stuckBindingRetries        int
+	stuckBindingThreshold      time.Duration
+	manifestsOut               string
+	requireSnapshotController  bool
+	excludePVCs                []string
+	excludeSelector            string
+	stateFile                  string
+	resumeFromState            string
+)
+
+// runStart is when the run started, and runID identifies the run in the
+// default snapshot names of every PVC.
+var (
+	runStart = time.Now()
+	runID    = newRunID(runStart)
+)
+
+// newRunID returns the Unix time followed by a short random suffix, so that
+// runs started within the same second, on one machine or several, still get
+// different snapshot names. It is a valid part of an object name.
+func newRunID(now time.Time) string {
+	return fmt.Sprintf("%d-%s", now.Unix(), utilrand.String(5))
+}
+
+// pollInterval is how often the objects snapshift waits for are checked.
+var pollInterval = 5 * time.Second
+
+// Snapshot wait modes accepted by --wait-for
+const (
+	waitForCreated = "created"
+	waitForReady   = "ready"
 )
 
 var rootCmd = &cobra.Command{
@@ -39,208 +179,1548 @@ var rootCmd = &cobra.Command{
 	Long: `snapshift is a CLI tool that creates a snapshot of a PVC in an origin cluster,
 replicates the snapshot to a destination cluster (using the same underlying storage),
 and optionally creates a PVC from the snapshot in the destination cluster.`,
-	RunE: runSnapshift,
+	PersistentPreRunE: validateClientFlags,
+	PreRunE:           validateQuantityFlags,
+	RunE:              runSnapshift,
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&pretty, "pretty", false, "Indent JSON output (the default on a terminal); --pretty=false prints it on a single line")
+	rootCmd.PersistentFlags().StringVar(&originKubeconfig, "origin-kubeconfig", "", "Path to origin cluster kubeconfig, or several colon-separated paths to merge (defaults to KUBECONFIG or ~/.kube/config)")
+	rootCmd.PersistentFlags().StringArrayVar(&destKubeconfigs, "dest-kubeconfig", nil, "Path to destination cluster kubeconfig (defaults to same as origin, repeatable for multiple destinations)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Named origin and destination cluster pair from --profiles-file, giving the defaults of the cluster, namespace and snapshot class flags")
+	rootCmd.PersistentFlags().StringVar(&profilesFile, "profiles-file", "", "YAML file of the --profile cluster pairs (default ~/.snapshift/profiles.yaml)")
+	rootCmd.PersistentFlags().StringVar(&originContext, "origin-context", "", "Origin cluster context name")
+	rootCmd.PersistentFlags().StringArrayVar(&destContexts, "dest-context", nil, "Destination cluster context name (repeatable for multiple destinations)")
+	rootCmd.PersistentFlags().StringVar(&originTLSServerName, "origin-tls-server-name", "", "Server name to verify the origin API server certificate against, when it differs from the endpoint host (e.g. behind a proxy); does not change the address connected to")
+	rootCmd.PersistentFlags().StringVar(&originAs, "origin-as", "", "User or service account (system:serviceaccount:<namespace>:<name>) to impersonate in the origin cluster, like kubectl --as")
+	rootCmd.PersistentFlags().StringArrayVar(&originAsGroups, "origin-as-group", nil, "Group to impersonate in the origin cluster, with --origin-as (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&destAs, "dest-as", "", "User or service account (system:serviceaccount:<namespace>:<name>) to impersonate in the destination clusters, like kubectl --as")
+	rootCmd.PersistentFlags().StringArrayVar(&destAsGroups, "dest-as-group", nil, "Group to impersonate in the destination clusters, with --dest-as (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&destTLSServerName, "dest-tls-server-name", "", "Server name to verify the destination API server certificates against, when it differs from the endpoint host; does not change the address connected to")
+	rootCmd.Flags().StringVar(&sourceSnapshot, "source-snapshot", "", "Replicate this existing VolumeSnapshot in --namespace instead of snapshotting a PVC")
+	rootCmd.Flags().StringVarP(&pvcName, "pvc", "p", "", "Name of the PVC to snapshot, or namespace/name to override --namespace (required unless --selector or --all-namespaces is set)")
+	rootCmd.PersistentFlags().IntVar(&maxAPICalls, "max-concurrent-api-calls", 0, "Maximum number of API requests in flight across all clusters (0 for no limit)")
+	rootCmd.PersistentFlags().StringVarP(&pvcNamespace, "namespace", "n", "default", "Namespace of the source PVC")
+	rootCmd.Flags().StringVar(&snapshotName, "snapshot-name", "", "Name for the snapshot (defaults to <pvc-name>-snapshot-<unix-time>-<random>)")
+	rootCmd.Flags().StringVar(&snapshotTmpl, "origin-snapshot-name-template", "", "Go template naming the origin snapshot of each PVC, with the same placeholders as --dest-snapshot-name-template")
+	rootCmd.Flags().StringVar(&destSnapshotName, "dest-snapshot-name", "", "Name for destination snapshot (defaults to same as origin)")
+	rootCmd.Flags().StringVar(&destSnapshotTmpl, "dest-snapshot-name-template", "", "Go template naming the destination snapshot of each PVC, with the placeholders {{.PVC}}, {{.Namespace}}, {{.Timestamp}} and {{.RunID}}")
+	rootCmd.Flags().BoolVar(&createPVC, "create-pvc", false, "Create a PVC from the snapshot in destination cluster")
+	rootCmd.Flags().BoolVar(&readOnly, "readonly", false, "Restore the destination PVC as ReadOnlyMany, for many workloads to mount read-only (requires --create-pvc and a driver supporting ReadOnlyMany)")
+	rootCmd.Flags().IntVar(&replicas, "replicas", 1, "Number of destination PVCs to restore from the snapshot, named <dest-pvc-name>-<index> when more than one")
+	rootCmd.Flags().BoolVar(&waitPVC, "wait-pvc", false, "Wait for the destination PVCs to be bound")
+	rootCmd.Flags().StringVar(&destPVCName, "dest-pvc-name", "", "Name for the destination PVC (defaults to same as source PVC)")
+	rootCmd.PersistentFlags().StringVar(&destNamespace, "dest-namespace", "", "Destination namespace (defaults to same as source)")
+	rootCmd.Flags().StringVar(&destPVCNamespace, "dest-pvc-namespace", "", "Namespace of the destination PVC, if not the destination snapshot's (requires --copy-snapshot-to-pvc-namespace)")
+	rootCmd.Flags().BoolVar(&copySnapshot, "copy-snapshot-to-pvc-namespace", false, "Also bind a copy of the destination snapshot in --dest-pvc-namespace, since a PVC can only be restored from a snapshot in its own namespace")
+	rootCmd.Flags().BoolVar(&createNamespace, "create-namespace", false, "Create destination namespace if it does not exist")
+	rootCmd.Flags().BoolVar(&deleteSnapshots, "delete-snapshots", false, "Delete snapshots after PVC is created (only with --create-pvc)")
+	rootCmd.Flags().StringVar(&snapshotClass, "snapshot-class", "", "VolumeSnapshotClass name (optional, uses default if not specified)")
+	rootCmd.Flags().StringVar(&maxSnapshotSizeSpec, "max-snapshot-size", "", "Refuse to migrate a PVC whose storage request is larger than this quantity (e.g. 500Gi), as a guard against pointing at the wrong volume (default no limit)")
+	rootCmd.Flags().StringSliceVar(&driverAllowlist, "driver-allowlist", nil, "Comma-separated CSI drivers snapshift may snapshot volumes of (default any driver)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 10*time.Minute, "Timeout for snapshot operations")
+	rootCmd.Flags().DurationVar(&timeoutGrace, "timeout-grace", 2*time.Minute, "Time cleanup after a failure gets on its own, even once --timeout or --deadline has passed")
+	rootCmd.Flags().StringVar(&waitTimeoutAction, "wait-timeout-action", timeoutActionFail, "What to do when a snapshot is not ready within --timeout: fail (clean up) or leave (keep the snapshot, which may still become ready, and exit with status 3)")
+	rootCmd.PersistentFlags().DurationVar(&contentDeletionWait, "wait-for-content-deletion", 0, "When cleanup deletes a VolumeSnapshotContent, or a snapshot whose content has the Delete policy, wait up to this long for the content to be gone and report it if stuck (0 to not wait)")
+	rootCmd.Flags().StringVar(&deadline, "deadline", "", "Absolute time the whole run must finish by, as RFC3339 (instead of --timeout)")
+	rootCmd.Flags().IntVar(&stuckBindingRetries, "retry-stuck-binding", 0, "Recreate a destination snapshot and its VolumeSnapshotContent not ready within --stuck-binding-threshold, up to this many times (0 to wait out --timeout)")
+	rootCmd.Flags().DurationVar(&stuckBindingThreshold, "stuck-binding-threshold", 2*time.Minute, "How long a destination snapshot may take to be ready before --retry-stuck-binding recreates it")
+	rootCmd.Flags().DurationVar(&bindingTimeout, "content-binding-timeout", 0, "Fail if a destination snapshot is not bound to its VolumeSnapshotContent within this time, instead of only at --timeout (0 to not check)")
+	rootCmd.Flags().StringVar(&waitFor, "wait-for", waitForReady, "Origin snapshot state to wait for before replicating: created or ready")
+	rootCmd.Flags().BoolVar(&strictSize, "strict-size", false, "Fail if the PVC request is smaller than the snapshot restore size instead of increasing it")
+	rootCmd.Flags().StringVarP(&selector, "selector", "l", "", "Label selector to migrate every matching PVC in the namespace")
+	rootCmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "Migrate matching PVCs from all namespaces")
+	rootCmd.Flags().StringToStringVar(&namespaceMap, "namespace-map", nil, "Map source to destination namespaces (e.g. prod=dr,apps=apps-dr)")
+	rootCmd.Flags().StringArrayVar(&excludePVCs, "exclude-pvc", nil, "In bulk migrations, skip this PVC, as name or namespace/name (repeatable)")
+	rootCmd.Flags().StringVar(&excludeSelector, "exclude-selector", "", "In bulk migrations, skip the PVCs matching this label selector")
+	rootCmd.Flags().StringVar(&statefulSet, "statefulset", "", "Migrate the PVCs of every replica of this StatefulSet in --namespace")
+	rootCmd.Flags().StringVar(&podName, "pod", "", "Migrate the PVCs mounted by this Pod in --namespace")
+	rootCmd.Flags().StringVar(&deployment, "deployment", "", "Migrate the PVCs mounted by the pods of this Deployment in --namespace")
+	rootCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before large bulk migrations")
+	rootCmd.Flags().BoolVar(&updateExistingSnapshot, "update-existing-snapshot", false, "Replace a destination snapshot and content of the same names left by a previous run for another snapshot handle, instead of failing")
+	rootCmd.Flags().BoolVar(&checkQuota, "check-quota", false, "Before replicating, fail if the destination namespace's ResourceQuotas lack the storage request headroom for the destination PVCs (only with --create-pvc)")
+	rootCmd.Flags().StringVar(&destVolumeName, "dest-volume-name", "", "Bind the destination PVC to this pre-created PersistentVolume (only with --create-pvc)")
+	rootCmd.Flags().BoolVar(&scheduleProbePod, "schedule-probe-pod", false, "Run a pause pod using each destination PVC of a WaitForFirstConsumer storage class so it binds, then delete it (only with --create-pvc)")
+	rootCmd.Flags().StringVar(&pvcTemplateFile, "pvc-template", "", "PVC manifest whose values override the ones copied from the source PVC; the data source is always the destination snapshot (only with --create-pvc and --pvc)")
+	rootCmd.Flags().StringVar(&destStorageClass, "dest-storage-class", "", "Storage class of the destination PVC instead of the source PVC's; its provisioner must be the snapshot's driver (only with --create-pvc)")
+	rootCmd.Flags().BoolVar(&originReadOnly, "origin-read-only", false, "Refuse any request that would change the origin cluster; requires --source-snapshot, since no origin snapshot can be taken")
+	rootCmd.Flags().BoolVar(&protectOrigin, "protect-origin", false, "Patch the origin VolumeSnapshotContent to Retain so deleting the origin snapshot keeps the shared storage snapshot")
+	rootCmd.Flags().StringVar(&classMapFile, "class-map-file", "", "YAML file mapping CSI drivers to the VolumeSnapshotClass to use in the origin and destination clusters")
+	rootCmd.Flags().StringVar(&fleetFile, "fleet", "", "YAML file listing the destination clusters, with per-cluster namespace and class overrides")
+	rootCmd.Flags().StringVar(&deletionSecret, "dest-deletion-secret", "", "Secret the destination snapshot controller uses to delete the storage snapshot of the destination content (namespace/name)")
+	rootCmd.Flags().StringVar(&destKubeSecret, "dest-kubeconfig-secret", "", "Read the destination kubeconfig from a Secret in the origin cluster (namespace/name[/key])")
+	rootCmd.Flags().DurationVar(&throttle, "throttle-between-pvcs", 0, "Delay between starting each PVC migration in bulk mode, to spread load on the storage backend")
+	rootCmd.Flags().StringVar(&snapshotRateSpec, "storage-snapshot-rate", "", "Maximum rate of origin snapshot creations across all PVCs, as N/duration (e.g. 10/1m), to stay within storage backend quotas")
+	rootCmd.Flags().StringVar(&destSnapClass, "dest-snapshot-class", "", "VolumeSnapshotClass for the destination content and snapshot (defaults to the origin class)")
+	rootCmd.Flags().StringVar(&selectDestClass, "select-dest-class-by", classSelectCopy, "How to pick the destination VolumeSnapshotClass: copy (the origin class name) or driver (a destination class for the same driver)")
+	rootCmd.Flags().StringVar(&handleTransform, "handle-transform", "", "Rewrite the origin snapshot handle for the destination contents, with a from=to regular expression replacement or exec:<command> printing the new handle")
+	rootCmd.Flags().StringVar(&destDriver, "dest-driver", "", "CSI driver for the destination VolumeSnapshotContent instead of the origin content's driver (escape hatch, can break handle interpretation)")
+	rootCmd.Flags().StringVar(&destClassSelector, "dest-snapshot-class-selector", "", "Label selector picking the destination VolumeSnapshotClass for the origin driver")
+	rootCmd.Flags().BoolVar(&snapshotOnly, "snapshot-only", false, "Only create the origin snapshot and wait for it, without touching any destination cluster")
+	rootCmd.Flags().StringVar(&cleanupScope, "cleanup-scope", cleanupScopeBoth, "Side cleanup after a failure deletes resources from: dest (keep the origin snapshot, e.g. for other destinations), origin, or both")
+	rootCmd.Flags().BoolVar(&cleanupOriginOnDestFailure, "cleanup-origin-on-dest-failure", false, "Delete the origin snapshot when every destination fails, even if it is retained and could be reused")
+	rootCmd.Flags().StringVar(&destVolumeAttributesClass, "dest-volume-attributes-class", "", "VolumeAttributesClass for the destination PVC (defaults to the source PVC's class, only with --create-pvc)")
+	rootCmd.Flags().BoolVar(&waitContentReady, "wait-for-content-ready", true, "Also wait for the origin VolumeSnapshotContent to be ready before reading its snapshot handle")
+	rootCmd.Flags().IntVar(&maxTotalSnapshots, "max-total-snapshots", 0, "Create at most this many origin snapshots in the run, and do not start the PVCs past it, as a guard against a selector matching far more volumes than meant (0 for no limit)")
+	rootCmd.Flags().BoolVar(&serverSideApply, "server-side-apply", false, "Create the destination VolumeSnapshotContent, VolumeSnapshot and PVCs with server-side apply, as field manager \"snapshift\", so re-runs update them and fields owned by other managers are conflicts")
+	rootCmd.Flags().BoolVar(&reuseInProgress, "reuse-in-progress", false, "When the source PVC already has a snapshot that is not ready yet, wait for it and replicate its handle instead of taking another")
+	rootCmd.Flags().BoolVar(&pinBindingUID, "pin-binding-uid", false, "Set the destination snapshot's UID in the snapshot reference of its VolumeSnapshotContent once created, so a later snapshot reusing the name cannot bind to it")
+	rootCmd.Flags().DurationVar(&readinessStableFor, "readiness-stable-for", 0, "Only consider a snapshot ready once it has reported ReadyToUse continuously for this long, for drivers that briefly flip it while finalizing (0 to trust the first ready)")
+	rootCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop a bulk migration at the first failed PVC instead of migrating the rest and reporting all failures at the end")
+	rootCmd.Flags().BoolVar(&printCommands, "print-equivalent-commands", false, "Print the kubectl commands that would do the same as each step, with the manifests snapshift creates")
+	rootCmd.Flags().BoolVar(&compareContents, "compare-contents", false, "Read the destination VolumeSnapshotContent back and fail if its snapshot handle or driver differ from the origin's, e.g. after a mutating webhook changed it")
+	rootCmd.Flags().BoolVar(&assumeReady, "assume-ready", false, "Do not wait for the destination snapshot to be ready before creating the PVC (readiness is not verified)")
+	rootCmd.Flags().BoolVar(&skipWaitOrigin, "skip-wait-origin", false, "Fail instead of waiting if the --source-snapshot or its content is not ready yet")
+	rootCmd.Flags().BoolVar(&recordProvenance, "record-provenance", true, "Annotate the destination PVC with its source PVC, snapshot handle and migration time")
+	rootCmd.Flags().BoolVar(&annotateSource, "annotate-source-pvc", false, "Annotate the source PVC with where and when it was last migrated, once the migration succeeds")
+	rootCmd.Flags().StringVar(&cleanupReport, "cleanup-report", "", "Append a JSON Lines record of every resource cleanup deletes or keeps to this file")
+	rootCmd.Flags().StringVar(&resultFile, "result-file", "", "Append a JSON Lines record of what each migration created, or got to before failing, to this file")
+	rootCmd.Flags().StringVar(&preSnapshotJobFile, "pre-snapshot-job", "", "Job manifest to run in the origin cluster before each snapshot, aborting the migration if it fails")
+	rootCmd.Flags().StringVar(&postSnapshotJobFile, "post-snapshot-job", "", "Job manifest to run in the origin cluster once each snapshot is taken")
+	rootCmd.Flags().BoolVar(&keepHookJobs, "keep-hook-jobs", false, "Keep completed hook Jobs instead of deleting them")
+	rootCmd.Flags().BoolVar(&reverse, "reverse", false, "Swap the origin and destination roles, to fail back with the same flags used to fail over")
+	rootCmd.Flags().BoolVar(&failOnDriverMismatch, "fail-on-driver-config-mismatch", false, "Fail instead of warning when the destination CSIDriver is missing or configured differently from the origin")
+	rootCmd.Flags().BoolVar(&progressBar, "progress-bar", false, "Show snapshot waits as a progress bar against the timeout, updated in place on a terminal, instead of periodic status lines")
+	rootCmd.Flags().BoolVar(&summaryOnly, "summary-only", false, "In bulk migrations, show a progress counter and a results table instead of the output of every step")
+	rootCmd.Flags().StringVarP(&resultsOutput, "output", "o", "", "How bulk migrations report their results: table, text to only list failures, or json, which moves the progress output to stderr (default table on a terminal, text otherwise)")
+	rootCmd.Flags().BoolVar(&pauseBeforeDest, "pause-before-dest", false, "Once the origin snapshot is ready, print its handle and wait for Enter before creating destination resources (requires stdin to be a terminal)")
+	rootCmd.Flags().BoolVar(&requireSnapshotController, "require-snapshot-controller", false, "Fail instead of warning when no running snapshot controller Deployment is found in a destination cluster")
+	rootCmd.Flags().StringVar(&manifestsOut, "manifests-out", "", "Write the destination VolumeSnapshotContents, VolumeSnapshots and PVCs a migration created, as read back from the cluster, as YAML files under this directory")
+	rootCmd.Flags().StringVar(&stateFile, "state-file", "", "Record the progress of every PVC of a bulk migration in this file, rewritten after each PVC, for --resume-from-state")
+	rootCmd.Flags().StringVar(&resumeFromState, "resume-from-state", "", "Resume the bulk migration recorded in this --state-file: skip the PVCs it completed and retry the others, reusing the resources they left (the file keeps being updated unless --state-file is given)")
+	rootCmd.Flags().StringVar(&summaryOut, "summary-out", "", "Write the results of a bulk migration, in the --output format (default table), to this file instead of stdout")
+	rootCmd.Flags().IntVar(&maxRetries, "max-retries", 3, "Times to retry API requests the server rejects as overloaded or unavailable, and to recreate the destination content and snapshot when a controller deletes the content before the snapshot binds")
+	rootCmd.Flags().DurationVar(&retryBaseDelay, "retry-base-delay", time.Second, "Delay before the first retry")
+	rootCmd.Flags().DurationVar(&retryMaxDelay, "retry-max-delay", 30*time.Second, "Maximum delay between retries")
+	rootCmd.Flags().Float64Var(&retryFactor, "retry-factor", 2, "Factor the delay between retries grows by on each retry")
+	rootCmd.Flags().StringVar(&volumeGroupSelector, "volume-group-selector", "", "Label selector of PVCs in --namespace to snapshot together with one VolumeGroupSnapshot")
+	rootCmd.Flags().StringVar(&volumeGroupSnapshotClass, "volume-group-snapshot-class", "", "VolumeGroupSnapshotClass for --volume-group-selector")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the objects that would be created and how they differ from the source, without changing anything")
+	rootCmd.Flags().BoolVar(&inspectOnly, "inspect-plan", false, "Print how the flags were resolved as JSON and exit, without contacting any cluster")
+	rootCmd.Flags().StringVar(&pushgateway, "pushgateway", "", "Prometheus Pushgateway URL to push the run's metrics to")
+	rootCmd.Flags().StringVar(&otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint to export traces to (defaults to OTEL_EXPORTER_OTLP_ENDPOINT, tracing disabled if unset)")
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if leftPending(err) {
+			os.Exit(exitWaitTimeout)
+		}
+		os.Exit(1)
+	}
 }
 
-func init() {
-	rootCmd.Flags().StringVar(&originKubeconfig, "origin-kubeconfig", "", "Path to origin cluster kubeconfig (defaults to KUBECONFIG or ~/.kube/config)")
-	rootCmd.Flags().StringVar(&destKubeconfig, "dest-kubeconfig", "", "Path to destination cluster kubeconfig (defaults to same as origin)")
-	rootCmd.Flags().StringVar(&originContext, "origin-context", "", "Origin cluster context name")
-	rootCmd.Flags().StringVar(&destContext, "dest-context", "", "Destination cluster context name")
-	rootCmd.Flags().StringVarP(&pvcName, "pvc", "p", "", "Name of the PVC to snapshot (required)")
-	rootCmd.Flags().StringVarP(&pvcNamespace, "namespace", "n", "default", "Namespace of the source PVC")
-	rootCmd.Flags().StringVar(&snapshotName, "snapshot-name", "", "Name for the snapshot (defaults to <pvc-name>-snapshot-<timestamp>)")
-	rootCmd.Flags().StringVar(&destSnapshotName, "dest-snapshot-name", "", "Name for destination snapshot (defaults to same as origin)")
-	rootCmd.Flags().BoolVar(&createPVC, "create-pvc", false, "Create a PVC from the snapshot in destination cluster")
-	rootCmd.Flags().StringVar(&destPVCName, "dest-pvc-name", "", "Name for the destination PVC (defaults to same as source PVC)")
-	rootCmd.Flags().StringVar(&destNamespace, "dest-namespace", "", "Destination namespace (defaults to same as source)")
-	rootCmd.Flags().BoolVar(&createNamespace, "create-namespace", false, "Create destination namespace if it does not exist")
-	rootCmd.Flags().BoolVar(&deleteSnapshots, "delete-snapshots", false, "Delete snapshots after PVC is created (only with --create-pvc)")
-	rootCmd.Flags().StringVar(&snapshotClass, "snapshot-class", "", "VolumeSnapshotClass name (optional, uses default if not specified)")
-	rootCmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "Timeout for snapshot operations")
+func runSnapshift(cmd *cobra.Command, args []string) error {
+	prettySet = cmd.Flags().Changed("pretty")
+
+	// Keep the plan alone on stdout, progress and warnings go to stderr
+	defer func(out io.Writer) { stepOut = out }(stepOut)
+	if inspectOnly {
+		stepOut = os.Stderr
+	}
+
+	if pvcName != "" {
+		var err error
+		if pvcNamespace, pvcName, err = parsePVCRef("pvc", pvcName, pvcNamespace); err != nil {
+			return err
+		}
+	}
+
+	if reverse {
+		if err := reverseRoles(); err != nil {
+			return err
+		}
+	}
+
+	if waitFor != waitForCreated && waitFor != waitForReady {
+		return fmt.Errorf("invalid --wait-for value %q: must be %q or %q", waitFor, waitForCreated, waitForReady)
+	}
+	if waitTimeoutAction != timeoutActionFail && waitTimeoutAction != timeoutActionLeave {
+		return fmt.Errorf("invalid --wait-timeout-action value %q: must be %q or %q", waitTimeoutAction, timeoutActionFail, timeoutActionLeave)
+	}
+	if err := validateCleanupScope(); err != nil {
+		return err
+	}
+
+	if err := validateRetryBackoff(); err != nil {
+		return err
+	}
+	if maxAPICalls < 0 {
+		return fmt.Errorf("--max-concurrent-api-calls must not be negative")
+	}
+	if maxTotalSnapshots < 0 {
+		return fmt.Errorf("--max-total-snapshots must not be negative")
+	}
+
+	if selectDestClass != classSelectCopy && selectDestClass != classSelectDriver {
+		return fmt.Errorf("invalid --select-dest-class-by value %q: must be %q or %q", selectDestClass, classSelectCopy, classSelectDriver)
+	}
+	if destSnapClass != "" && selectDestClass != classSelectCopy {
+		return fmt.Errorf("--dest-snapshot-class cannot be combined with --select-dest-class-by")
+	}
+	if destClassSelector != "" && (destSnapClass != "" || selectDestClass != classSelectCopy) {
+		return fmt.Errorf("--dest-snapshot-class-selector cannot be combined with --dest-snapshot-class or --select-dest-class-by")
+	}
+
+	workloads := 0
+	for _, name := range []string{statefulSet, podName, deployment} {
+		if name != "" {
+			workloads++
+		}
+	}
+	if workloads > 1 {
+		return fmt.Errorf("only one of --statefulset, --pod and --deployment can be given")
+	}
+	workload := workloads > 0
+	if workload && (selector != "" || allNamespaces) {
+		return fmt.Errorf("--statefulset, --pod and --deployment cannot be combined with --selector or --all-namespaces")
+	}
+	if volumeGroupSelector != "" {
+		if pvcName != "" || selector != "" || allNamespaces || workload {
+			return fmt.Errorf("--volume-group-selector cannot be combined with --pvc, --selector, --all-namespaces, --statefulset, --pod or --deployment")
+		}
+		if snapshotName != "" || snapshotTmpl != "" || destSnapshotName != "" || destSnapshotTmpl != "" || destPVCName != "" || destVolumeName != "" || replicas > 1 {
+			return fmt.Errorf("--snapshot-name, --dest-snapshot-name, their templates, --dest-pvc-name, --dest-volume-name and --replicas cannot be used with --volume-group-selector")
+		}
+		if dryRun || snapshotOnly || summaryOnly || deleteSnapshots || pauseBeforeDest {
+			return fmt.Errorf("--volume-group-selector cannot be combined with --dry-run, --snapshot-only, --summary-only, --delete-snapshots or --pause-before-dest")
+		}
+		if preSnapshotJobFile != "" || postSnapshotJobFile != "" || resultFile != "" || waitFor != waitForReady || waitTimeoutAction != timeoutActionFail {
+			return fmt.Errorf("--volume-group-selector cannot be combined with snapshot hook Jobs, --result-file, --wait-for %s or --wait-timeout-action %s, which group snapshots do not support", waitForCreated, timeoutActionLeave)
+		}
+	} else if volumeGroupSnapshotClass != "" {
+		return fmt.Errorf("--volume-group-snapshot-class requires --volume-group-selector")
+	}
+	group := volumeGroupSelector != ""
+
+	bulk := selector != "" || allNamespaces || workload
+	if bulk && pvcName != "" {
+		return fmt.Errorf("--pvc cannot be combined with --selector, --all-namespaces, --statefulset, --pod or --deployment")
+	}
+	// Likewise keep a JSON summary alone on stdout
+	if bulk && resultsOutput == outputJSON && summaryOut == "" {
+		stepOut = os.Stderr
+	}
+	if sourceSnapshot != "" {
+		if pvcName != "" || bulk || group {
+			return fmt.Errorf("--source-snapshot cannot be combined with --pvc, --selector, --all-namespaces, --statefulset, --pod, --deployment or --volume-group-selector")
+		}
+		if snapshotName != "" || snapshotTmpl != "" || snapshotOnly || dryRun || preSnapshotJobFile != "" || postSnapshotJobFile != "" {
+			return fmt.Errorf("--source-snapshot cannot be combined with --snapshot-name, --origin-snapshot-name-template, --snapshot-only, --dry-run or snapshot hook Jobs, since no snapshot is taken")
+		}
+	}
+	if originReadOnly {
+		if sourceSnapshot == "" {
+			return fmt.Errorf("--origin-read-only requires --source-snapshot, since taking an origin snapshot writes to the origin cluster")
+		}
+		if protectOrigin || annotateSource {
+			return fmt.Errorf("--origin-read-only cannot be combined with --protect-origin or --annotate-source-pvc, which write to the origin cluster")
+		}
+	}
+	if reuseInProgress && (sourceSnapshot != "" || preSnapshotJobFile != "" || postSnapshotJobFile != "") {
+		return fmt.Errorf("--reuse-in-progress cannot be combined with --source-snapshot or snapshot hook Jobs, which would not run around a reused snapshot")
+	}
+	if skipWaitOrigin && sourceSnapshot == "" {
+		return fmt.Errorf("--skip-wait-origin requires --source-snapshot, a snapshot snapshift creates is never ready right away")
+	}
+	if !bulk && !group && pvcName == "" && sourceSnapshot == "" {
+		return fmt.Errorf("either --pvc, --source-snapshot, --selector/--all-namespaces, --statefulset, --pod, --deployment or --volume-group-selector must be specified")
+	}
+	if bulk && (snapshotName != "" || destSnapshotName != "" || destPVCName != "" || destVolumeName != "") {
+		return fmt.Errorf("--snapshot-name, --dest-snapshot-name, --dest-pvc-name and --dest-volume-name can only be used with --pvc")
+	}
+	if snapshotTmpl != "" {
+		if snapshotName != "" {
+			return fmt.Errorf("--origin-snapshot-name-template cannot be combined with --snapshot-name")
+		}
+		var err error
+		if originSnapshotNameTemplate, err = parseSnapshotNameTemplate("origin-snapshot-name-template", snapshotTmpl); err != nil {
+			return err
+		}
+	}
+	if destSnapshotTmpl != "" {
+		if destSnapshotName != "" {
+			return fmt.Errorf("--dest-snapshot-name-template cannot be combined with --dest-snapshot-name")
+		}
+		var err error
+		if destSnapshotNameTemplate, err = parseSnapshotNameTemplate("dest-snapshot-name-template", destSnapshotTmpl); err != nil {
+			return err
+		}
+	}
+	if progressBar && (bulk || group || fleetFile != "" || len(destKubeconfigs) > 1 || len(destContexts) > 1) {
+		return fmt.Errorf("--progress-bar only works for a single PVC and destination, whose waits are not interleaved with others")
+	}
+	if resultsOutput != "" && resultsOutput != outputTable && resultsOutput != outputText && resultsOutput != outputJSON {
+		return fmt.Errorf("invalid --output value %q: must be %q, %q or %q", resultsOutput, outputTable, outputText, outputJSON)
+	}
+	if summaryOnly && (!bulk || dryRun) {
+		return fmt.Errorf("--summary-only requires --selector, --all-namespaces, --statefulset, --pod or --deployment, and cannot be combined with --dry-run")
+	}
+	if pauseBeforeDest {
+		if dryRun || snapshotOnly || summaryOnly {
+			return fmt.Errorf("--pause-before-dest cannot be combined with --dry-run, --snapshot-only or --summary-only")
+		}
+		if !isTerminal(os.Stdin) {
+			return fmt.Errorf("--pause-before-dest requires an interactive terminal to wait for Enter")
+		}
+	}
+	if manifestsOut != "" && (dryRun || snapshotOnly) {
+		return fmt.Errorf("--manifests-out cannot be combined with --dry-run or --snapshot-only, which create no destination objects")
+	}
+	if len(excludePVCs) > 0 || excludeSelector != "" {
+		if !bulk {
+			return fmt.Errorf("--exclude-pvc and --exclude-selector require --selector, --all-namespaces, --statefulset, --pod or --deployment")
+		}
+		if _, err := parseExclusions(); err != nil {
+			return err
+		}
+	}
+	if (stateFile != "" || resumeFromState != "") && (!bulk || dryRun) {
+		return fmt.Errorf("--state-file and --resume-from-state require --selector, --all-namespaces, --statefulset, --pod or --deployment, and cannot be combined with --dry-run")
+	}
+	if summaryOut != "" && (!bulk || dryRun) {
+		return fmt.Errorf("--summary-out requires --selector, --all-namespaces, --statefulset, --pod or --deployment, and cannot be combined with --dry-run")
+	}
+	if destVolumeName != "" && !createPVC {
+		return fmt.Errorf("--dest-volume-name requires --create-pvc")
+	}
+	if updateExistingSnapshot && snapshotOnly {
+		return fmt.Errorf("--update-existing-snapshot cannot be combined with --snapshot-only, which creates no destination snapshot")
+	}
+	if checkQuota && !createPVC {
+		return fmt.Errorf("--check-quota requires --create-pvc")
+	}
+	if destPVCNamespace != "" {
+		if !createPVC || allNamespaces {
+			return fmt.Errorf("--dest-pvc-namespace requires --create-pvc and cannot be combined with --all-namespaces")
+		}
+		if errs := validation.IsDNS1123Label(destPVCNamespace); len(errs) > 0 {
+			return fmt.Errorf("invalid --dest-pvc-namespace %q: %s", destPVCNamespace, strings.Join(errs, ", "))
+		}
+		if !copySnapshot {
+			return fmt.Errorf("--dest-pvc-namespace requires --copy-snapshot-to-pvc-namespace: a PVC can only be restored from a VolumeSnapshot in its own namespace, so the destination snapshot must be copied there")
+		}
+	} else if copySnapshot {
+		return fmt.Errorf("--copy-snapshot-to-pvc-namespace requires --dest-pvc-namespace")
+	}
+	if scheduleProbePod && !createPVC {
+		return fmt.Errorf("--schedule-probe-pod requires --create-pvc")
+	}
+	if destStorageClass != "" && (!createPVC || destVolumeName != "") {
+		return fmt.Errorf("--dest-storage-class requires --create-pvc and cannot be combined with --dest-volume-name, whose class the PVC must use")
+	}
+	if replicas < 1 {
+		return fmt.Errorf("--replicas must be at least 1")
+	}
+	if replicas > 1 && !createPVC {
+		return fmt.Errorf("--replicas requires --create-pvc")
+	}
+	if replicas > 1 && destVolumeName != "" {
+		return fmt.Errorf("--replicas cannot be combined with --dest-volume-name, a PersistentVolume binds a single PVC")
+	}
+	if destVolumeAttributesClass != "" && !createPVC {
+		return fmt.Errorf("--dest-volume-attributes-class requires --create-pvc")
+	}
+	if timeoutGrace <= 0 {
+		return fmt.Errorf("--timeout-grace must be positive")
+	}
+	if stuckBindingRetries < 0 {
+		return fmt.Errorf("--retry-stuck-binding must not be negative")
+	}
+	if readinessStableFor < 0 {
+		return fmt.Errorf("--readiness-stable-for must not be negative")
+	}
+	if stuckBindingRetries > 0 {
+		if stuckBindingThreshold <= 0 {
+			return fmt.Errorf("--stuck-binding-threshold must be positive")
+		}
+		if assumeReady {
+			return fmt.Errorf("--retry-stuck-binding cannot be combined with --assume-ready, which does not wait for the destination snapshot")
+		}
+	}
+	if readOnly && !createPVC {
+		return fmt.Errorf("--readonly requires --create-pvc")
+	}
+	if destKubeSecret != "" && (len(destKubeconfigs) > 0 || len(destContexts) > 0) {
+		return fmt.Errorf("--dest-kubeconfig-secret cannot be combined with --dest-kubeconfig or --dest-context")
+	}
+	if fleetFile != "" && (len(destKubeconfigs) > 0 || len(destContexts) > 0 || destKubeSecret != "") {
+		return fmt.Errorf("--fleet cannot be combined with --dest-kubeconfig, --dest-context or --dest-kubeconfig-secret")
+	}
+	if allNamespaces && destNamespace != "" {
+		return fmt.Errorf("--dest-namespace cannot be used with --all-namespaces, use --namespace-map instead")
+	}
+
+	if snapshotOnly {
+		if createPVC || dryRun || annotateSource {
+			return fmt.Errorf("--snapshot-only cannot be combined with --create-pvc, --dry-run or --annotate-source-pvc")
+		}
+		if len(destKubeconfigs) > 0 || len(destContexts) > 0 || destKubeSecret != "" || fleetFile != "" || destNamespace != "" ||
+			destSnapshotName != "" || destSnapshotTmpl != "" || destSnapClass != "" || destClassSelector != "" || destDriver != "" || len(namespaceMap) > 0 || deletionSecret != "" || handleTransform != "" {
+			return fmt.Errorf("--snapshot-only cannot be combined with destination flags")
+		}
+	}
+
+	if handleTransform != "" {
+		var err error
+		if transformHandle, err = parseHandleTransform(handleTransform); err != nil {
+			return err
+		}
+	}
+
+	if classMapFile != "" {
+		var err error
+		if classMapping, err = loadClassMap(classMapFile); err != nil {
+			return err
+		}
+	}
+
+	if resumeFromState != "" {
+		var err error
+		if resumeState, err = loadState(resumeFromState); err != nil {
+			return err
+		}
+	}
+
+	if deletionSecret != "" {
+		parts := strings.Split(deletionSecret, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid --dest-deletion-secret %q: expected namespace/name", deletionSecret)
+		}
+		deletionSecretNamespace, deletionSecretName = parts[0], parts[1]
+	}
+
+	if preSnapshotJobFile != "" {
+		var err error
+		if preSnapshotJob, err = loadHookJob(preSnapshotJobFile); err != nil {
+			return err
+		}
+	}
+	if postSnapshotJobFile != "" {
+		var err error
+		if postSnapshotJob, err = loadHookJob(postSnapshotJobFile); err != nil {
+			return err
+		}
+	}
+	if pvcTemplateFile != "" {
+		if !createPVC || bulk || group {
+			return fmt.Errorf("--pvc-template requires --create-pvc and a single --pvc")
+		}
+		if pvcTemplate.Spec.DataSource != nil || pvcTemplate.Spec.DataSourceRef != nil {
+			fmt.Fprintf(stepOut, "⚠ Warning: The data source of PVC template %s is replaced by the destination snapshot\n", pvcTemplateFile)
+		}
+		if pvcTemplate.Spec.VolumeName != "" && (destVolumeName != "" || replicas > 1) {
+			return fmt.Errorf("a PVC template setting volumeName cannot be combined with --dest-volume-name or --replicas")
+		}
+		if pvcTemplate.Spec.StorageClassName != nil && destStorageClass != "" {
+			return fmt.Errorf("a PVC template setting storageClassName cannot be combined with --dest-storage-class")
+		}
+		if len(pvcTemplate.Spec.AccessModes) > 0 && readOnly {
+			return fmt.Errorf("a PVC template setting accessModes cannot be combined with --readonly")
+		}
+	}
+
+	if destDriver != "" {
+		fmt.Fprintf(stepOut, "⚠ Warning: --dest-driver overrides the origin content's driver with %s; the destination driver must understand the origin snapshot handle or the restore will fail\n", destDriver)
+	}
+
+	var deadlineTime time.Time
+	if deadline != "" {
+		if cmd.Flags().Changed("timeout") {
+			return fmt.Errorf("--deadline cannot be combined with --timeout")
+		}
+		var err error
+		deadlineTime, err = time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			return fmt.Errorf("invalid --deadline value %q: %w", deadline, err)
+		}
+		if !deadlineTime.After(time.Now()) {
+			return fmt.Errorf("--deadline %s is already in the past", deadline)
+		}
+	}
+
+	if inspectOnly {
+		return printPlan(os.Stdout)
+	}
+
+	// Cancel in-flight work on Ctrl-C so that cleanup still runs
+	baseCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !deadlineTime.IsZero() {
+		var cancel context.CancelFunc
+		baseCtx, cancel = context.WithDeadline(baseCtx, deadlineTime)
+		defer cancel()
+		fmt.Fprintf(stepOut, "Deadline: %s (%s remaining)\n", deadlineTime.Format(time.RFC3339), remaining(baseCtx))
+	}
+
+	shutdownTracing, err := setupTracing(context.Background(), otelEndpoint)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			fmt.Fprintf(stepOut, "⚠ Warning: Failed to flush traces: %v\n", err)
+		}
+	}()
+
+	if pushgateway != "" {
+		defer pushMetrics(pushgateway)
+	}
+
+	// Create origin cluster clients
+	fmt.Fprintf(stepOut, "Connecting to origin cluster...\n")
+	originK8sClient, originSnapClient, err := createClients(originKubeconfig, originContext, originOverrides())
+	if err != nil {
+		return fmt.Errorf("failed to create origin cluster clients: %w", err)
+	}
+	if err := requireSnapshotCRDs(originK8sClient, fmt.Sprintf("origin cluster (%s)", clusterName(originKubeconfig, originContext))); err != nil {
+		return err
+	}
+
+	// Create destination cluster clients
+	var dests []*destination
+	switch {
+	case snapshotOnly:
+		// No destination is touched
+	case destKubeSecret != "":
+		dest, err := destinationFromSecret(baseCtx, originK8sClient, destKubeSecret)
+		if err != nil {
+			return err
+		}
+		dests = []*destination{dest}
+	case fleetFile != "":
+		dests, err = fleetDestinations(fleetFile)
+		if err != nil {
+			return err
+		}
+	default:
+		dests, err = createDestinations(destKubeconfigs, destContexts)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, d := range dests {
+		if err := requireSnapshotCRDs(d.k8s, fmt.Sprintf("destination cluster (%s)", d.name)); err != nil {
+			return err
+		}
+		if err := checkSnapshotController(baseCtx, d.k8s, fmt.Sprintf("destination cluster (%s)", d.name)); err != nil {
+			return err
+		}
+		d.sameAsOrigin = sameCluster(baseCtx, originK8sClient, d.k8s)
+		if d.sameAsOrigin && originReadOnly {
+			return fmt.Errorf("destination %s is the origin cluster, which --origin-read-only forbids writing to", d.name)
+		}
+	}
+
+	c := &clusterClients{
+		originK8s:  originK8sClient,
+		originSnap: originSnapClient,
+		dests:      dests,
+	}
+
+	if group {
+		ctx, cancel := migrationContext(baseCtx)
+		defer cancel()
+		return migrateVolumeGroup(ctx, c)
+	}
+
+	if !bulk {
+		ctx, cancel := migrationContext(baseCtx)
+		defer cancel()
+
+		m := newMigration(pvcNamespace, pvcName)
+		if sourceSnapshot != "" {
+			if m, err = sourceSnapshotMigration(ctx, c.originSnap); err != nil {
+				return err
+			}
+		}
+		if err := applySnapshotNameTemplates([]*migration{m}); err != nil {
+			return err
+		}
+		if err := avoidNameCollision(c, m); err != nil {
+			return err
+		}
+		if dryRun {
+			return dryRunMigration(ctx, c, m)
+		}
+		res, err := migratePVC(ctx, c, m)
+		writeResult(res)
+		return err
+	}
+
+	var migrations []*migration
+	switch {
+	case statefulSet != "":
+		migrations, err = statefulSetMigrations(baseCtx, originK8sClient)
+	case podName != "":
+		migrations, err = podMigrations(baseCtx, originK8sClient)
+	case deployment != "":
+		migrations, err = deploymentMigrations(baseCtx, originK8sClient)
+	default:
+		migrations, err = listMigrations(baseCtx, originK8sClient)
+	}
+	if err == nil {
+		migrations, err = excludeMigrations(baseCtx, originK8sClient, migrations)
+	}
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		fmt.Fprintf(stepOut, "No PVCs matched, nothing to do\n")
+		return nil
+	}
+	if err := applySnapshotNameTemplates(migrations); err != nil {
+		return err
+	}
+	var completed []*pvcState
+	if resumeState != nil {
+		migrations, completed = resumeMigrations(resumeState, migrations)
+		if len(migrations) == 0 {
+			fmt.Fprintf(stepOut, "Every PVC was already migrated, nothing to do\n")
+			return nil
+		}
+	}
+	for _, m := range migrations {
+		if err := avoidNameCollision(c, m); err != nil {
+			return err
+		}
+	}
+
+	printMigrationPlan(migrations)
+	if dryRun {
+		for _, m := range migrations {
+			fmt.Fprintf(stepOut, "\n==> %s/%s\n", m.pvcNamespace, m.pvcName)
+			if err := dryRunMigration(baseCtx, c, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(migrations) > confirmThreshold && !assumeYes {
+		confirmed, err := confirm(fmt.Sprintf("About to migrate %d PVCs. Continue?", len(migrations)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("aborted by user")
+		}
+	}
+
+	var prog *progress
+	if summaryOnly {
+		var restore func()
+		prog, restore = startSummaryOnly(len(migrations))
+		defer restore()
+	}
+
+	// A resumed run keeps updating its state file unless given another
+	statePath := stateFile
+	if statePath == "" {
+		statePath = resumeFromState
+	}
+	var progressState *runProgress
+	if statePath != "" {
+		if progressState, err = newRunProgress(statePath, completed, migrations); err != nil {
+			return err
+		}
+	}
+
+	budget := newSnapshotBudget()
+	results, failed, interrupted := migrateEach(baseCtx, c, migrations, prog, progressState, budget)
+
+	var runErr error
+	switch {
+	case interrupted != nil:
+		runErr = interrupted
+	case len(failed) > 0:
+		runErr = &partialFailureError{failed: failed, total: len(migrations)}
+	}
+	if err := budget.err(); err != nil {
+		runErr = errors.Join(runErr, err)
+	}
+
+	// The summary is written even when the run failed or was interrupted,
+	// for what was attempted
+	summary := func(w io.Writer) error {
+		return printSummary(w, summaryFormat(), results, failed, len(migrations), interrupted, budget)
+	}
+	var summaryErr error
+	if summaryOut != "" {
+		if summaryErr = writeFileAtomic(summaryOut, summary); summaryErr == nil {
+			fmt.Fprintf(os.Stdout, "\nSummary written to %s\n", summaryOut)
+		}
+	} else {
+		summaryErr = summary(os.Stdout)
+	}
+	if summaryErr != nil {
+		return errors.Join(runErr, fmt.Errorf("failed to write the summary: %w", summaryErr))
+	}
+
+	return runErr
+}
+
+// migrateEach migrates the PVCs of a bulk run one after the other, until
+// every one was attempted, the run is interrupted, --fail-fast stops it at a
+// failure, or the --max-total-snapshots budget is used up.
+func migrateEach(baseCtx context.Context, c *clusterClients, migrations []*migration, prog *progress, progressState *runProgress, budget *snapshotBudget) (results, failed []bulkResult, interrupted error) {
+	for i, m := range migrations {
+		if budget.exhausted() {
+			budget.skip(migrations[i:])
+			fmt.Fprintf(stepOut, "\n⚠ Reached --max-total-snapshots %d, not starting the remaining %d PVCs\n", budget.Limit, len(migrations)-i)
+			break
+		}
+		if i > 0 && throttle > 0 {
+			fmt.Fprintf(stepOut, "\nWaiting %s before the next PVC...\n", throttle)
+			if err := sleep(baseCtx, throttle); err != nil {
+				interrupted = fmt.Errorf("interrupted after %d/%d PVCs: %w", i, len(migrations), err)
+				break
+			}
+		}
+		if baseCtx.Err() != nil {
+			interrupted = fmt.Errorf("interrupted after %d/%d PVCs: %w", i, len(migrations), baseCtx.Err())
+			break
+		}
+
+		if deadline != "" {
+			fmt.Fprintf(stepOut, "\n==> [%d/%d] %s/%s (%s remaining before deadline)\n", i+1, len(migrations), m.pvcNamespace, m.pvcName, remaining(baseCtx))
+		} else {
+			fmt.Fprintf(stepOut, "\n==> [%d/%d] %s/%s\n", i+1, len(migrations), m.pvcNamespace, m.pvcName)
+		}
+		progressState.update(m, stateStarted, nil)
+		ctx, cancel := migrationContext(baseCtx)
+		start := time.Now()
+		res, err := migratePVC(ctx, c, m)
+		cancel()
+		writeResult(res)
+		budget.record(m, res)
+		if err != nil {
+			fmt.Fprintf(stepOut, "✗ Failed to migrate %s/%s: %v\n", m.pvcNamespace, m.pvcName, err)
+			progressState.update(m, stateFailed, res)
+		} else {
+			progressState.update(m, stateSucceeded, res)
+		}
+
+		r := bulkResult{m: m, res: res, err: err, duration: time.Since(start)}
+		results = append(results, r)
+		if err != nil {
+			failed = append(failed, r)
+		}
+		if prog != nil {
+			prog.update(i+1, r)
+		}
+		// The failed migration has cleaned up after itself, so nothing is
+		// left in flight
+		if err != nil && failFast {
+			break
+		}
+	}
+
+	return results, failed, interrupted
+}
+
+// migrationContext returns the context of a single PVC migration: bounded by
+// --timeout, or only by the run's deadline when --deadline is set.
+func migrationContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if deadline != "" {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// remaining returns the time left before the context deadline.
+func remaining(ctx context.Context) time.Duration {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return time.Until(d).Round(time.Second)
+}
+
+// clusterClients holds the clients for the origin cluster and every
+// destination cluster of a migration.
+type clusterClients struct {
+	originK8s  kubernetes.Interface
+	originSnap snapshotclient.Interface
+	dests      []*destination
+}
+
+// migration holds the resolved names used to migrate a single PVC.
+type migration struct {
+	pvcNamespace     string
+	pvcName          string
+	snapshotName     string
+	destNamespace    string
+	destSnapshotName string
+	destPVCName      string
+	// destPVCNamespace is the namespace of the destination PVCs, which is
+	// destNamespace unless --dest-pvc-namespace is set.
+	destPVCNamespace string
+
+	// existingSnapshot is set when replicating an existing origin snapshot
+	// with --source-snapshot, or one in progress with --reuse-in-progress,
+	// instead of taking one.
+	existingSnapshot bool
+	// resumed is set when retrying a PVC a --resume-from-state run left
+	// incomplete, whose resources may still exist under the same names.
+	resumed bool
+}
+
+// destPVCNames returns the names of the destination PVCs: --dest-pvc-name, or
+// <dest-pvc-name>-0 to -N-1 with --replicas.
+func (m *migration) destPVCNames() []string {
+	if replicas <= 1 {
+		return []string{m.destPVCName}
+	}
+	names := make([]string, replicas)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", m.destPVCName, i)
+	}
+	return names
+}
+
+// newMigration resolves the snapshot, namespace and PVC names for a source PVC,
+// applying the name flags and their defaults.
+func newMigration(namespace, name string) *migration {
+	m := &migration{
+		pvcNamespace:     namespace,
+		pvcName:          name,
+		snapshotName:     snapshotName,
+		destNamespace:    destNamespace,
+		destSnapshotName: destSnapshotName,
+		destPVCName:      destPVCName,
+	}
+
+	if m.snapshotName == "" {
+		m.snapshotName = fmt.Sprintf("%s-snapshot-%s", name, runID)
+	}
+	if m.destSnapshotName == "" {
+		m.destSnapshotName = m.snapshotName
+	}
+	if m.destNamespace == "" {
+		if mapped, ok := namespaceMap[namespace]; ok {
+			m.destNamespace = mapped
+		} else {
+			m.destNamespace = namespace
+		}
+	}
+	if createPVC && m.destPVCName == "" {
+		m.destPVCName = name
+	}
+	m.destPVCNamespace = destPVCNamespace
+	if m.destPVCNamespace == "" {
+		m.destPVCNamespace = m.destNamespace
+	}
+
+	return m
+}
+
+// copiesSnapshot reports whether the destination PVCs are in another namespace
+// than the destination snapshot, which must then be copied to theirs.
+func (m *migration) copiesSnapshot() bool {
+	return createPVC && m.destPVCNamespace != m.destNamespace
+}
+
+// parsePVCRef parses a PVC given to flag as name or namespace/name,
+// defaulting to namespace when it has no namespace part.
+func parsePVCRef(flag, ref, namespace string) (string, string, error) {
+	name := ref
+	if i := strings.Index(ref, "/"); i >= 0 {
+		namespace, name = ref[:i], ref[i+1:]
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return "", "", fmt.Errorf("invalid namespace in --%s %q: %s", flag, ref, strings.Join(errs, ", "))
+		}
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid PVC name in --%s %q: %s", flag, ref, strings.Join(errs, ", "))
+	}
+	return namespace, name, nil
+}
+
+// sameClusterSuffix is appended to destination snapshot names that would
+// collide with the origin snapshot when a destination is the origin cluster.
+const sameClusterSuffix = "-dest"
+
+// avoidNameCollision makes sure the destination snapshot does not take the
+// origin snapshot's place when a destination is the origin cluster itself.
+// Default names get a suffix; an explicit --dest-snapshot-name is an error.
+func avoidNameCollision(c *clusterClients, m *migration) error {
+	if m.destNamespace != m.pvcNamespace || m.destSnapshotName != m.snapshotName {
+		return nil
+	}
+	for _, d := range c.dests {
+		if !d.sameAsOrigin {
+			continue
+		}
+		if destSnapshotName != "" {
+			return fmt.Errorf("destination %s is the origin cluster and --dest-snapshot-name %s/%s is the origin snapshot, choose another --dest-snapshot-name", d.name, m.destNamespace, m.destSnapshotName)
+		}
+		m.destSnapshotName += sameClusterSuffix
+		fmt.Fprintf(stepOut, "⚠ Warning: destination %s is the origin cluster, naming the destination snapshot %s/%s\n", d.name, m.destNamespace, m.destSnapshotName)
+		return nil
+	}
+	return nil
+}
+
+// destContentName returns the name of the VolumeSnapshotContent bound to the
+// destination snapshot. Contents are cluster-scoped, so the name includes the
+// destination namespace: PVCs of the same name in several namespaces get
+// contents of their own, and one snapshot can be cloned into several
+// namespaces of the origin cluster.
+func destContentName(m *migration) string {
+	return fmt.Sprintf("snapcontent-%s-%s", m.destNamespace, m.destSnapshotName)
+}
+
+func migratePVC(ctx context.Context, c *clusterClients, m *migration) (res *migrationResult, err error) {
+	ctx, span := tracer.Start(ctx, "snapshift.migrate", trace.WithAttributes(
+		attribute.String("snapshift.source_pvc", m.pvcNamespace+"/"+m.pvcName),
+		attribute.String("snapshift.dest_snapshot", m.destNamespace+"/"+m.destSnapshotName),
+	))
+	startMigration()
+	res = newMigrationResult(m)
+	defer func(start time.Time) {
+		endSpan(span, start, err)
+		recordMigration(m, start, err)
+		res.finish(start, err)
+	}(time.Now())
+
+	// Track created resources for cleanup on failure
+	var (
+		originSnapshotCreated = false
+		destsStarted          = false
+	)
+
+	// Step 1: Get source PVC
+	var sourcePVC *corev1.PersistentVolumeClaim
+	phaseCtx, p := startPhase(ctx, "fetch-pvc")
+	if m.existingSnapshot {
+		sourcePVC, err = existingSnapshotPVC(phaseCtx, c.originK8s, m)
+		p.end(err)
+		if err != nil {
+			return res, err
+		}
+	} else {
+		fmt.Fprintf(stepOut, "Fetching PVC %s/%s from origin cluster...\n", m.pvcNamespace, m.pvcName)
+		sourcePVC, err = c.originK8s.CoreV1().PersistentVolumeClaims(m.pvcNamespace).Get(phaseCtx, m.pvcName, metav1.GetOptions{})
+		p.end(err)
+		if err != nil {
+			return res, fmt.Errorf("failed to get source PVC: %w", err)
+		}
+	}
+	storageSize := sourcePVC.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !storageSize.IsZero() {
+		fmt.Fprintf(stepOut, "Found PVC with size: %s\n", storageSize.String())
+		res.StorageSize = storageSize.String()
+		if err = checkSnapshotSize(fmt.Sprintf("PVC %s/%s request", sourcePVC.Namespace, sourcePVC.Name), storageSize); err != nil {
+			return res, err
+		}
+	}
+	originClass := snapshotClass
+	if !m.existingSnapshot {
+		if err = checkPVCDriverAllowed(ctx, c.originK8s, sourcePVC); err != nil {
+			return res, err
+		}
+		if originClass, err = originSnapshotClass(ctx, c, sourcePVC); err != nil {
+			return res, err
+		}
+	}
+
+	// Step 1.3: Warn about snapshots of the PVC still being taken, and wait
+	// for one of them instead with --reuse-in-progress
+	if !m.existingSnapshot {
+		if name := checkInProgressSnapshots(ctx, c.originSnap, m); name != "" {
+			m.snapshotName = name
+			m.existingSnapshot = true
+		}
+	}
+
+	// Step 1.4: Check the destinations while the origin snapshot is taken.
+	// They are only read from until the snapshot handle is known, and the
+	// origin steps stop as soon as no destination is left to replicate to
+	var preflights *destPreflights
+	if !m.existingSnapshot && !snapshotOnly {
+		if driver := pvcCSIDriver(ctx, c.originK8s, sourcePVC); driver != "" {
+			runCtx := ctx
+			var cancelOrigin context.CancelCauseFunc
+			ctx, cancelOrigin = context.WithCancelCause(ctx)
+			defer cancelOrigin(nil)
+			preflights = startDestPreflights(runCtx, c, sourcePVC, driver, cancelOrigin)
+			defer preflights.stop()
+			defer func() {
+				// Report the failed preflight rather than a bare cancellation
+				if err != nil && runCtx.Err() == nil && ctx.Err() != nil {
+					err = context.Cause(ctx)
+				}
+			}()
+		}
+	}
+
+	// Step 1.5: Run the pre-snapshot hook Job; the post-snapshot Job runs once
+	// the snapshot is taken, or on the way out if it fails
+	if preSnapshotJob != nil {
+		phaseCtx, p = startPhase(ctx, "pre-snapshot-job")
+		err = runHookJob(phaseCtx, c.originK8s, preSnapshotJob, hookPreSnapshot, m)
+		p.end(err)
+		if err != nil {
+			return res, err
+		}
+	}
+	postHookPending := postSnapshotJob != nil
+	runPostHook := func(ctx context.Context) {
+		postHookPending = false
+		phaseCtx, p := startPhase(ctx, "post-snapshot-job")
+		err := runHookJob(phaseCtx, c.originK8s, postSnapshotJob, hookPostSnapshot, m)
+		p.end(err)
+		if err != nil {
+			fmt.Fprintf(stepOut, "⚠ Warning: %v\n", err)
+		}
+	}
+	defer func() {
+		if postHookPending {
+			// The run's context may be done, after a failure or Ctrl-C, so
+			// bound the wait for a Job that never gets to run
+			hookCtx, cancel := context.WithTimeout(context.Background(), cleanupHookTimeout)
+			defer cancel()
+			runPostHook(hookCtx)
+		}
+	}()
+
+	// Step 2: Create snapshot in origin cluster, unless replicating an
+	// existing one
+	if !m.existingSnapshot {
+		if err = waitSnapshotRate(ctx, m.pvcNamespace, m.snapshotName); err != nil {
+			return res, fmt.Errorf("failed to create origin snapshot: %w", err)
+		}
+		fmt.Fprintf(stepOut, "Creating snapshot %s/%s in origin cluster...\n", m.pvcNamespace, m.snapshotName)
+		printApply(originKubectlFlags(), buildSnapshot(m.pvcNamespace, m.snapshotName, m.pvcName, originClass))
+		phaseCtx, p = startPhase(ctx, "create-origin-snapshot")
+		_, err = createSnapshot(phaseCtx, c.originSnap, m.pvcNamespace, m.snapshotName, m.pvcName, originClass)
+		if apierrors.IsAlreadyExists(err) && m.resumed {
+			err = reuseResumedSnapshot(phaseCtx, c.originSnap, m)
+		}
+		p.end(err)
+		if err != nil {
+			return res, fmt.Errorf("failed to create origin snapshot: %w", err)
+		}
+		originSnapshotCreated = true
+		res.OriginSnapshot = &objectRef{Namespace: m.pvcNamespace, Name: m.snapshotName}
+	}
+
+	// Setup cleanup on failure; failed destinations clean up after themselves
+	defer func() {
+		if err != nil && !destsStarted && leaveOnTimeout(err) {
+			printLeftSnapshot("", originKubectlFlags(), c.originSnap, m.pvcNamespace, m.snapshotName)
+			return
+		}
+		if err != nil && !destsStarted {
+			cleanupCtx, cancel := cleanupContext()
+			defer cancel()
+			cleanupOnFailure(cleanupCtx, failedInOrigin, originCleanup{
+				snap:      c.originSnap,
+				created:   originSnapshotCreated,
+				namespace: m.pvcNamespace,
+				name:      m.snapshotName,
+			}, nil)
+		}
+	}()
 
-	if err := rootCmd.MarkFlagRequired("pvc"); err != nil {
-		panic(fmt.Sprintf("failed to mark pvc flag as required: %v", err))
+	// Step 3: Wait for origin snapshot to be ready (or just created), unless
+	// it is an existing snapshot known to be ready
+	var originSnapshot *snapshotv1.VolumeSnapshot
+	if skipWaitOrigin {
+		if originSnapshot, err = readySnapshot(ctx, c.originSnap, m.pvcNamespace, m.snapshotName); err != nil {
+			return res, err
+		}
+	} else {
+		if waitFor == waitForCreated {
+			fmt.Fprintf(stepOut, "Waiting for origin snapshot to be created...\n")
+		} else {
+			fmt.Fprintf(stepOut, "Waiting for origin snapshot to be ready...\n")
+		}
+		phaseCtx, p = startPhase(ctx, "wait-origin-snapshot")
+		originSnapshot, err = waitForSnapshotReady(phaseCtx, c.originSnap, m.pvcNamespace, m.snapshotName, waitFor)
+		p.end(err)
+		if err != nil {
+			return res, fmt.Errorf("failed waiting for origin snapshot: %w", err)
+		}
 	}
-}
 
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	if postHookPending {
+		runPostHook(ctx)
 	}
-}
 
-func runSnapshift(cmd *cobra.Command, args []string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	if originSnapshot.Status == nil || originSnapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return res, fmt.Errorf("origin snapshot does not have a bound VolumeSnapshotContent")
+	}
 
-	// Set defaults
-	if snapshotName == "" {
-		snapshotName = fmt.Sprintf("%s-snapshot-%d", pvcName, time.Now().Unix())
+	// Step 4: Get the VolumeSnapshotContent from origin
+	fmt.Fprintf(stepOut, "Fetching VolumeSnapshotContent %s...\n", *originSnapshot.Status.BoundVolumeSnapshotContentName)
+	phaseCtx, p = startPhase(ctx, "fetch-origin-content")
+	originContent, err := c.originSnap.SnapshotV1().VolumeSnapshotContents().Get(phaseCtx, *originSnapshot.Status.BoundVolumeSnapshotContentName, metav1.GetOptions{})
+	p.end(err)
+	if err != nil {
+		return res, fmt.Errorf("failed to get origin VolumeSnapshotContent: %w", err)
 	}
-	if destSnapshotName == "" {
-		destSnapshotName = snapshotName
+	res.setOriginContent(originSnapshot, originContent)
+	if err = checkDriverAllowed(originContent.Spec.Driver); err != nil {
+		return res, fmt.Errorf("origin VolumeSnapshotContent %s: %w", originContent.Name, err)
+	}
+
+	// Some drivers mark the snapshot ready before its content, and the handle
+	// is only usable once the content is ready too
+	if waitContentReady && waitFor == waitForReady && !contentReady(originContent) {
+		if skipWaitOrigin {
+			return res, fmt.Errorf("origin VolumeSnapshotContent %s is not ready to use, run without --skip-wait-origin to wait for it", originContent.Name)
+		}
+		fmt.Fprintf(stepOut, "Waiting for origin VolumeSnapshotContent to be ready...\n")
+		phaseCtx, p = startPhase(ctx, "wait-origin-content")
+		originContent, err = waitForContentReady(phaseCtx, c.originSnap, originContent.Name)
+		p.end(err)
+		if err != nil {
+			return res, fmt.Errorf("failed waiting for origin VolumeSnapshotContent: %w", err)
+		}
+		res.setOriginContent(originSnapshot, originContent)
 	}
-	if destNamespace == "" {
-		destNamespace = pvcNamespace
+
+	if originContent.Status == nil || originContent.Status.SnapshotHandle == nil {
+		return res, fmt.Errorf("origin VolumeSnapshotContent does not have a snapshot handle")
 	}
-	if createPVC && destPVCName == "" {
-		destPVCName = pvcName
+	snapshotHandle := *originContent.Status.SnapshotHandle
+	fmt.Fprintf(stepOut, "Found snapshot handle: %s\n", snapshotHandle)
+	span.SetAttributes(attribute.String("snapshift.snapshot_handle", snapshotHandle))
+
+	if snapshotOnly {
+		fmt.Fprintf(stepOut, "\n✓ Successfully created snapshot!\n")
+		fmt.Fprintf(stepOut, "  Origin snapshot: %s/%s\n", m.pvcNamespace, m.snapshotName)
+		fmt.Fprintf(stepOut, "  VolumeSnapshotContent: %s\n", originContent.Name)
+		fmt.Fprintf(stepOut, "  Snapshot handle: %s\n", snapshotHandle)
+		return res, nil
 	}
 
-	// Create origin cluster clients
-	fmt.Printf("Connecting to origin cluster...\n")
-	originK8sClient, originSnapClient, err := createClients(originKubeconfig, originContext)
-	if err != nil {
-		return fmt.Errorf("failed to create origin cluster clients: %w", err)
+	// Step 4.0: The destination shares the handle, so deleting an origin content
+	// with a Delete policy would remove the storage snapshot it depends on
+	originRetained := originContent.Spec.DeletionPolicy == snapshotv1.VolumeSnapshotContentRetain
+	if originContent.Spec.DeletionPolicy == snapshotv1.VolumeSnapshotContentDelete {
+		if protectOrigin {
+			fmt.Fprintf(stepOut, "Patching origin VolumeSnapshotContent %s to DeletionPolicy Retain...\n", originContent.Name)
+			printCommand(kubectl(originKubectlFlags(), fmt.Sprintf(`patch volumesnapshotcontent %s --type merge -p '{"spec":{"deletionPolicy":"Retain"}}'`, originContent.Name)))
+			if err = retainContent(ctx, c.originSnap, originContent.Name); err != nil {
+				return res, fmt.Errorf("failed to protect origin VolumeSnapshotContent: %w", err)
+			}
+			originRetained = true
+			fmt.Fprintf(stepOut, "✓ Origin VolumeSnapshotContent is now retained\n")
+		} else {
+			fmt.Fprintf(stepOut, "⚠ Warning: origin VolumeSnapshotContent %s has DeletionPolicy Delete\n", originContent.Name)
+			fmt.Fprintf(stepOut, "  Deleting origin snapshot %s/%s will destroy the storage snapshot the destination depends on\n", m.pvcNamespace, m.snapshotName)
+			fmt.Fprintf(stepOut, "  Use --protect-origin to switch it to Retain\n")
+		}
 	}
 
-	// Create destination cluster clients
-	fmt.Printf("Connecting to destination cluster...\n")
-	destK8sClient, destSnapClient, err := createClients(destKubeconfig, destContext)
+	// Step 4.1: Make sure the destination PVC request can hold the snapshot data
+	if createPVC {
+		if storageSize.IsZero() && originSnapshot.Status.RestoreSize != nil {
+			// No source PVC to copy the request from
+			storageSize = originSnapshot.Status.RestoreSize.DeepCopy()
+		}
+		storageSize, err = resolvePVCSize(templateStorageSize(storageSize), originSnapshot.Status.RestoreSize, strictSize)
+		if err != nil {
+			return res, err
+		}
+		// The request may have grown to the restore size
+		if err = checkSnapshotSize("destination PVC request", storageSize); err != nil {
+			return res, err
+		}
+	} else if storageSize.IsZero() && originSnapshot.Status.RestoreSize != nil {
+		// No source PVC request to have checked
+		if err = checkSnapshotSize("snapshot restore size", *originSnapshot.Status.RestoreSize); err != nil {
+			return res, err
+		}
+	}
+
+	// Rewrite the handle for the destination account, if needed
+	destHandle, err := destSnapshotHandle(ctx, snapshotHandle)
 	if err != nil {
-		return fmt.Errorf("failed to create destination cluster clients: %w", err)
+		return res, err
+	}
+	if destHandle != snapshotHandle {
+		res.DestSnapshotHandle = destHandle
 	}
 
-	// Track created resources for cleanup on failure
-	var (
-		originSnapshotCreated = false
-		destContentCreated    = false
-		destSnapshotCreated   = false
-		destContentName       string
-	)
+	// Let the operator act on the storage snapshot before the destinations
+	if err = pauseBeforeDests(ctx, m, snapshotHandle, destHandle); err != nil {
+		return res, err
+	}
 
-	// Step 1: Get source PVC
-	fmt.Printf("Fetching PVC %s/%s from origin cluster...\n", pvcNamespace, pvcName)
-	sourcePVC, err := originK8sClient.CoreV1().PersistentVolumeClaims(pvcNamespace).Get(ctx, pvcName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get source PVC: %w", err)
+	origin := &originState{
+		sourcePVC:      sourcePVC,
+		content:        originContent,
+		snapshotHandle: snapshotHandle,
+		destHandle:     destHandle,
+		storageSize:    storageSize,
+	}
+	if preflights != nil && preflights.originDriver == originContent.Spec.Driver {
+		origin.csiDriver = preflights.originCSIDriver
+		origin.preflights = preflights.wait()
+	} else {
+		origin.csiDriver = getOriginCSIDriver(ctx, c.originK8s, originContent.Spec.Driver)
 	}
-	storageSize := sourcePVC.Spec.Resources.Requests[corev1.ResourceStorage]
-	fmt.Printf("Found PVC with size: %s\n", storageSize.String())
 
-	// Step 2: Create snapshot in origin cluster
-	fmt.Printf("Creating snapshot %s/%s in origin cluster...\n", pvcNamespace, snapshotName)
-	_, err = createSnapshot(ctx, originSnapClient, pvcNamespace, snapshotName, pvcName, snapshotClass)
+	// Steps 5-9 run for every destination, concurrently when there are several
+	destsStarted = true
+	failed, left, err := replicateToDests(ctx, c, m, origin, res)
+	// A destination left to become ready still needs the origin snapshot
+	if failed == len(c.dests) && left == 0 {
+		cleanupCtx, cancel := cleanupContext()
+		cleanupOnFailure(cleanupCtx, failedInDest, originCleanup{
+			snap:      c.originSnap,
+			created:   originSnapshotCreated,
+			retained:  originRetained,
+			namespace: m.pvcNamespace,
+			name:      m.snapshotName,
+		}, nil)
+		cancel()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create origin snapshot: %w", err)
+		return res, err
 	}
-	originSnapshotCreated = true
 
-	// Setup cleanup on failure
-	defer func() {
-		if err != nil {
-			cleanupOnFailure(context.Background(), originSnapClient, destSnapClient,
-				originSnapshotCreated, pvcNamespace, snapshotName,
-				destContentCreated, destContentName,
-				destSnapshotCreated, destNamespace, destSnapshotName)
+	// Step 9.1: Delete the origin snapshot once every destination has its PVC;
+	// an existing snapshot given with --source-snapshot is left alone
+	if createPVC && deleteSnapshots && !m.existingSnapshot {
+		if err := deleteOriginSnapshot(ctx, c.originSnap, m.pvcNamespace, m.snapshotName); err != nil {
+			fmt.Fprintf(stepOut, "⚠ Warning: Failed to delete snapshots: %v\n", err)
+			fmt.Fprintf(stepOut, "  You may need to manually clean up the snapshots\n")
 		}
-	}()
+	}
 
-	// Step 3: Wait for origin snapshot to be ready
-	fmt.Printf("Waiting for origin snapshot to be ready...\n")
-	originSnapshot, err := waitForSnapshotReady(ctx, originSnapClient, pvcNamespace, snapshotName)
-	if err != nil {
-		return fmt.Errorf("failed waiting for origin snapshot: %w", err)
+	annotateSourcePVC(ctx, c, m)
+
+	fmt.Fprintf(stepOut, "\n✓ Successfully completed snapshot migration!\n")
+	if !deleteSnapshots || m.existingSnapshot {
+		fmt.Fprintf(stepOut, "  Origin snapshot: %s/%s\n", m.pvcNamespace, m.snapshotName)
+	}
+	for _, d := range c.dests {
+		dm := d.migration(m)
+		if !deleteSnapshots {
+			fmt.Fprintf(stepOut, "  %sDestination snapshot: %s/%s\n", d.prefix, dm.destNamespace, dm.destSnapshotName)
+			if dm.copiesSnapshot() {
+				fmt.Fprintf(stepOut, "  %sDestination snapshot copy: %s/%s\n", d.prefix, dm.destPVCNamespace, dm.destSnapshotName)
+			}
+		}
+		if createPVC {
+			for _, name := range dm.destPVCNames() {
+				fmt.Fprintf(stepOut, "  %sDestination PVC: %s/%s\n", d.prefix, dm.destPVCNamespace, name)
+			}
+		}
+	}
+	if deleteSnapshots && createPVC {
+		fmt.Fprintf(stepOut, "  Snapshots deleted\n")
 	}
 
-	if originSnapshot.Status == nil || originSnapshot.Status.BoundVolumeSnapshotContentName == nil {
-		return fmt.Errorf("origin snapshot does not have a bound VolumeSnapshotContent")
+	return res, nil
+}
+
+// replicateToDests replicates the origin snapshot to every destination,
+// concurrently when there are several, and cleans up the destinations that
+// failed, except those left to become ready with --wait-timeout-action=leave.
+// It returns how many failed, how many of them were left, and their error.
+// The outcome in each destination is added to res, if not nil.
+func replicateToDests(ctx context.Context, c *clusterClients, m *migration, origin *originState, res *migrationResult) (failed, left int, err error) {
+	states := make([]*destState, len(c.dests))
+	durations := make([]time.Duration, len(c.dests))
+	var wg sync.WaitGroup
+	for i, d := range c.dests {
+		states[i] = &destState{}
+		wg.Add(1)
+		go func(i int, d *destination, st *destState) {
+			defer wg.Done()
+			start := time.Now()
+			st.err = replicateToDest(ctx, d, d.migration(m), origin, st)
+			durations[i] = time.Since(start)
+		}(i, d, states[i])
+	}
+	wg.Wait()
+	if res != nil {
+		for i, d := range c.dests {
+			res.addDest(d, d.migration(m), states[i], durations[i])
+		}
 	}
 
-	// Step 4: Get the VolumeSnapshotContent from origin
-	fmt.Printf("Fetching VolumeSnapshotContent %s...\n", *originSnapshot.Status.BoundVolumeSnapshotContentName)
-	originContent, err := originSnapClient.SnapshotV1().VolumeSnapshotContents().Get(ctx, *originSnapshot.Status.BoundVolumeSnapshotContentName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get origin VolumeSnapshotContent: %w", err)
+	var failedNames []string
+	for i, d := range c.dests {
+		st := states[i]
+		if st.err == nil {
+			continue
+		}
+		failedNames = append(failedNames, d.name)
+		if len(c.dests) > 1 {
+			fmt.Fprintf(stepOut, "\n✗ Destination %s failed: %v\n", d.name, st.err)
+		}
+		if leaveOnTimeout(st.err) {
+			left++
+			dm := d.migration(m)
+			printLeftSnapshot(d.prefix, d.kubectlFlags, d.snap, dm.destNamespace, m.destSnapshotName)
+			if st.copy != nil && st.copy.snapshotCreated {
+				printLeftSnapshot(d.prefix, d.kubectlFlags, d.snap, dm.destPVCNamespace, m.destSnapshotName)
+			}
+			continue
+		}
+		cleanupCtx, cancel := cleanupContext()
+		dm := d.migration(m)
+		cleanupOnFailure(cleanupCtx, failedInDest, originCleanup{}, &destCleanup{d: d, st: st, namespace: dm.destNamespace, name: m.destSnapshotName})
+		if st.copy != nil {
+			cleanupOnFailure(cleanupCtx, failedInDest, originCleanup{}, &destCleanup{d: d, st: st.copy, namespace: dm.destPVCNamespace, name: m.destSnapshotName})
+		}
+		cancel()
 	}
 
-	if originContent.Status == nil || originContent.Status.SnapshotHandle == nil {
-		return fmt.Errorf("origin VolumeSnapshotContent does not have a snapshot handle")
+	switch {
+	case len(failedNames) == 0:
+		return 0, 0, nil
+	case len(c.dests) == 1:
+		return 1, left, states[0].err
+	case left == len(failedNames):
+		return left, left, fmt.Errorf("%w waiting for %d/%d destinations: %s", errWaitTimeout, left, len(c.dests), strings.Join(failedNames, ", "))
+	default:
+		return len(failedNames), left, fmt.Errorf("failed to replicate to %d/%d destinations: %s", len(failedNames), len(c.dests), strings.Join(failedNames, ", "))
+	}
+}
+
+// originState holds what the destinations need from the ready origin snapshot.
+type originState struct {
+	sourcePVC      *corev1.PersistentVolumeClaim
+	content        *snapshotv1.VolumeSnapshotContent
+	snapshotHandle string
+	destHandle     string // snapshotHandle rewritten by --handle-transform
+	storageSize    resource.Quantity
+	csiDriver      *storagev1.CSIDriver
+	// preflights holds the destination checks run while the origin snapshot
+	// was taken, if any.
+	preflights map[*destination]*destPreflight
+}
+
+// destState tracks the resources created in one destination, for cleanup on
+// failure.
+type destState struct {
+	contentName     string
+	contentCreated  bool
+	snapshotCreated bool
+	err             error
+	// copy tracks the copy of the destination snapshot in the PVC namespace
+	// with --copy-snapshot-to-pvc-namespace.
+	copy *destState
+}
+
+// replicateToDest creates the VolumeSnapshotContent and pre-bound
+// VolumeSnapshot for the origin snapshot handle in a destination cluster, and
+// optionally a PVC from it.
+func replicateToDest(ctx context.Context, d *destination, m *migration, origin *originState, st *destState) (err error) {
+	ctx, span := tracer.Start(ctx, "snapshift.replicate", trace.WithAttributes(
+		attribute.String("snapshift.destination", d.name),
+		attribute.String("snapshift.snapshot_handle", origin.snapshotHandle),
+	))
+	defer func(start time.Time) { endSpan(span, start, err) }(time.Now())
+	defer func() {
+		if err == nil {
+			writeDestManifests(ctx, d, m, st)
+		}
+	}()
+
+	// Step 4.2: Validate the pre-created destination PV, if any
+	var destVolume *corev1.PersistentVolume
+	if destVolumeName != "" {
+		destVolume, err = validateDestVolume(ctx, d.k8s, destVolumeName, origin.storageSize, destAccessModes(origin.sourcePVC))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Steps 4.3-4.6: Use the checks run while the origin snapshot was taken,
+	// if they were for its driver, or run them now
+	pf := origin.preflights[d]
+	if pf == nil || pf.originDriver != origin.content.Spec.Driver {
+		if pf, err = preflightDest(ctx, d, origin.sourcePVC, origin.csiDriver, origin.content.Spec.Driver); err != nil {
+			return err
+		}
+	} else if pf.err != nil {
+		return pf.err
+	}
+	volumeAttributesClass := pf.volumeAttributesClass
+	content := contentWithDriver(origin.content, pf.driver)
+	contentClass, destClass := pf.contentClass, pf.destClass
+
+	if createPVC {
+		if err := checkStorageQuota(ctx, d, m.destPVCNamespace, d.mapStorageClass(origin.sourcePVC), origin.storageSize); err != nil {
+			return err
+		}
 	}
-	snapshotHandle := *originContent.Status.SnapshotHandle
-	fmt.Printf("Found snapshot handle: %s\n", snapshotHandle)
 
 	// Step 4.5: Ensure destination namespace exists
 	if createNamespace {
-		if err := ensureNamespace(ctx, destK8sClient, destNamespace); err != nil {
+		printCommand(kubectl(d.kubectlFlags, fmt.Sprintf("create namespace %s --dry-run=client -o yaml | ", m.destNamespace)+kubectl(d.kubectlFlags, "apply -f -")))
+		if err := ensureNamespace(ctx, d.k8s, m.destNamespace); err != nil {
 			return fmt.Errorf("failed to ensure destination namespace: %w", err)
 		}
+		if m.copiesSnapshot() {
+			printCommand(kubectl(d.kubectlFlags, fmt.Sprintf("create namespace %s --dry-run=client -o yaml | ", m.destPVCNamespace)+kubectl(d.kubectlFlags, "apply -f -")))
+			if err := ensureNamespace(ctx, d.k8s, m.destPVCNamespace); err != nil {
+				return fmt.Errorf("failed to ensure destination PVC namespace: %w", err)
+			}
+		}
 	}
 
-	// Step 5: Create VolumeSnapshotContent in destination cluster (with same snapshotHandle)
-	fmt.Printf("Creating VolumeSnapshotContent in destination cluster...\n")
-	destContentName = fmt.Sprintf("snapcontent-%s", destSnapshotName)
-	destContent, err := createVolumeSnapshotContent(ctx, destSnapClient, destContentName, destNamespace, destSnapshotName, snapshotHandle, originContent)
-	if err != nil {
-		return fmt.Errorf("failed to create destination VolumeSnapshotContent: %w", err)
+	// Steps 5-6: Create the content and its pre-bound snapshot, recreating both
+	// if a controller garbage-collects the content before the snapshot binds
+	st.contentName = destContentName(m)
+	if err := retryDestSnapshotPair(ctx, d, m, origin, st, content, contentClass, destClass); err != nil {
+		return err
 	}
-	destContentCreated = true
-	fmt.Printf("Created VolumeSnapshotContent: %s\n", destContent.Name)
 
-	// Step 6: Create VolumeSnapshot in destination cluster (pre-bound to the content)
-	fmt.Printf("Creating VolumeSnapshot %s/%s in destination cluster...\n", destNamespace, destSnapshotName)
-	_, err = createPreBoundSnapshot(ctx, destSnapClient, destNamespace, destSnapshotName, destContentName, snapshotClass)
-	if err != nil {
-		return fmt.Errorf("failed to create destination snapshot: %w", err)
-	}
-	destSnapshotCreated = true
 	// Step 7: Wait for destination snapshot to be ready
-	fmt.Printf("Waiting for destination snapshot to be ready...\n")
-	_, err = waitForSnapshotReady(ctx, destSnapClient, destNamespace, destSnapshotName)
-	if err != nil {
-		return fmt.Errorf("failed waiting for destination snapshot: %w", err)
+	var (
+		destSnapshot *snapshotv1.VolumeSnapshot
+		phaseCtx     context.Context
+		p            *phase
+	)
+	if assumeReady {
+		fmt.Fprintf(stepOut, "%s⚠ Warning: Not waiting for destination snapshot, its readiness was not verified\n", d.prefix)
+	} else {
+		fmt.Fprintf(stepOut, "%sWaiting for destination snapshot to be ready...\n", d.prefix)
+		phaseCtx, p = startPhase(ctx, "wait-dest-snapshot")
+		destSnapshot, err = waitDestSnapshotReady(phaseCtx, d, m, origin, st, content, contentClass, destClass)
+		p.end(err)
+		if err != nil {
+			return fmt.Errorf("failed waiting for destination snapshot: %w", err)
+		}
+		fmt.Fprintf(stepOut, "%sDestination snapshot is ready!\n", d.prefix)
+
+		// Step 7.4: Make sure the snapshot is backed by the origin's data
+		if err := verifyBoundHandle(ctx, d, destSnapshot, origin.destHandle); err != nil {
+			return err
+		}
+	}
+
+	// Step 7.5: Check that nothing altered the destination content
+	if compareContents {
+		if err := compareDestContent(ctx, d, st.contentName, origin.destHandle, content.Spec.Driver); err != nil {
+			return err
+		}
+		fmt.Fprintf(stepOut, "%sDestination VolumeSnapshotContent matches the origin\n", d.prefix)
+	}
+
+	// Step 7.6: A PVC can only be restored from a snapshot in its own
+	// namespace, so bind a copy of the destination snapshot in the PVC
+	// namespace, to a second content for the same handle
+	if m.copiesSnapshot() {
+		fmt.Fprintf(stepOut, "%sCopying destination snapshot to PVC namespace %s...\n", d.prefix, m.destPVCNamespace)
+		cm := *m
+		cm.destNamespace = m.destPVCNamespace
+		st.copy = &destState{contentName: fmt.Sprintf("snapcontent-%s-%s", m.destPVCNamespace, m.destSnapshotName)}
+		if err := retryDestSnapshotPair(ctx, d, &cm, origin, st.copy, content, contentClass, destClass); err != nil {
+			return fmt.Errorf("failed to copy destination snapshot to namespace %s: %w", m.destPVCNamespace, err)
+		}
+		if !assumeReady {
+			fmt.Fprintf(stepOut, "%sWaiting for destination snapshot copy to be ready...\n", d.prefix)
+			phaseCtx, p = startPhase(ctx, "wait-dest-snapshot-copy")
+			destSnapshot, err = waitForSnapshotReady(phaseCtx, d.snap, m.destPVCNamespace, m.destSnapshotName, waitForReady)
+			p.end(err)
+			if err != nil {
+				return fmt.Errorf("failed waiting for destination snapshot copy: %w", err)
+			}
+			if err := verifyBoundHandle(ctx, d, destSnapshot, origin.destHandle); err != nil {
+				return err
+			}
+		}
 	}
-	fmt.Printf("Destination snapshot is ready!\n")
 
-	// Step 8: Optionally create PVC from snapshot
+	// Step 8: Optionally create PVCs from snapshot
 	if createPVC {
-		fmt.Printf("Creating PVC %s/%s from snapshot...\n", destNamespace, destPVCName)
-		pvc, err := createPVCFromSnapshot(ctx, destK8sClient, destNamespace, destPVCName, destSnapshotName, sourcePVC)
+		storageSize, err := reconcileDestSize(destSnapshot, origin.storageSize, destVolume)
 		if err != nil {
-			return fmt.Errorf("failed to create destination PVC: %w", err)
+			return err
 		}
-		fmt.Printf("Created PVC: %s/%s\n", pvc.Namespace, pvc.Name)
 
-		// Step 9: Wait for PVC to be bound before deleting snapshots
-		if deleteSnapshots {
-			fmt.Printf("Waiting for PVC to be bound before deleting snapshots...\n")
-			err = waitForPVCBound(ctx, destK8sClient, destNamespace, destPVCName)
+		pvcNames := m.destPVCNames()
+		for _, name := range pvcNames {
+			fmt.Fprintf(stepOut, "%sCreating PVC %s/%s from snapshot...\n", d.prefix, m.destPVCNamespace, name)
+			printApply(d.kubectlFlags, buildPVCFromSnapshot(m.destPVCNamespace, name, m.destSnapshotName, storageSize, d.mapStorageClass(origin.sourcePVC), destVolume, volumeAttributesClass, provenanceAnnotations(m, origin.snapshotHandle)))
+			phaseCtx, p = startPhase(ctx, "create-pvc", attribute.String("snapshift.dest_pvc", name))
+			pvc, err := createPVCFromSnapshot(phaseCtx, d.k8s, m.destPVCNamespace, name, m.destSnapshotName, storageSize, d.mapStorageClass(origin.sourcePVC), destVolume, volumeAttributesClass, provenanceAnnotations(m, origin.snapshotHandle))
+			p.end(err)
 			if err != nil {
-				fmt.Printf("⚠ Warning: PVC may not be bound yet: %v\n", err)
-				fmt.Printf("  Proceeding with snapshot deletion anyway...\n")
-			} else {
-				fmt.Printf("PVC is bound!\n")
+				return fmt.Errorf("failed to create destination PVC: %w", err)
 			}
+			fmt.Fprintf(stepOut, "%sCreated PVC: %s/%s\n", d.prefix, pvc.Namespace, pvc.Name)
+		}
 
-			fmt.Printf("\nDeleting snapshots after PVC creation...\n")
-			if err := deleteSnapshotsAfterPVC(ctx, originSnapClient, destSnapClient, pvcNamespace, snapshotName, destNamespace, destSnapshotName, destContentName); err != nil {
-				fmt.Printf("⚠ Warning: Failed to delete snapshots: %v\n", err)
-				fmt.Printf("  You may need to manually clean up the snapshots\n")
+		// Step 9: Wait for PVCs to be bound, before deleting snapshots
+		if waitPVC || deleteSnapshots || scheduleProbePod {
+			if deleteSnapshots {
+				fmt.Fprintf(stepOut, "%sWaiting for PVC to be bound before deleting snapshots...\n", d.prefix)
+			}
+			unbound, waiting := false, false
+			for _, name := range pvcNames {
+				pending, err := bindDestPVC(ctx, d, m.destPVCNamespace, name)
+				switch {
+				case err != nil:
+					fmt.Fprintf(stepOut, "%s⚠ Warning: PVC %s may not be bound yet: %v\n", d.prefix, name, err)
+					unbound = true
+				case pending:
+					waiting = true
+				default:
+					fmt.Fprintf(stepOut, "%sPVC %s is bound!\n", d.prefix, name)
+				}
+			}
+			if waiting && deleteSnapshots {
+				// The volume is only restored from the snapshot once the PVC binds
+				fmt.Fprintf(stepOut, "%s⚠ Warning: Not deleting snapshots, a PVC waiting for its first consumer still needs them\n", d.prefix)
+				return nil
+			}
+			if unbound && deleteSnapshots {
+				fmt.Fprintf(stepOut, "  Proceeding with snapshot deletion anyway...\n")
 			}
 		}
-	}
 
-	fmt.Printf("\n✓ Successfully completed snapshot migration!\n")
-	if !deleteSnapshots {
-		fmt.Printf("  Origin snapshot: %s/%s\n", pvcNamespace, snapshotName)
-		fmt.Printf("  Destination snapshot: %s/%s\n", destNamespace, destSnapshotName)
-	}
-	if createPVC {
-		fmt.Printf("  Destination PVC: %s/%s\n", destNamespace, destPVCName)
-	}
-	if deleteSnapshots && createPVC {
-		fmt.Printf("  Snapshots deleted\n")
+		if deleteSnapshots {
+			fmt.Fprintf(stepOut, "\n%sDeleting snapshots after PVC creation...\n", d.prefix)
+			if st.copy != nil {
+				if err := deleteDestSnapshots(ctx, d.snap, m.destPVCNamespace, m.destSnapshotName, st.copy.contentName); err != nil {
+					fmt.Fprintf(stepOut, "%s⚠ Warning: Failed to delete snapshot copy: %v\n", d.prefix, err)
+				}
+			}
+			if err := deleteDestSnapshots(ctx, d.snap, m.destNamespace, m.destSnapshotName, st.contentName); err != nil {
+				fmt.Fprintf(stepOut, "%s⚠ Warning: Failed to delete snapshots: %v\n", d.prefix, err)
+				fmt.Fprintf(stepOut, "  You may need to manually clean up the snapshots\n")
+			}
+		}
 	}
 
 	return nil
 }
 
-func createClients(kubeconfigPath, contextName string) (*kubernetes.Clientset, *snapshotclient.Clientset, error) {
-	// Load kubeconfig
+func createClients(kubeconfigPath, contextName string, o clientOverrides) (kubernetes.Interface, snapshotclient.Interface, error) {
+	config, err := loadKubeconfig(kubeconfigPath, contextName)
+	if err != nil {
+		return nil, nil, err
+	}
+	o.apply(config)
+
+	return clientsForConfig(config)
+}
+
+// loadKubeconfig loads the REST config of a context from a kubeconfig path,
+// which may list several files to merge.
+func loadKubeconfig(kubeconfigPath, contextName string) (*rest.Config, error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	if kubeconfigPath != "" {
+	if paths := filepath.SplitList(kubeconfigPath); len(paths) > 1 {
+		// Merge several files like $KUBECONFIG does: the first to set a value wins
+		loadingRules.Precedence = paths
+	} else if kubeconfigPath != "" {
 		loadingRules.ExplicitPath = kubeconfigPath
 	}
 
@@ -252,7 +1732,21 @@ func createClients(kubeconfigPath, contextName string) (*kubernetes.Clientset, *
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
 	config, err := kubeConfig.ClientConfig()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return config, nil
+}
+
+// clientsForConfig creates the Kubernetes and snapshot clientsets for a REST
+// config.
+func clientsForConfig(config *rest.Config) (kubernetes.Interface, snapshotclient.Interface, error) {
+	if maxAPICalls > 0 {
+		config.Wrap(limitAPICalls)
+	}
+	if maxRetries > 0 {
+		// Outside the API call limit, so a request waiting to be retried
+		// does not hold a slot
+		config.Wrap(retryTransient)
 	}
 
 	// Create Kubernetes clientset
@@ -270,11 +1764,19 @@ func createClients(kubeconfigPath, contextName string) (*kubernetes.Clientset, *
 	return k8sClient, snapClient, nil
 }
 
-func createSnapshot(ctx context.Context, client *snapshotclient.Clientset, namespace, name, pvcName, snapshotClass string) (*snapshotv1.VolumeSnapshot, error) {
+func createSnapshot(ctx context.Context, client snapshotclient.Interface, namespace, name, pvcName, snapshotClass string) (*snapshotv1.VolumeSnapshot, error) {
+	snapshot := buildSnapshot(namespace, name, pvcName, snapshotClass)
+	return client.SnapshotV1().VolumeSnapshots(namespace).Create(ctx, snapshot, metav1.CreateOptions{})
+}
+
+// buildSnapshot renders the origin VolumeSnapshot of a PVC.
+func buildSnapshot(namespace, name, pvcName, snapshotClass string) *snapshotv1.VolumeSnapshot {
 	snapshot := &snapshotv1.VolumeSnapshot{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{managedByLabel: managedBy},
+			Annotations: map[string]string{annotationSourcePVC: namespace + "/" + pvcName},
 		},
 		Spec: snapshotv1.VolumeSnapshotSpec{
 			Source: snapshotv1.VolumeSnapshotSource{
@@ -283,45 +1785,246 @@ func createSnapshot(ctx context.Context, client *snapshotclient.Clientset, names
 		},
 	}
 
-	if snapshotClass != "" {
-		snapshot.Spec.VolumeSnapshotClassName = &snapshotClass
+	if snapshotClass != "" {
+		snapshot.Spec.VolumeSnapshotClassName = &snapshotClass
+	}
+
+	return snapshot
+}
+
+func createVolumeSnapshotContent(ctx context.Context, client snapshotclient.Interface, name, namespace, snapshotName, snapshotHandle, snapshotClass string, originContent *snapshotv1.VolumeSnapshotContent) (*snapshotv1.VolumeSnapshotContent, error) {
+	content := buildVolumeSnapshotContent(name, namespace, snapshotName, snapshotHandle, snapshotClass, originContent)
+	if serverSideApply {
+		return applyVolumeSnapshotContent(ctx, client, content)
+	}
+	return client.SnapshotV1().VolumeSnapshotContents().Create(ctx, content, metav1.CreateOptions{})
+}
+
+// buildVolumeSnapshotContent renders the pre-provisioned destination
+// VolumeSnapshotContent for a snapshot handle. The snapshot class is copied
+// from the origin content unless snapshotClass is set.
+func buildVolumeSnapshotContent(name, namespace, snapshotName, snapshotHandle, snapshotClass string, originContent *snapshotv1.VolumeSnapshotContent) *snapshotv1.VolumeSnapshotContent {
+	// Create a pre-provisioned VolumeSnapshotContent
+	content := &snapshotv1.VolumeSnapshotContent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{managedByLabel: managedBy},
+		},
+		Spec: snapshotv1.VolumeSnapshotContentSpec{
+			VolumeSnapshotRef: corev1.ObjectReference{
+				Name:      snapshotName,
+				Namespace: namespace,
+			},
+			Source: snapshotv1.VolumeSnapshotContentSource{
+				SnapshotHandle: &snapshotHandle,
+			},
+			Driver:         originContent.Spec.Driver,
+			DeletionPolicy: snapshotv1.VolumeSnapshotContentRetain, // Use Retain to keep the underlying snapshot
+		},
+	}
+
+	if deletionSecretName != "" {
+		content.Annotations = map[string]string{
+			annotationDeletionSecretName:      deletionSecretName,
+			annotationDeletionSecretNamespace: deletionSecretNamespace,
+		}
+	}
+
+	// Copy VolumeSnapshotClassName if present
+	if originContent.Spec.VolumeSnapshotClassName != nil {
+		content.Spec.VolumeSnapshotClassName = originContent.Spec.VolumeSnapshotClassName
+	}
+	if snapshotClass != "" {
+		content.Spec.VolumeSnapshotClassName = &snapshotClass
+	}
+
+	return content
+}
+
+// ensureVolumeSnapshotContent creates the destination VolumeSnapshotContent,
+// reusing an existing one for the same handle. It reports whether the content
+// was created by this call.
+func ensureVolumeSnapshotContent(ctx context.Context, client snapshotclient.Interface, name, namespace, snapshotName, snapshotHandle, snapshotClass string, originContent *snapshotv1.VolumeSnapshotContent) (bool, error) {
+	content, err := findExistingContent(ctx, client, name, namespace, snapshotName, snapshotHandle)
+	if err != nil {
+		return false, err
+	}
+	if content != nil {
+		fmt.Fprintf(stepOut, "VolumeSnapshotContent %s already exists with the same handle, reusing it\n", content.Name)
+		if serverSideApply {
+			// Bring the fields snapshift manages back to what it would create
+			if _, err := createVolumeSnapshotContent(ctx, client, name, namespace, snapshotName, snapshotHandle, snapshotClass, originContent); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	content, err = createVolumeSnapshotContent(ctx, client, name, namespace, snapshotName, snapshotHandle, snapshotClass, originContent)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(stepOut, "Created VolumeSnapshotContent: %s\n", content.Name)
+	return true, nil
+}
+
+// ensurePreBoundSnapshot creates the destination VolumeSnapshot bound to the
+// given content, reusing an existing one already bound to it. It reports
+// whether the snapshot was created by this call.
+func ensurePreBoundSnapshot(ctx context.Context, client snapshotclient.Interface, namespace, name, contentName, snapshotClass, sourcePVC string) (bool, error) {
+	snapshot, err := findExistingSnapshot(ctx, client, namespace, name, contentName)
+	if err != nil {
+		return false, err
+	}
+	if snapshot != nil {
+		fmt.Fprintf(stepOut, "VolumeSnapshot %s/%s already exists and is bound to %s, reusing it\n", namespace, name, contentName)
+		if serverSideApply {
+			// Bring the fields snapshift manages back to what it would create
+			if _, err := createPreBoundSnapshot(ctx, client, namespace, name, contentName, snapshotClass, sourcePVC); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	if _, err := createPreBoundSnapshot(ctx, client, namespace, name, contentName, snapshotClass, sourcePVC); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// findExistingContent returns the destination VolumeSnapshotContent if one with
+// the given name already exists for the same snapshot handle and snapshot
+// reference, so that re-runs can reuse it. A content with the same handle whose
+// snapshot reference is stale is repointed at the new snapshot. It returns nil
+// if the content does not exist, and an error if it exists but points elsewhere.
+func findExistingContent(ctx context.Context, client snapshotclient.Interface, name, namespace, snapshotName, snapshotHandle string) (*snapshotv1.VolumeSnapshotContent, error) {
+	content, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing VolumeSnapshotContent: %w", err)
+	}
+
+	existingHandle := contentHandle(content)
+	if existingHandle != snapshotHandle {
+		return nil, fmt.Errorf("VolumeSnapshotContent %s already exists with a different snapshot handle (%s)", name, existingHandle)
+	}
+	ref := content.Spec.VolumeSnapshotRef
+	sameRef := ref.Namespace == namespace && ref.Name == snapshotName
+	if sameRef && ref.UID == "" {
+		return content, nil
+	}
+
+	stale, err := staleSnapshotRef(ctx, client, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !stale {
+		if sameRef {
+			return content, nil
+		}
+		return nil, fmt.Errorf("VolumeSnapshotContent %s already exists and is bound to snapshot %s/%s", name, ref.Namespace, ref.Name)
+	}
+
+	fmt.Fprintf(stepOut, "VolumeSnapshotContent %s refers to deleted snapshot %s/%s, repointing it to %s/%s\n", name, ref.Namespace, ref.Name, namespace, snapshotName)
+	if err := repointContent(ctx, client, name, namespace, snapshotName); err != nil {
+		return nil, fmt.Errorf("failed to repoint VolumeSnapshotContent %s: %w", name, err)
+	}
+
+	return content, nil
+}
+
+// staleSnapshotRef reports whether the snapshot a content refers to no longer
+// exists, or has been recreated since the content was bound to it.
+func staleSnapshotRef(ctx context.Context, client snapshotclient.Interface, ref corev1.ObjectReference) (bool, error) {
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get VolumeSnapshot %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	return ref.UID != "" && ref.UID != snapshot.UID, nil
+}
+
+// repointContent patches the snapshot reference of a content to a new
+// snapshot, clearing the UID of the old one so the new snapshot can bind.
+func repointContent(ctx context.Context, client snapshotclient.Interface, name, namespace, snapshotName string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"volumeSnapshotRef": map[string]interface{}{
+				"namespace":       namespace,
+				"name":            snapshotName,
+				"uid":             nil,
+				"resourceVersion": nil,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.SnapshotV1().VolumeSnapshotContents().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// findExistingSnapshot returns the destination VolumeSnapshot if one with the
+// given name already exists and is bound to the expected content. It returns nil
+// if the snapshot does not exist, and an error if it is bound to another content.
+func findExistingSnapshot(ctx context.Context, client snapshotclient.Interface, namespace, name, contentName string) (*snapshotv1.VolumeSnapshot, error) {
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing VolumeSnapshot: %w", err)
+	}
+
+	boundContent := boundContentName(snapshot)
+	if boundContent != contentName {
+		return nil, fmt.Errorf("VolumeSnapshot %s/%s already exists and is bound to a different VolumeSnapshotContent (%s)", namespace, name, boundContent)
 	}
 
-	return client.SnapshotV1().VolumeSnapshots(namespace).Create(ctx, snapshot, metav1.CreateOptions{})
+	return snapshot, nil
 }
 
-func createVolumeSnapshotContent(ctx context.Context, client *snapshotclient.Clientset, name, namespace, snapshotName, snapshotHandle string, originContent *snapshotv1.VolumeSnapshotContent) (*snapshotv1.VolumeSnapshotContent, error) {
-	// Create a pre-provisioned VolumeSnapshotContent
-	content := &snapshotv1.VolumeSnapshotContent{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
-		},
-		Spec: snapshotv1.VolumeSnapshotContentSpec{
-			VolumeSnapshotRef: corev1.ObjectReference{
-				Name:      snapshotName,
-				Namespace: namespace,
-			},
-			Source: snapshotv1.VolumeSnapshotContentSource{
-				SnapshotHandle: &snapshotHandle,
-			},
-			Driver:         originContent.Spec.Driver,
-			DeletionPolicy: snapshotv1.VolumeSnapshotContentRetain, // Use Retain to keep the underlying snapshot
-		},
-	}
+// retainContent switches a VolumeSnapshotContent's deletion policy to Retain.
+func retainContent(ctx context.Context, client snapshotclient.Interface, name string) error {
+	patch := []byte(`{"spec":{"deletionPolicy":"Retain"}}`)
+	_, err := client.SnapshotV1().VolumeSnapshotContents().Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
 
-	// Copy VolumeSnapshotClassName if present
-	if originContent.Spec.VolumeSnapshotClassName != nil {
-		content.Spec.VolumeSnapshotClassName = originContent.Spec.VolumeSnapshotClassName
+// contentHandle returns the snapshot handle of a content, preferring the
+// handle reported in its status.
+func contentHandle(content *snapshotv1.VolumeSnapshotContent) string {
+	if content.Status != nil && content.Status.SnapshotHandle != nil {
+		return *content.Status.SnapshotHandle
+	}
+	if content.Spec.Source.SnapshotHandle != nil {
+		return *content.Spec.Source.SnapshotHandle
 	}
+	return ""
+}
 
-	return client.SnapshotV1().VolumeSnapshotContents().Create(ctx, content, metav1.CreateOptions{})
+func createPreBoundSnapshot(ctx context.Context, client snapshotclient.Interface, namespace, name, contentName, snapshotClass, sourcePVC string) (*snapshotv1.VolumeSnapshot, error) {
+	snapshot := buildPreBoundSnapshot(namespace, name, contentName, snapshotClass, sourcePVC)
+	if serverSideApply {
+		return applyVolumeSnapshot(ctx, client, snapshot)
+	}
+	return client.SnapshotV1().VolumeSnapshots(namespace).Create(ctx, snapshot, metav1.CreateOptions{})
 }
 
-func createPreBoundSnapshot(ctx context.Context, client *snapshotclient.Clientset, namespace, name, contentName, snapshotClass string) (*snapshotv1.VolumeSnapshot, error) {
+// buildPreBoundSnapshot renders the destination VolumeSnapshot bound to a
+// pre-provisioned content.
+func buildPreBoundSnapshot(namespace, name, contentName, snapshotClass, sourcePVC string) *snapshotv1.VolumeSnapshot {
 	snapshot := &snapshotv1.VolumeSnapshot{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{managedByLabel: managedBy},
+			Annotations: map[string]string{annotationSourcePVC: sourcePVC},
 		},
 		Spec: snapshotv1.VolumeSnapshotSpec{
 			Source: snapshotv1.VolumeSnapshotSource{
@@ -334,24 +2037,59 @@ func createPreBoundSnapshot(ctx context.Context, client *snapshotclient.Clientse
 		snapshot.Spec.VolumeSnapshotClassName = &snapshotClass
 	}
 
-	return client.SnapshotV1().VolumeSnapshots(namespace).Create(ctx, snapshot, metav1.CreateOptions{})
+	return snapshot
 }
 
-func waitForSnapshotReady(ctx context.Context, client *snapshotclient.Clientset, namespace, name string) (*snapshotv1.VolumeSnapshot, error) {
-	ticker := time.NewTicker(5 * time.Second)
+// waitForSnapshotReady polls the snapshot until it is ready to use. With the
+// waitForCreated mode it returns as soon as the snapshot has been cut and bound
+// to its content, which is enough to read the snapshot handle.
+func waitForSnapshotReady(ctx context.Context, client snapshotclient.Interface, namespace, name, mode string) (*snapshotv1.VolumeSnapshot, error) {
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
+	bar := newWaitBar(ctx, fmt.Sprintf("snapshot %s/%s", namespace, name))
+	defer bar.done()
 
+	// With --readiness-stable-for, when the snapshot was first seen ready
+	// without flipping back since
+	var readySince time.Time
 	for {
 		select {
 		case <-ctx.Done():
-			return nil, fmt.Errorf("timeout waiting for snapshot to be ready")
+			return nil, waitEnded(ctx, "snapshot to be ready")
+		case <-bar.tick():
+			bar.update("")
 		case <-ticker.C:
 			snapshot, err := client.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
 			if err != nil {
 				return nil, err
 			}
 
-			if snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse {
+			ready := snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+			if ready && (readinessStableFor <= 0 || mode == waitForCreated) {
+				return snapshot, nil
+			}
+			if ready {
+				if readySince.IsZero() {
+					readySince = time.Now()
+				}
+				if time.Since(readySince) >= readinessStableFor {
+					return snapshot, nil
+				}
+				if bar != nil {
+					bar.update("ReadyToUse=true, waiting for it to stay ready")
+					continue
+				}
+				fmt.Fprintf(stepOut, "  Snapshot status: ReadyToUse=true, waiting for it to stay ready for %s\n", readinessStableFor)
+				continue
+			}
+			if !readySince.IsZero() && bar == nil {
+				fmt.Fprintf(stepOut, "  ⚠ Snapshot is no longer ReadyToUse, waiting for it to be ready for %s again\n", readinessStableFor)
+			}
+			readySince = time.Time{}
+
+			if mode == waitForCreated && snapshot.Status != nil && snapshot.Status.CreationTime != nil && snapshot.Status.BoundVolumeSnapshotContentName != nil {
+				bar.done()
+				fmt.Fprintf(stepOut, "  Snapshot created, not waiting for ReadyToUse\n")
 				return snapshot, nil
 			}
 
@@ -359,14 +2097,102 @@ func waitForSnapshotReady(ctx context.Context, client *snapshotclient.Clientset,
 				return nil, fmt.Errorf("snapshot error: %s", *snapshot.Status.Error.Message)
 			}
 
-			fmt.Printf("  Snapshot status: ReadyToUse=%v\n", snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse)
+			if bar != nil {
+				bar.update("ReadyToUse=false")
+				continue
+			}
+			fmt.Fprintf(stepOut, "  Snapshot status: ReadyToUse=%v\n", snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse)
+		}
+	}
+}
+
+// waitSnapshotBound waits up to --content-binding-timeout for a pre-bound
+// snapshot to report its binding to the content it was created for. A snapshot
+// the controller refuses to bind, for example because the content's snapshot
+// reference does not match, would otherwise only fail at the readiness timeout.
+func waitSnapshotBound(ctx context.Context, d *destination, namespace, name, contentName string) error {
+	if bindingTimeout <= 0 {
+		return nil
+	}
+	bindCtx, cancel := context.WithTimeout(ctx, bindingTimeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		snapshot, err := d.snap.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get destination snapshot: %w", err)
+		}
+		bound := ""
+		if snapshot.Status != nil && snapshot.Status.BoundVolumeSnapshotContentName != nil {
+			bound = *snapshot.Status.BoundVolumeSnapshotContentName
+		}
+		if bound == contentName {
+			fmt.Fprintf(stepOut, "%s✓ Snapshot bound to VolumeSnapshotContent %s\n", d.prefix, contentName)
+			return nil
+		}
+
+		select {
+		case <-bindCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			msg := fmt.Sprintf("snapshot %s/%s did not bind to the expected content %s within %s", namespace, name, contentName, bindingTimeout)
+			if bound != "" {
+				msg += fmt.Sprintf(", it is bound to %s", bound)
+			}
+			if snapshot.Status != nil && snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+				msg += ": " + *snapshot.Status.Error.Message
+			}
+			return fmt.Errorf("%s", msg)
+		case <-ticker.C:
+		}
+	}
+}
+
+func waitForContentReady(ctx context.Context, client snapshotclient.Interface, name string) (*snapshotv1.VolumeSnapshotContent, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	bar := newWaitBar(ctx, "VolumeSnapshotContent "+name)
+	defer bar.done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, waitEnded(ctx, "VolumeSnapshotContent to be ready")
+		case <-bar.tick():
+			bar.update("")
+		case <-ticker.C:
+			content, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+
+			if contentReady(content) {
+				return content, nil
+			}
+
+			if content.Status != nil && content.Status.Error != nil && content.Status.Error.Message != nil {
+				return nil, fmt.Errorf("VolumeSnapshotContent error: %s", *content.Status.Error.Message)
+			}
+
+			if bar != nil {
+				bar.update("ReadyToUse=false")
+				continue
+			}
+			fmt.Fprintf(stepOut, "  VolumeSnapshotContent status: ReadyToUse=false\n")
 		}
 	}
 }
 
-func waitForPVCBound(ctx context.Context, client *kubernetes.Clientset, namespace, pvcName string) error {
-	fmt.Printf("Waiting for PVC %s to be bound...\n", pvcName)
-	ticker := time.NewTicker(5 * time.Second)
+func contentReady(content *snapshotv1.VolumeSnapshotContent) bool {
+	return content.Status != nil && content.Status.ReadyToUse != nil && *content.Status.ReadyToUse
+}
+
+func waitForPVCBound(ctx context.Context, client kubernetes.Interface, namespace, pvcName string) error {
+	fmt.Fprintf(stepOut, "Waiting for PVC %s to be bound...\n", pvcName)
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
 	for {
@@ -380,7 +2206,7 @@ func waitForPVCBound(ctx context.Context, client *kubernetes.Clientset, namespac
 			}
 
 			if pvc.Status.Phase == corev1.ClaimBound {
-				fmt.Printf("  PVC is bound\n")
+				fmt.Fprintf(stepOut, "  PVC is bound\n")
 				return nil
 			}
 
@@ -388,23 +2214,34 @@ func waitForPVCBound(ctx context.Context, client *kubernetes.Clientset, namespac
 				return fmt.Errorf("PVC is in Lost phase")
 			}
 
-			fmt.Printf("  PVC status: Phase=%s\n", pvc.Status.Phase)
+			fmt.Fprintf(stepOut, "  PVC status: Phase=%s\n", pvc.Status.Phase)
 		}
 	}
 }
 
-func createPVCFromSnapshot(ctx context.Context, client *kubernetes.Clientset, namespace, pvcName, snapshotName string, sourcePVC *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
-	// Get the storage size from source PVC
-	storageSize := sourcePVC.Spec.Resources.Requests[corev1.ResourceStorage]
+func createPVCFromSnapshot(ctx context.Context, client kubernetes.Interface, namespace, pvcName, snapshotName string, storageSize resource.Quantity, sourcePVC *corev1.PersistentVolumeClaim, volume *corev1.PersistentVolume, volumeAttributesClass *string, annotations map[string]string) (*corev1.PersistentVolumeClaim, error) {
+	pvc := buildPVCFromSnapshot(namespace, pvcName, snapshotName, storageSize, sourcePVC, volume, volumeAttributesClass, annotations)
+	if serverSideApply {
+		return applyPVC(ctx, client, pvc)
+	}
+	return client.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{})
+}
 
+// buildPVCFromSnapshot renders the destination PVC restored from a snapshot,
+// copying the relevant parts of the source PVC spec, overridden by the PVC
+// template if any. The VolumeAttributesClass
+// is passed in resolved, since not every destination supports it.
+func buildPVCFromSnapshot(namespace, pvcName, snapshotName string, storageSize resource.Quantity, sourcePVC *corev1.PersistentVolumeClaim, volume *corev1.PersistentVolume, volumeAttributesClass *string, annotations map[string]string) *corev1.PersistentVolumeClaim {
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      pvcName,
-			Namespace: namespace,
+			Name:        pvcName,
+			Namespace:   namespace,
+			Annotations: annotations,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: sourcePVC.Spec.AccessModes,
-			Resources: corev1.ResourceRequirements{
+			Selector:    sourcePVC.Spec.Selector,
+			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
 					corev1.ResourceStorage: storageSize,
 				},
@@ -414,6 +2251,7 @@ func createPVCFromSnapshot(ctx context.Context, client *kubernetes.Clientset, na
 				Kind:     "VolumeSnapshot",
 				Name:     snapshotName,
 			},
+			VolumeAttributesClassName: volumeAttributesClass,
 		},
 	}
 
@@ -422,66 +2260,539 @@ func createPVCFromSnapshot(ctx context.Context, client *kubernetes.Clientset, na
 		pvc.Spec.StorageClassName = sourcePVC.Spec.StorageClassName
 	}
 
-	return client.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	// Values set in --pvc-template win over the ones copied from the source
+	applyPVCTemplate(pvc)
+	applyReadOnly(pvc)
+
+	// Bind to a pre-created PV, which only binds to claims of its own class
+	if volume != nil {
+		pvc.Spec.VolumeName = volume.Name
+		pvc.Spec.StorageClassName = stringPtr(volume.Spec.StorageClassName)
+	}
+
+	return pvc
+}
+
+// Snapshots and contents created by snapshift are labeled as managed by it,
+// and snapshots annotated with their source PVC, so that prune can find them
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "snapshift"
+)
+
+// Provenance annotations recorded on destination PVCs
+const (
+	annotationSourcePVC            = "snapshift.io/source-pvc"
+	annotationSourceSnapshotHandle = "snapshift.io/source-snapshot-handle"
+	annotationMigratedAt           = "snapshift.io/migrated-at"
+)
+
+// Annotations recorded on the source PVC with --annotate-source-pvc
+const (
+	annotationLastMigratedTo = "snapshift.io/last-migrated-to"
+	annotationLastMigratedAt = "snapshift.io/last-migrated-at"
+)
+
+// annotateSourcePVC records on the source PVC, with --annotate-source-pvc,
+// the destination snapshots it was last migrated to, as
+// <cluster>/<namespace>/<snapshot> separated by commas, and when. It is a
+// patch, so it does not conflict with concurrent updates of the PVC. The
+// migration has already succeeded, so a failure is only a warning.
+func annotateSourcePVC(ctx context.Context, c *clusterClients, m *migration) {
+	if !annotateSource || m.pvcName == "" {
+		return
+	}
+
+	targets := make([]string, len(c.dests))
+	for i, d := range c.dests {
+		dm := d.migration(m)
+		targets[i] = d.name + "/" + dm.destNamespace + "/" + dm.destSnapshotName
+	}
+	to := strings.Join(targets, ",")
+	at := time.Now().UTC().Format(time.RFC3339)
+
+	fmt.Fprintf(stepOut, "Annotating source PVC %s/%s...\n", m.pvcNamespace, m.pvcName)
+	printCommand(kubectl(originKubectlFlags(), fmt.Sprintf("annotate pvc -n %s %s --overwrite %s=%s %s=%s",
+		m.pvcNamespace, m.pvcName, annotationLastMigratedTo, to, annotationLastMigratedAt, at)))
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annotationLastMigratedTo: to,
+				annotationLastMigratedAt: at,
+			},
+		},
+	})
+	if err == nil {
+		_, err = c.originK8s.CoreV1().PersistentVolumeClaims(m.pvcNamespace).Patch(ctx, m.pvcName, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Failed to annotate source PVC %s/%s: %v\n", m.pvcNamespace, m.pvcName, err)
+	}
+}
+
+// provenanceAnnotations returns the annotations recording where a destination
+// PVC was restored from, or nil when --record-provenance is off.
+func provenanceAnnotations(m *migration, snapshotHandle string) map[string]string {
+	if !recordProvenance {
+		return nil
+	}
+	return map[string]string{
+		annotationSourcePVC:            m.pvcNamespace + "/" + m.pvcName,
+		annotationSourceSnapshotHandle: snapshotHandle,
+		annotationMigratedAt:           time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// validateDestVolume checks that a pre-created destination PV exists, is
+// Available, and can satisfy the requested size and access modes.
+func validateDestVolume(ctx context.Context, client kubernetes.Interface, name string, storageSize resource.Quantity, accessModes []corev1.PersistentVolumeAccessMode) (*corev1.PersistentVolume, error) {
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination PersistentVolume %s: %w", name, err)
+	}
+
+	if pv.Status.Phase != corev1.VolumeAvailable {
+		return nil, fmt.Errorf("destination PersistentVolume %s is %s, not Available", name, pv.Status.Phase)
+	}
+
+	capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+	if capacity.Cmp(storageSize) < 0 {
+		return nil, fmt.Errorf("destination PersistentVolume %s capacity %s is smaller than the requested %s", name, capacity.String(), storageSize.String())
+	}
+
+	for _, mode := range accessModes {
+		supported := false
+		for _, pvMode := range pv.Spec.AccessModes {
+			if pvMode == mode {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("destination PersistentVolume %s does not support access mode %s", name, mode)
+		}
+	}
+
+	fmt.Fprintf(stepOut, "Destination PersistentVolume %s is Available (%s)\n", name, capacity.String())
+	return pv, nil
+}
+
+// defaultStorageClassAnnotation marks the default StorageClass.
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// checkDestStorageClass checks that the storage class of the destination PVC
+// is provisioned by the snapshot's driver. Any other provisioner cannot restore
+// the snapshot, and the PVC would stay Pending forever.
+func checkDestStorageClass(ctx context.Context, d *destination, pvc *corev1.PersistentVolumeClaim, driver string) error {
+	class, err := pvcStorageClass(ctx, d.k8s, pvc)
+	if err != nil || class == nil {
+		return err
+	}
+
+	if class.Provisioner != driver {
+		return fmt.Errorf("destination storage class %s is provisioned by %s, which cannot restore a snapshot of driver %s", class.Name, class.Provisioner, driver)
+	}
+	return nil
+}
+
+// pvcStorageClass returns the storage class a PVC is provisioned with: its own,
+// or the default class when it has none. It is nil for a PVC that can only
+// bind statically, with an empty class or no default class.
+func pvcStorageClass(ctx context.Context, client kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) (*storagev1.StorageClass, error) {
+	if pvc.Spec.StorageClassName != nil {
+		if *pvc.Spec.StorageClassName == "" {
+			return nil, nil
+		}
+		class, err := client.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get destination storage class %s: %w", *pvc.Spec.StorageClassName, err)
+		}
+		return class, nil
+	}
+
+	classes, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destination storage classes: %w", err)
+	}
+	for i := range classes.Items {
+		if classes.Items[i].Annotations[defaultStorageClassAnnotation] == "true" {
+			return &classes.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// The snapshot controller reads the secret for deleting the storage snapshot
+// of a pre-provisioned content from these annotations.
+const (
+	annotationDeletionSecretName      = "snapshot.storage.kubernetes.io/deletion-secret-name"
+	annotationDeletionSecretNamespace = "snapshot.storage.kubernetes.io/deletion-secret-namespace"
+)
+
+// Secret referenced by the destination contents, from --dest-deletion-secret
+var deletionSecretNamespace, deletionSecretName string
+
+// checkDeletionSecret checks that the --dest-deletion-secret Secret exists in
+// the destination cluster. Without it, a driver that needs a secret cannot
+// delete the storage snapshot once the content is deleted.
+func checkDeletionSecret(ctx context.Context, d *destination) error {
+	if deletionSecretName == "" {
+		return nil
+	}
+	_, err := d.k8s.CoreV1().Secrets(deletionSecretNamespace).Get(ctx, deletionSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get deletion Secret %s/%s in the destination cluster: %w", deletionSecretNamespace, deletionSecretName, err)
+	}
+	return nil
+}
+
+// destContentSource returns the origin content the destination content is
+// built from, with its driver replaced by --dest-driver, which must be a
+// CSIDriver registered in the destination cluster.
+func destContentSource(ctx context.Context, d *destination, originContent *snapshotv1.VolumeSnapshotContent) (*snapshotv1.VolumeSnapshotContent, error) {
+	driver, err := destContentDriver(ctx, d, originContent.Spec.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return contentWithDriver(originContent, driver), nil
+}
+
+// destContentDriver returns the driver of the destination content: --dest-driver
+// if set, which must be registered in the destination cluster, otherwise the
+// origin driver.
+func destContentDriver(ctx context.Context, d *destination, originDriver string) (string, error) {
+	if destDriver == "" || destDriver == originDriver {
+		return originDriver, nil
+	}
+
+	if _, err := d.k8s.StorageV1().CSIDrivers().Get(ctx, destDriver, metav1.GetOptions{}); err != nil {
+		return "", fmt.Errorf("failed to get CSIDriver %s in destination cluster %s: %w", destDriver, d.name, err)
+	}
+	fmt.Fprintf(stepOut, "%s⚠ Warning: Using driver %s for the destination content instead of the origin driver %s\n", d.prefix, destDriver, originDriver)
+	return destDriver, nil
+}
+
+// contentWithDriver returns originContent, or a copy of it using driver.
+func contentWithDriver(originContent *snapshotv1.VolumeSnapshotContent, driver string) *snapshotv1.VolumeSnapshotContent {
+	if driver == originContent.Spec.Driver {
+		return originContent
+	}
+	content := originContent.DeepCopy()
+	content.Spec.Driver = driver
+	return content
+}
+
+// resolvePVCSize returns the storage request to use for the destination PVC.
+// A request smaller than the snapshot restore size cannot be provisioned, so it
+// is increased to the restore size, or rejected when strict is set.
+func resolvePVCSize(request resource.Quantity, restoreSize *resource.Quantity, strict bool) (resource.Quantity, error) {
+	if restoreSize == nil || request.Cmp(*restoreSize) >= 0 {
+		return request, nil
+	}
+
+	if strict {
+		return request, fmt.Errorf("PVC request %s is smaller than snapshot restore size %s", request.String(), restoreSize.String())
+	}
+
+	fmt.Fprintf(stepOut, "⚠ Warning: PVC request %s is smaller than snapshot restore size %s, using %s\n", request.String(), restoreSize.String(), restoreSize.String())
+	return restoreSize.DeepCopy(), nil
+}
+
+// errContentGone reports that the destination content disappeared before its
+// snapshot bound to it.
+var errContentGone = errors.New("destination VolumeSnapshotContent was deleted before its snapshot bound to it")
+
+// createDestSnapshotPair creates the destination VolumeSnapshotContent with the
+// destination snapshot handle, then the VolumeSnapshot pre-bound to it. If the
+// content is gone once the snapshot exists, the snapshot is deleted so the pair
+// can be recreated, and errContentGone is returned.
+func createDestSnapshotPair(ctx context.Context, d *destination, m *migration, origin *originState, st *destState, content *snapshotv1.VolumeSnapshotContent, contentClass, destClass string) error {
+	// Step 4.9: Delete a destination snapshot and content of the same names
+	// left by a previous run for another handle
+	if err := replaceOutdatedDestSnapshot(ctx, d, m.destNamespace, m.destSnapshotName, st.contentName, origin.destHandle); err != nil {
+		return err
+	}
+
+	// Step 5: Create VolumeSnapshotContent in destination cluster (with same snapshotHandle)
+	fmt.Fprintf(stepOut, "%sCreating VolumeSnapshotContent in destination cluster...\n", d.prefix)
+	printApply(d.kubectlFlags, buildVolumeSnapshotContent(st.contentName, m.destNamespace, m.destSnapshotName, origin.destHandle, contentClass, content))
+	phaseCtx, p := startPhase(ctx, "create-dest-content")
+	contentCreated, err := ensureVolumeSnapshotContent(phaseCtx, d.snap, st.contentName, m.destNamespace, m.destSnapshotName, origin.destHandle, contentClass, content)
+	p.end(err)
+	if err != nil {
+		return fmt.Errorf("failed to create destination VolumeSnapshotContent: %w", err)
+	}
+	st.contentCreated = st.contentCreated || contentCreated
+
+	// Step 6: Create VolumeSnapshot in destination cluster (pre-bound to the content)
+	fmt.Fprintf(stepOut, "%sCreating VolumeSnapshot %s/%s in destination cluster...\n", d.prefix, m.destNamespace, m.destSnapshotName)
+	printApply(d.kubectlFlags, buildPreBoundSnapshot(m.destNamespace, m.destSnapshotName, st.contentName, destClass, m.pvcNamespace+"/"+m.pvcName))
+	phaseCtx, p = startPhase(ctx, "create-dest-snapshot")
+	st.snapshotCreated, err = ensurePreBoundSnapshot(phaseCtx, d.snap, m.destNamespace, m.destSnapshotName, st.contentName, destClass, m.pvcNamespace+"/"+m.pvcName)
+	p.end(err)
+	if err != nil {
+		return fmt.Errorf("failed to create destination snapshot: %w", err)
+	}
+
+	_, err = d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, st.contentName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get destination VolumeSnapshotContent %s: %w", st.contentName, err)
+	}
+	if err == nil {
+		if pinBindingUID {
+			if err := pinContentRef(ctx, d, m.destNamespace, m.destSnapshotName, st.contentName); err != nil {
+				return err
+			}
+		}
+		return waitSnapshotBound(ctx, d, m.destNamespace, m.destSnapshotName, st.contentName)
+	}
+	if !st.snapshotCreated {
+		return fmt.Errorf("VolumeSnapshotContent %s of the existing VolumeSnapshot %s/%s was deleted", st.contentName, m.destNamespace, m.destSnapshotName)
+	}
+	err = d.snap.SnapshotV1().VolumeSnapshots(m.destNamespace).Delete(ctx, m.destSnapshotName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete destination snapshot bound to the deleted VolumeSnapshotContent %s: %w", st.contentName, err)
+	}
+	st.snapshotCreated = false
+	return fmt.Errorf("%w (%s)", errContentGone, st.contentName)
+}
+
+// retryDestSnapshotPair creates a destination content and its pre-bound
+// snapshot, recreating both if a controller garbage-collects the content
+// before the snapshot binds.
+func retryDestSnapshotPair(ctx context.Context, d *destination, m *migration, origin *originState, st *destState, content *snapshotv1.VolumeSnapshotContent, contentClass, destClass string) error {
+	for attempt := 0; ; attempt++ {
+		err := createDestSnapshotPair(ctx, d, m, origin, st, content, contentClass, destClass)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errContentGone) || attempt >= maxRetries {
+			return err
+		}
+		fmt.Fprintf(stepOut, "%s⚠ Warning: %v, recreating it and its snapshot in %s (retry %d/%d)\n", d.prefix, err, retryBackoff(attempt), attempt+1, maxRetries)
+		if err := waitRetry(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// compareDestContent reads the destination content back and compares its
+// snapshot handle, as resolved by the snapshot controller, and driver with the
+// values it was created with, which a mutating admission webhook may have
+// changed.
+func compareDestContent(ctx context.Context, d *destination, name, handle, driver string) error {
+	content, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get destination VolumeSnapshotContent %s: %w", name, err)
+	}
+
+	var diffs []string
+	for _, f := range []fieldDiff{
+		{"snapshotHandle", handle, orUnset(contentHandle(content))},
+		{"driver", driver, content.Spec.Driver},
+	} {
+		if f.source != f.dest {
+			diffs = append(diffs, fmt.Sprintf("%s is %s, expected %s", f.field, f.dest, f.source))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("destination VolumeSnapshotContent %s does not match the origin, it may have been changed by an admission webhook: %s", name, strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// verifyBoundHandle checks that the content a ready destination snapshot is
+// bound to reports the snapshot handle snapshift supplied. Another handle,
+// e.g. from a content recreated by a controller or a name collision, means
+// the snapshot is backed by other data than the origin snapshot.
+func verifyBoundHandle(ctx context.Context, d *destination, snapshot *snapshotv1.VolumeSnapshot, handle string) error {
+	if snapshot.Status == nil || snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		return fmt.Errorf("destination snapshot %s/%s is ready but not bound to a VolumeSnapshotContent", snapshot.Namespace, snapshot.Name)
+	}
+	name := *snapshot.Status.BoundVolumeSnapshotContentName
+	content, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get VolumeSnapshotContent %s bound to destination snapshot %s/%s: %w", name, snapshot.Namespace, snapshot.Name, err)
+	}
+	bound := unset
+	if content.Status != nil && content.Status.SnapshotHandle != nil {
+		bound = *content.Status.SnapshotHandle
+	}
+	if bound != handle {
+		return fmt.Errorf("destination snapshot %s/%s is bound to VolumeSnapshotContent %s with snapshot handle %s, not the handle %s snapshift supplied: it is backed by other data than the origin snapshot", snapshot.Namespace, snapshot.Name, name, bound, handle)
+	}
+	return nil
+}
+
+// reconcileDestSize checks the PVC request against the destination snapshot's
+// restore size, which may differ from the origin's when the backend rounds the
+// shared snapshot, and increases it like resolvePVCSize does. It also checks
+// that a pre-created destination volume still fits. Without a restore size,
+// e.g. with --assume-ready, the request is used as is.
+func reconcileDestSize(snapshot *snapshotv1.VolumeSnapshot, request resource.Quantity, volume *corev1.PersistentVolume) (resource.Quantity, error) {
+	if snapshot == nil || snapshot.Status == nil || snapshot.Status.RestoreSize == nil {
+		return request, nil
+	}
+
+	size, err := resolvePVCSize(request, snapshot.Status.RestoreSize, strictSize)
+	if err != nil {
+		return size, fmt.Errorf("destination snapshot %s/%s: %w", snapshot.Namespace, snapshot.Name, err)
+	}
+
+	if volume != nil {
+		capacity := volume.Spec.Capacity[corev1.ResourceStorage]
+		if capacity.Cmp(size) < 0 {
+			return size, fmt.Errorf("destination PersistentVolume %s capacity %s is smaller than the destination snapshot restore size %s", volume.Name, capacity.String(), size.String())
+		}
+	}
+
+	return size, nil
+}
+
+// sleep waits for d, returning early with the context error if ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 func stringPtr(s string) *string {
 	return &s
 }
 
-func cleanupOnFailure(ctx context.Context, originSnapClient, destSnapClient *snapshotclient.Clientset,
-	originSnapshotCreated bool, originNamespace, originSnapshotName string,
-	destContentCreated bool, destContentName string,
-	destSnapshotCreated bool, destNamespace, destSnapshotName string) {
+// failureSide records which cluster a migration failed in.
+type failureSide int
 
-	fmt.Printf("\n⚠ Operation failed, cleaning up created resources...\n")
+const (
+	failedInOrigin failureSide = iota
+	failedInDest
+)
 
-	// Clean up destination snapshot
-	if destSnapshotCreated {
-		fmt.Printf("  Deleting destination snapshot %s/%s...\n", destNamespace, destSnapshotName)
-		err := destSnapClient.SnapshotV1().VolumeSnapshots(destNamespace).Delete(ctx, destSnapshotName, metav1.DeleteOptions{})
+// originCleanup is the origin snapshot of a failed migration.
+type originCleanup struct {
+	snap snapshotclient.Interface
+	// created is set when the migration created the snapshot, and retained
+	// when its content has the Retain policy.
+	created   bool
+	retained  bool
+	namespace string
+	name      string
+}
+
+// destCleanup is what a failed migration created in one destination: the
+// content and snapshot tracked by st, the snapshot being namespace/name.
+type destCleanup struct {
+	d         *destination
+	st        *destState
+	namespace string
+	name      string
+}
+
+// cleanupOnFailure deletes the resources created before a failure, in the
+// origin cluster and in dest, if any. An origin snapshot whose content is
+// retained is kept when the failure happened on the destination side, since
+// it is still usable, unless --cleanup-origin-on-dest-failure is set.
+func cleanupOnFailure(ctx context.Context, side failureSide, origin originCleanup, dest *destCleanup) {
+	fmt.Fprintf(stepOut, "\n⚠ Operation failed, cleaning up created resources...\n")
+
+	if dest != nil {
+		dest.cleanup(ctx)
+	}
+
+	// Clean up origin snapshot
+	if origin.created && !cleansOrigin() {
+		fmt.Fprintf(stepOut, "  Keeping origin snapshot %s/%s, --cleanup-scope is %s\n", origin.namespace, origin.name, cleanupScope)
+		recordCleanupKept("origin", originContext, "VolumeSnapshot", origin.namespace, origin.name)
+	} else if origin.created && side == failedInDest && origin.retained && !cleanupOriginOnDestFailure {
+		fmt.Fprintf(stepOut, "  Keeping origin snapshot %s/%s, the failure was on the destination side\n", origin.namespace, origin.name)
+		fmt.Fprintf(stepOut, "  Use --cleanup-origin-on-dest-failure to delete it instead\n")
+		recordCleanupKept("origin", originContext, "VolumeSnapshot", origin.namespace, origin.name)
+	} else if origin.created {
+		fmt.Fprintf(stepOut, "  Deleting origin snapshot %s/%s...\n", origin.namespace, origin.name)
+		contentName := deletingContent(ctx, origin.snap, origin.namespace, origin.name)
+		err := origin.snap.SnapshotV1().VolumeSnapshots(origin.namespace).Delete(ctx, origin.name, metav1.DeleteOptions{})
+		recordCleanup("origin", originContext, "VolumeSnapshot", origin.namespace, origin.name, err)
 		if err != nil {
-			fmt.Printf("  ✗ Failed to delete destination snapshot: %v\n", err)
+			fmt.Fprintf(stepOut, "  ✗ Failed to delete origin snapshot: %v\n", err)
 		} else {
-			fmt.Printf("  ✓ Deleted destination snapshot\n")
+			fmt.Fprintf(stepOut, "  ✓ Deleted origin snapshot\n")
+			// The snapshot controller deletes the content, which may get stuck
+			if contentName != "" {
+				err := waitContentDeleted(ctx, origin.snap, contentName)
+				if err != nil {
+					fmt.Fprintf(stepOut, "  ⚠ Warning: %v\n", err)
+				}
+				recordContentCleanup("origin", originContext, contentName, err)
+			}
 		}
 	}
+	reportGraceExhausted(ctx)
+	fmt.Fprintf(stepOut, "Cleanup completed.\n\n")
+}
 
-	// Clean up destination snapshot content
-	if destContentCreated {
-		fmt.Printf("  Deleting destination VolumeSnapshotContent %s...\n", destContentName)
-		err := destSnapClient.SnapshotV1().VolumeSnapshotContents().Delete(ctx, destContentName, metav1.DeleteOptions{})
+// cleanup deletes the destination snapshot and content a failed migration
+// created, or keeps them when --cleanup-scope leaves the destinations out.
+func (dc *destCleanup) cleanup(ctx context.Context) {
+	d, st := dc.d, dc.st
+
+	// Keep the destination resources out of --cleanup-scope
+	if !cleansDest() {
+		if st.snapshotCreated {
+			fmt.Fprintf(stepOut, "  Keeping destination snapshot %s/%s, --cleanup-scope is %s\n", dc.namespace, dc.name, cleanupScope)
+			recordCleanupKept(d.name, d.context, "VolumeSnapshot", dc.namespace, dc.name)
+		}
+		if st.contentCreated {
+			fmt.Fprintf(stepOut, "  Keeping destination VolumeSnapshotContent %s, --cleanup-scope is %s\n", st.contentName, cleanupScope)
+			recordCleanupKept(d.name, d.context, "VolumeSnapshotContent", "", st.contentName)
+		}
+		return
+	}
+
+	// Clean up destination snapshot
+	if st.snapshotCreated {
+		fmt.Fprintf(stepOut, "  Deleting destination snapshot %s/%s...\n", dc.namespace, dc.name)
+		err := d.snap.SnapshotV1().VolumeSnapshots(dc.namespace).Delete(ctx, dc.name, metav1.DeleteOptions{})
+		recordCleanup(d.name, d.context, "VolumeSnapshot", dc.namespace, dc.name, err)
 		if err != nil {
-			fmt.Printf("  ✗ Failed to delete destination VolumeSnapshotContent: %v\n", err)
+			fmt.Fprintf(stepOut, "  ✗ Failed to delete destination snapshot: %v\n", err)
 		} else {
-			fmt.Printf("  ✓ Deleted destination VolumeSnapshotContent\n")
+			fmt.Fprintf(stepOut, "  ✓ Deleted destination snapshot\n")
 		}
 	}
 
-	// Clean up origin snapshot
-	if originSnapshotCreated {
-		fmt.Printf("  Deleting origin snapshot %s/%s...\n", originNamespace, originSnapshotName)
-		err := originSnapClient.SnapshotV1().VolumeSnapshots(originNamespace).Delete(ctx, originSnapshotName, metav1.DeleteOptions{})
+	// Clean up destination snapshot content
+	if st.contentCreated {
+		fmt.Fprintf(stepOut, "  Deleting destination VolumeSnapshotContent %s...\n", st.contentName)
+		err := d.snap.SnapshotV1().VolumeSnapshotContents().Delete(ctx, st.contentName, metav1.DeleteOptions{})
 		if err != nil {
-			fmt.Printf("  ✗ Failed to delete origin snapshot: %v\n", err)
+			fmt.Fprintf(stepOut, "  ✗ Failed to delete destination VolumeSnapshotContent: %v\n", err)
 		} else {
-			fmt.Printf("  ✓ Deleted origin snapshot\n")
+			fmt.Fprintf(stepOut, "  ✓ Deleted destination VolumeSnapshotContent\n")
+			if err = waitContentDeleted(ctx, d.snap, st.contentName); err != nil {
+				fmt.Fprintf(stepOut, "  ⚠ Warning: %v\n", err)
+			}
 		}
+		recordContentCleanup(d.name, d.context, st.contentName, err)
 	}
-	fmt.Printf("Cleanup completed.\n\n")
 }
 
-func ensureNamespace(ctx context.Context, client *kubernetes.Clientset, namespace string) error {
+func ensureNamespace(ctx context.Context, client kubernetes.Interface, namespace string) error {
 	// Check if namespace exists
 	_, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
 	if err == nil {
 		// Namespace already exists
-		fmt.Printf("Destination namespace %s already exists\n", namespace)
+		fmt.Fprintf(stepOut, "Destination namespace %s already exists\n", namespace)
 		return nil
 	}
 
 	// Create namespace if it doesn't exist
-	fmt.Printf("Creating destination namespace %s...\n", namespace)
+	fmt.Fprintf(stepOut, "Creating destination namespace %s...\n", namespace)
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: namespace,
@@ -493,36 +2804,41 @@ func ensureNamespace(ctx context.Context, client *kubernetes.Clientset, namespac
 		return fmt.Errorf("failed to create namespace: %w", err)
 	}
 
-	fmt.Printf("✓ Created namespace %s\n", namespace)
+	fmt.Fprintf(stepOut, "✓ Created namespace %s\n", namespace)
 	return nil
 }
 
-func deleteSnapshotsAfterPVC(ctx context.Context, originSnapClient, destSnapClient *snapshotclient.Clientset,
-	originNamespace, originSnapshotName, destNamespace, destSnapshotName, destContentName string) error {
-
+// deleteDestSnapshots deletes the destination snapshot and its content once
+// the destination PVC has been created.
+func deleteDestSnapshots(ctx context.Context, destSnapClient snapshotclient.Interface, destNamespace, destSnapshotName, destContentName string) error {
 	// Delete destination snapshot first
-	fmt.Printf("  Deleting destination snapshot %s/%s...\n", destNamespace, destSnapshotName)
+	fmt.Fprintf(stepOut, "  Deleting destination snapshot %s/%s...\n", destNamespace, destSnapshotName)
 	err := destSnapClient.SnapshotV1().VolumeSnapshots(destNamespace).Delete(ctx, destSnapshotName, metav1.DeleteOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to delete destination snapshot: %w", err)
 	}
-	fmt.Printf("  ✓ Deleted destination snapshot\n")
+	fmt.Fprintf(stepOut, "  ✓ Deleted destination snapshot\n")
 
 	// Delete destination VolumeSnapshotContent
-	fmt.Printf("  Deleting destination VolumeSnapshotContent %s...\n", destContentName)
+	fmt.Fprintf(stepOut, "  Deleting destination VolumeSnapshotContent %s...\n", destContentName)
 	err = destSnapClient.SnapshotV1().VolumeSnapshotContents().Delete(ctx, destContentName, metav1.DeleteOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to delete destination VolumeSnapshotContent: %w", err)
 	}
-	fmt.Printf("  ✓ Deleted destination VolumeSnapshotContent\n")
+	fmt.Fprintf(stepOut, "  ✓ Deleted destination VolumeSnapshotContent\n")
+
+	return nil
+}
 
-	// Delete origin snapshot
-	fmt.Printf("  Deleting origin snapshot %s/%s...\n", originNamespace, originSnapshotName)
-	err = originSnapClient.SnapshotV1().VolumeSnapshots(originNamespace).Delete(ctx, originSnapshotName, metav1.DeleteOptions{})
+// deleteOriginSnapshot deletes the origin snapshot once every destination
+// PVC has been created.
+func deleteOriginSnapshot(ctx context.Context, originSnapClient snapshotclient.Interface, originNamespace, originSnapshotName string) error {
+	fmt.Fprintf(stepOut, "  Deleting origin snapshot %s/%s...\n", originNamespace, originSnapshotName)
+	err := originSnapClient.SnapshotV1().VolumeSnapshots(originNamespace).Delete(ctx, originSnapshotName, metav1.DeleteOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to delete origin snapshot: %w", err)
 	}
-	fmt.Printf("  ✓ Deleted origin snapshot\n")
+	fmt.Fprintf(stepOut, "  ✓ Deleted origin snapshot\n")
 
 	return nil
 }
@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// setFlag sets a flag variable for the duration of a test.
+func setFlag[T any](t *testing.T, p *T, value T) {
+	t.Helper()
+	old := *p
+	*p = value
+	t.Cleanup(func() { *p = old })
+}
+
+// captureStdout returns what fn writes to standard output, including the step
+// output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, out := os.Stdout, stepOut
+	os.Stdout, stepOut = w, w
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	defer func() { os.Stdout, stepOut = stdout, out }()
+	fn()
+	w.Close()
+	os.Stdout, stepOut = stdout, out
+	return <-done
+}
+
+// writeKubeconfig writes a kubeconfig with the given current context and
+// clusters, each with a context of the same name.
+func writeKubeconfig(t *testing.T, current string, servers map[string]string) string {
+	t.Helper()
+	config := "apiVersion: v1\nkind: Config\ncurrent-context: " + current + "\nclusters:\n"
+	for name, server := range servers {
+		config += "- name: " + name + "\n  cluster:\n    server: " + server + "\n"
+	}
+	config += "contexts:\n"
+	for name := range servers {
+		config += "- name: " + name + "\n  context:\n    cluster: " + name + "\n    user: " + name + "\n"
+	}
+	config += "users:\n"
+	for name := range servers {
+		config += "- name: " + name + "\n  user:\n    token: " + name + "\n"
+	}
+	path := filepath.Join(t.TempDir(), current+".yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKubeconfigPrecedence(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	a := writeKubeconfig(t, "a", map[string]string{"a": "https://a.example"})
+	b := writeKubeconfig(t, "b", map[string]string{"b": "https://b.example", "a": "https://a-from-b.example"})
+	merged := func(paths ...string) string { return strings.Join(paths, string(filepath.ListSeparator)) }
+
+	tests := []struct {
+		name    string
+		path    string
+		context string
+		want    string
+	}{
+		{"single file, current context", a, "", "https://a.example"},
+		{"single file, explicit context", b, "a", "https://a-from-b.example"},
+		{"merged, current context of the first file", merged(b, a), "", "https://b.example"},
+		{"merged, context only in the second file", merged(a, b), "b", "https://b.example"},
+		{"merged, first file wins a conflict", merged(a, b), "a", "https://a.example"},
+		{"merged, first file wins a conflict in the other order", merged(b, a), "a", "https://a-from-b.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadKubeconfig(tt.path, tt.context)
+			if err != nil {
+				t.Fatalf("loadKubeconfig: %v", err)
+			}
+			if config.Host != tt.want {
+				t.Errorf("host = %q, want %q", config.Host, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadKubeconfig(a, "missing"); err == nil {
+		t.Errorf("loadKubeconfig with an unknown context succeeded")
+	}
+}
+
+func TestFindExistingSnapshot(t *testing.T) {
+	ready := true
+	notReady := false
+	contentName := "snapcontent-data-snap"
+	otherContent := "snapcontent-other-snap"
+	pvc := "other"
+
+	tests := []struct {
+		name     string
+		existing *snapshotv1.VolumeSnapshot
+		wantErr  bool
+		wantNil  bool
+	}{
+		{name: "missing", wantNil: true},
+		{
+			name: "ready and bound to the content",
+			existing: &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap"},
+				Spec:       snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{VolumeSnapshotContentName: &contentName}},
+				Status:     &snapshotv1.VolumeSnapshotStatus{BoundVolumeSnapshotContentName: &contentName, ReadyToUse: &ready},
+			},
+		},
+		{
+			name: "not ready yet, pre-bound to the content",
+			existing: &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap"},
+				Spec:       snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{VolumeSnapshotContentName: &contentName}},
+				Status:     &snapshotv1.VolumeSnapshotStatus{ReadyToUse: &notReady},
+			},
+		},
+		{
+			name: "bound to the content of another PVC",
+			existing: &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap"},
+				Spec:       snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{VolumeSnapshotContentName: &otherContent}},
+				Status:     &snapshotv1.VolumeSnapshotStatus{BoundVolumeSnapshotContentName: &otherContent, ReadyToUse: &ready},
+			},
+			wantErr: true,
+		},
+		{
+			name: "dynamic snapshot of another PVC",
+			existing: &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap"},
+				Spec:       snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: &pvc}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := snapfake.NewSimpleClientset()
+			if tt.existing != nil {
+				client = snapfake.NewSimpleClientset(tt.existing)
+			}
+			snapshot, err := findExistingSnapshot(context.Background(), client, "apps", "snap", contentName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (snapshot == nil) != tt.wantNil {
+				t.Errorf("snapshot = %v, want nil %v", snapshot, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestEnsurePreBoundSnapshotReusesReadySnapshot(t *testing.T) {
+	ready := true
+	contentName := "snapcontent-data-snap"
+	client := snapfake.NewSimpleClientset(&snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap"},
+		Spec:       snapshotv1.VolumeSnapshotSpec{Source: snapshotv1.VolumeSnapshotSource{VolumeSnapshotContentName: &contentName}},
+		Status:     &snapshotv1.VolumeSnapshotStatus{BoundVolumeSnapshotContentName: &contentName, ReadyToUse: &ready},
+	})
+
+	created, err := ensurePreBoundSnapshot(context.Background(), client, "apps", "snap", contentName, "", "apps/data")
+	if err != nil {
+		t.Fatalf("ensurePreBoundSnapshot: %v", err)
+	}
+	if created {
+		t.Errorf("ensurePreBoundSnapshot created a snapshot that already exists")
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "create" {
+			t.Errorf("unexpected create: %v", action)
+		}
+	}
+}
+
+func TestWaitForContentReady(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	notReady := false
+	message := "driver failed to cut the snapshot"
+	newContent := func(status *snapshotv1.VolumeSnapshotContentStatus) *snapshotv1.VolumeSnapshotContent {
+		return &snapshotv1.VolumeSnapshotContent{ObjectMeta: metav1.ObjectMeta{Name: "content"}, Status: status}
+	}
+
+	t.Run("waits for a lagging content", func(t *testing.T) {
+		client := snapfake.NewSimpleClientset(newContent(&snapshotv1.VolumeSnapshotContentStatus{ReadyToUse: &notReady}))
+		gets := 0
+		client.PrependReactor("get", "volumesnapshotcontents", func(k8stesting.Action) (bool, runtime.Object, error) {
+			gets++
+			if gets < 3 {
+				return false, nil, nil
+			}
+			ready := true
+			return true, newContent(&snapshotv1.VolumeSnapshotContentStatus{ReadyToUse: &ready}), nil
+		})
+		content, err := waitForContentReady(context.Background(), client, "content")
+		if err != nil {
+			t.Fatalf("waitForContentReady: %v", err)
+		}
+		if !contentReady(content) || gets != 3 {
+			t.Errorf("returned after %d gets with ready %v, want 3 gets and a ready content", gets, contentReady(content))
+		}
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		client := snapfake.NewSimpleClientset(newContent(&snapshotv1.VolumeSnapshotContentStatus{ReadyToUse: &notReady}))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := waitForContentReady(ctx, client, "content")
+		if err == nil || !strings.Contains(err.Error(), "timeout") {
+			t.Errorf("err = %v, want a timeout", err)
+		}
+	})
+
+	t.Run("fails on a content error", func(t *testing.T) {
+		client := snapfake.NewSimpleClientset(newContent(&snapshotv1.VolumeSnapshotContentStatus{
+			ReadyToUse: &notReady,
+			Error:      &snapshotv1.VolumeSnapshotError{Message: &message},
+		}))
+		_, err := waitForContentReady(context.Background(), client, "content")
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("err = %v, want the content error", err)
+		}
+	})
+}
+
+func TestWaitForSnapshotReadyStableFor(t *testing.T) {
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	// The snapshot is ready on the first get, flips back on the second and is
+	// ready from then on
+	flapping := func() (*snapfake.Clientset, *int, *time.Time) {
+		client := snapfake.NewSimpleClientset()
+		gets := 0
+		var flipped time.Time
+		client.PrependReactor("get", "volumesnapshots", func(k8stesting.Action) (bool, runtime.Object, error) {
+			gets++
+			ready := gets != 2
+			if !ready {
+				flipped = time.Now()
+			}
+			return true, &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap"},
+				Status:     &snapshotv1.VolumeSnapshotStatus{ReadyToUse: &ready},
+			}, nil
+		})
+		return client, &gets, &flipped
+	}
+
+	t.Run("trusts the first ready by default", func(t *testing.T) {
+		client, gets, _ := flapping()
+		if _, err := waitForSnapshotReady(context.Background(), client, "apps", "snap", waitForReady); err != nil {
+			t.Fatalf("waitForSnapshotReady: %v", err)
+		}
+		if *gets != 1 {
+			t.Errorf("returned after %d gets, want 1", *gets)
+		}
+	})
+
+	t.Run("waits for the ready flag to be stable", func(t *testing.T) {
+		setFlag(t, &readinessStableFor, 100*time.Millisecond)
+		client, gets, flipped := flapping()
+		var err error
+		out := captureStdout(t, func() {
+			_, err = waitForSnapshotReady(context.Background(), client, "apps", "snap", waitForReady)
+		})
+		if err != nil {
+			t.Fatalf("waitForSnapshotReady: %v", err)
+		}
+		if *gets < 4 {
+			t.Errorf("returned after %d gets, want to poll on after the flip", *gets)
+		}
+		if stable := time.Since(*flipped); stable < readinessStableFor {
+			t.Errorf("returned %s after the snapshot flipped back, want at least %s", stable, readinessStableFor)
+		}
+		if !strings.Contains(out, "no longer ReadyToUse") {
+			t.Errorf("output misses the flip warning:\n%s", out)
+		}
+	})
+
+	t.Run("ignores the window when waiting for creation", func(t *testing.T) {
+		setFlag(t, &readinessStableFor, time.Hour)
+		client, gets, _ := flapping()
+		if _, err := waitForSnapshotReady(context.Background(), client, "apps", "snap", waitForCreated); err != nil {
+			t.Fatalf("waitForSnapshotReady: %v", err)
+		}
+		if *gets != 1 {
+			t.Errorf("returned after %d gets, want 1", *gets)
+		}
+	})
+}
+
+func TestFindExistingContent(t *testing.T) {
+	handle := "handle-1"
+	otherHandle := "handle-0"
+	newContent := func(handle, refName string, uid types.UID) *snapshotv1.VolumeSnapshotContent {
+		return &snapshotv1.VolumeSnapshotContent{
+			ObjectMeta: metav1.ObjectMeta{Name: "content"},
+			Spec: snapshotv1.VolumeSnapshotContentSpec{
+				Source:            snapshotv1.VolumeSnapshotContentSource{SnapshotHandle: &handle},
+				VolumeSnapshotRef: corev1.ObjectReference{Namespace: "apps", Name: refName, UID: uid},
+			},
+		}
+	}
+	liveSnapshot := &snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "live", UID: "live-uid"}}
+
+	tests := []struct {
+		name        string
+		objects     []runtime.Object
+		wantErr     bool
+		wantContent bool
+		wantRef     string
+	}{
+		{name: "missing"},
+		{
+			name:        "reused with the same handle and snapshot",
+			objects:     []runtime.Object{newContent(handle, "snap", "")},
+			wantContent: true,
+			wantRef:     "snap",
+		},
+		{
+			name:        "repointed from a deleted snapshot",
+			objects:     []runtime.Object{newContent(handle, "deleted", "deleted-uid")},
+			wantContent: true,
+			wantRef:     "snap",
+		},
+		{
+			name:    "bound to a live snapshot",
+			objects: []runtime.Object{newContent(handle, "live", "live-uid"), liveSnapshot},
+			wantErr: true,
+			wantRef: "live",
+		},
+		{
+			name:    "conflicting handle",
+			objects: []runtime.Object{newContent(otherHandle, "snap", "")},
+			wantErr: true,
+			wantRef: "snap",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := snapfake.NewSimpleClientset(tt.objects...)
+			content, err := findExistingContent(ctx, client, "content", "apps", "snap", handle)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if (content != nil) != tt.wantContent {
+				t.Errorf("content = %v, want one %v", content, tt.wantContent)
+			}
+			if tt.wantRef == "" {
+				return
+			}
+			stored, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, "content", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ref := stored.Spec.VolumeSnapshotRef; ref.Name != tt.wantRef || (ref.Name == "snap" && ref.UID != "") {
+				t.Errorf("snapshot reference = %s (uid %q), want %s", ref.Name, ref.UID, tt.wantRef)
+			}
+		})
+	}
+}
+
+func TestAvoidNameCollision(t *testing.T) {
+	tests := []struct {
+		name         string
+		dests        []*destination
+		destNS       string
+		explicitName bool
+		calls        int
+		want         string
+		wantErr      bool
+	}{
+		{name: "other cluster", dests: []*destination{{name: "dr"}}, calls: 1, want: "snap"},
+		{name: "same cluster", dests: []*destination{{name: "self", sameAsOrigin: true}}, calls: 1, want: "snap" + sameClusterSuffix},
+		{name: "same cluster, other namespace", dests: []*destination{{name: "self", sameAsOrigin: true}}, destNS: "dr", calls: 1, want: "snap"},
+		{name: "same cluster, explicit name", dests: []*destination{{name: "self", sameAsOrigin: true}}, explicitName: true, calls: 1, wantErr: true},
+		{name: "checked again", dests: []*destination{{name: "self", sameAsOrigin: true}}, calls: 3, want: "snap" + sameClusterSuffix},
+		{name: "several same-cluster destinations", dests: []*destination{{name: "dr"}, {name: "self", sameAsOrigin: true}, {name: "self-2", sameAsOrigin: true}}, calls: 1, want: "snap" + sameClusterSuffix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.explicitName {
+				setFlag(t, &destSnapshotName, "snap")
+			}
+			m := &migration{pvcNamespace: "apps", pvcName: "data", snapshotName: "snap", destNamespace: "apps", destSnapshotName: "snap"}
+			if tt.destNS != "" {
+				m.destNamespace = tt.destNS
+			}
+			c := &clusterClients{dests: tt.dests}
+			var err error
+			for i := 0; i < tt.calls && err == nil; i++ {
+				err = avoidNameCollision(c, m)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && m.destSnapshotName != tt.want {
+				t.Errorf("destination snapshot = %q, want %q", m.destSnapshotName, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDestSnapshotPair(t *testing.T) {
+	setFlag(t, &retryBaseDelay, time.Millisecond)
+	setFlag(t, &retryMaxDelay, 10*time.Millisecond)
+	setFlag(t, &maxRetries, 2)
+
+	tests := []struct {
+		name         string
+		gcTimes      int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "content kept", gcTimes: 0, wantAttempts: 1},
+		{name: "content deleted once", gcTimes: 1, wantAttempts: 2},
+		{name: "content deleted until the last retry", gcTimes: 2, wantAttempts: 3},
+		{name: "content always deleted", gcTimes: 5, wantAttempts: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := snapfake.NewSimpleClientset()
+			attempts := 0
+			// Garbage-collect the content right before its snapshot is created
+			client.PrependReactor("create", "volumesnapshots", func(k8stesting.Action) (bool, runtime.Object, error) {
+				attempts++
+				if attempts <= tt.gcTimes {
+					if err := client.Tracker().Delete(snapshotv1.SchemeGroupVersion.WithResource("volumesnapshotcontents"), "", "content"); err != nil {
+						t.Errorf("deleting the content: %v", err)
+					}
+				}
+				return false, nil, nil
+			})
+
+			d := &destination{name: "dr", snap: client}
+			m := &migration{pvcNamespace: "apps", pvcName: "data", snapshotName: "snap", destNamespace: "apps", destSnapshotName: "snap"}
+			origin := &originState{snapshotHandle: "handle", destHandle: "handle"}
+			st := &destState{contentName: "content"}
+			source := &snapshotv1.VolumeSnapshotContent{Spec: snapshotv1.VolumeSnapshotContentSpec{Driver: "driver"}}
+
+			err := retryDestSnapshotPair(context.Background(), d, m, origin, st, source, "", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, errContentGone) {
+				t.Errorf("err = %v, want errContentGone", err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if !tt.wantErr && !st.snapshotCreated {
+				t.Errorf("snapshot not recorded as created")
+			}
+		})
+	}
+}
+
+func TestCreateDestSnapshotPairContentGetError(t *testing.T) {
+	client := snapfake.NewSimpleClientset()
+	snapshotCreated := false
+	client.PrependReactor("create", "volumesnapshots", func(k8stesting.Action) (bool, runtime.Object, error) {
+		snapshotCreated = true
+		return false, nil, nil
+	})
+	client.PrependReactor("get", "volumesnapshotcontents", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if !snapshotCreated {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewInternalError(errors.New("etcd timeout"))
+	})
+
+	d := &destination{name: "dr", snap: client}
+	m := &migration{pvcNamespace: "apps", pvcName: "data", snapshotName: "snap", destNamespace: "apps", destSnapshotName: "snap"}
+	origin := &originState{snapshotHandle: "handle", destHandle: "handle"}
+	st := &destState{contentName: "content"}
+	source := &snapshotv1.VolumeSnapshotContent{Spec: snapshotv1.VolumeSnapshotContentSpec{Driver: "driver"}}
+
+	err := createDestSnapshotPair(context.Background(), d, m, origin, st, source, "", "")
+	if err == nil || errors.Is(err, errContentGone) || !strings.Contains(err.Error(), "etcd timeout") {
+		t.Fatalf("err = %v, want the get error", err)
+	}
+	// The snapshot is kept for cleanup, not deleted as if the content was gone
+	if !st.snapshotCreated {
+		t.Error("snapshot not recorded as created")
+	}
+	if _, err := client.SnapshotV1().VolumeSnapshots("apps").Get(context.Background(), "snap", metav1.GetOptions{}); err != nil {
+		t.Errorf("destination snapshot: %v", err)
+	}
+}
+
+func TestParsePVCRef(t *testing.T) {
+	tests := []struct {
+		ref       string
+		wantNS    string
+		wantName  string
+		wantError bool
+	}{
+		{ref: "data", wantNS: "default", wantName: "data"},
+		{ref: "apps/data", wantNS: "apps", wantName: "data"},
+		{ref: "apps/data.v2", wantNS: "apps", wantName: "data.v2"},
+		{ref: "", wantError: true},
+		{ref: "/data", wantError: true},
+		{ref: "apps/", wantError: true},
+		{ref: "/", wantError: true},
+		{ref: "apps/data/extra", wantError: true},
+		{ref: "apps//data", wantError: true},
+		{ref: "Apps/data", wantError: true},
+		{ref: "apps/Data", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ns, name, err := parsePVCRef("pvc", tt.ref, "default")
+			if (err != nil) != tt.wantError {
+				t.Fatalf("err = %v, want error %v", err, tt.wantError)
+			}
+			if ns != tt.wantNS || name != tt.wantName {
+				t.Errorf("parsePVCRef(%q) = %q, %q, want %q, %q", tt.ref, ns, name, tt.wantNS, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	now := time.Unix(1736471529, 0)
+	format := regexp.MustCompile(`^1736471529-[a-z0-9]{5}$`)
+
+	seen := make(map[string]bool)
+	// 27^5 suffixes make a collision among a few IDs negligible
+	for i := 0; i < 10; i++ {
+		id := newRunID(now)
+		if !format.MatchString(id) {
+			t.Fatalf("run ID %q does not match %s", id, format)
+		}
+		if errs := validation.IsDNS1123Label(id); len(errs) > 0 {
+			t.Fatalf("run ID %q is not a valid name part: %v", id, errs)
+		}
+		if seen[id] {
+			t.Fatalf("run ID %q generated twice within the same second", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestReplaceOutdatedDestSnapshotRefuses(t *testing.T) {
+	setFlag(t, &updateExistingSnapshot, true)
+	setFlag(t, &pollInterval, 10*time.Millisecond)
+	managed := map[string]string{managedByLabel: managedBy}
+	oldHandle, contentName, pvcName := "old-handle", "snapcontent-snap", "data"
+	content := func(labels map[string]string, policy snapshotv1.DeletionPolicy) *snapshotv1.VolumeSnapshotContent {
+		return &snapshotv1.VolumeSnapshotContent{
+			ObjectMeta: metav1.ObjectMeta{Name: contentName, Labels: labels},
+			Spec: snapshotv1.VolumeSnapshotContentSpec{
+				Source:         snapshotv1.VolumeSnapshotContentSource{SnapshotHandle: &oldHandle},
+				DeletionPolicy: policy,
+			},
+		}
+	}
+	snapshot := func(labels map[string]string, source snapshotv1.VolumeSnapshotSource) *snapshotv1.VolumeSnapshot {
+		return &snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "snap", Labels: labels},
+			Spec:       snapshotv1.VolumeSnapshotSpec{Source: source},
+		}
+	}
+	preBound := snapshotv1.VolumeSnapshotSource{VolumeSnapshotContentName: &contentName}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    string
+	}{
+		{
+			name:    "unlabeled snapshot",
+			objects: []runtime.Object{snapshot(nil, preBound), content(managed, snapshotv1.VolumeSnapshotContentRetain)},
+			want:    "VolumeSnapshot apps/snap is not labeled",
+		},
+		{
+			name:    "dynamic snapshot",
+			objects: []runtime.Object{snapshot(managed, snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: &pvcName})},
+			want:    "VolumeSnapshot apps/snap is not pre-bound",
+		},
+		{
+			name:    "unlabeled content",
+			objects: []runtime.Object{snapshot(managed, preBound), content(nil, snapshotv1.VolumeSnapshotContentRetain)},
+			want:    "VolumeSnapshotContent snapcontent-snap is not labeled",
+		},
+		{
+			name:    "content with Delete policy",
+			objects: []runtime.Object{snapshot(managed, preBound), content(managed, snapshotv1.VolumeSnapshotContentDelete)},
+			want:    "VolumeSnapshotContent snapcontent-snap has DeletionPolicy Delete",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := snapfake.NewSimpleClientset(tt.objects...)
+			d := &destination{name: "dr", snap: client}
+			err := replaceOutdatedDestSnapshot(context.Background(), d, "apps", "snap", contentName, "new-handle")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.want)
+			}
+			if _, err := client.SnapshotV1().VolumeSnapshots("apps").Get(context.Background(), "snap", metav1.GetOptions{}); err != nil {
+				t.Errorf("snapshot was deleted: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// serverMetadataFields are the metadata fields the API server assigns, left
+// out of the written manifests.
+var serverMetadataFields = []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink", "finalizers"}
+
+// controllerAnnotationPrefixes are the prefixes of the annotations controllers
+// set while binding and provisioning, left out of the written manifests.
+var controllerAnnotationPrefixes = []string{"pv.kubernetes.io/", "volume.kubernetes.io/", "volume.beta.kubernetes.io/"}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// writeDestManifests writes, with --manifests-out, the destination objects a
+// successful migration created as YAML files, read back from the cluster so
+// that they include the defaults it resolved. Objects already deleted, e.g.
+// with --delete-snapshots, are skipped. The migration has already succeeded,
+// so a failure is only a warning.
+func writeDestManifests(ctx context.Context, d *destination, m *migration, st *destState) {
+	if manifestsOut == "" {
+		return
+	}
+
+	var objs []runtime.Object
+	add := func(obj runtime.Object, err error) {
+		switch {
+		case err == nil:
+			objs = append(objs, obj)
+		case !apierrors.IsNotFound(err):
+			fmt.Fprintf(stepOut, "%s⚠ Warning: Failed to read an object back for --manifests-out: %v\n", d.prefix, err)
+		}
+	}
+	add(d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, st.contentName, metav1.GetOptions{}))
+	add(d.snap.SnapshotV1().VolumeSnapshots(m.destNamespace).Get(ctx, m.destSnapshotName, metav1.GetOptions{}))
+	if st.copy != nil {
+		add(d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, st.copy.contentName, metav1.GetOptions{}))
+		add(d.snap.SnapshotV1().VolumeSnapshots(m.destPVCNamespace).Get(ctx, m.destSnapshotName, metav1.GetOptions{}))
+	}
+	if createPVC {
+		for _, name := range m.destPVCNames() {
+			add(d.k8s.CoreV1().PersistentVolumeClaims(m.destPVCNamespace).Get(ctx, name, metav1.GetOptions{}))
+		}
+	}
+
+	dir := filepath.Join(manifestsOut, unsafePathChars.ReplaceAllString(d.name, "_"))
+	for _, obj := range objs {
+		path, err := writeManifest(dir, obj)
+		if err != nil {
+			fmt.Fprintf(stepOut, "%s⚠ Warning: Failed to write manifest: %v\n", d.prefix, err)
+			continue
+		}
+		fmt.Fprintf(stepOut, "%sWrote manifest %s\n", d.prefix, path)
+	}
+}
+
+// writeManifest writes obj without its server-assigned fields and status to
+// <dir>/<kind>/<namespace>/<name>.yaml, or <dir>/<kind>/<name>.yaml for a
+// cluster-scoped object, and returns the path.
+func writeManifest(dir string, obj runtime.Object) (string, error) {
+	manifest, err := cleanManifest(obj)
+	if err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(manifest.Object)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, strings.ToLower(manifest.GetKind()), manifest.GetNamespace(), manifest.GetName()+".yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// cleanManifest converts obj to a manifest that can be applied again: with its
+// apiVersion and kind, and without status, server-assigned metadata and the
+// annotations of controllers.
+func cleanManifest(obj runtime.Object) (*unstructured.Unstructured, error) {
+	obj = obj.DeepCopyObject()
+	switch o := obj.(type) {
+	case *snapshotv1.VolumeSnapshot:
+		o.SetGroupVersionKind(snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshot"))
+	case *snapshotv1.VolumeSnapshotContent:
+		o.SetGroupVersionKind(snapshotv1.SchemeGroupVersion.WithKind("VolumeSnapshotContent"))
+	case *corev1.PersistentVolumeClaim:
+		o.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	unstructured.RemoveNestedField(u.Object, "status")
+	for _, field := range serverMetadataFields {
+		unstructured.RemoveNestedField(u.Object, "metadata", field)
+	}
+
+	annotations := u.GetAnnotations()
+	for key := range annotations {
+		for _, prefix := range controllerAnnotationPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(annotations, key)
+			}
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	u.SetAnnotations(annotations)
+	return u, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCleanManifest(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "data",
+			Namespace:         "apps",
+			UID:               "1234",
+			ResourceVersion:   "42",
+			CreationTimestamp: metav1.Now(),
+			Finalizers:        []string{"kubernetes.io/pvc-protection"},
+			ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "snapshift"}},
+			Annotations: map[string]string{
+				"pv.kubernetes.io/bind-completed":          "yes",
+				"volume.kubernetes.io/storage-provisioner": "fake.csi.snapshift.io",
+				annotationSourcePVC:                        "apps/data",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv-1"},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+		},
+	}
+
+	manifest, err := cleanManifest(pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := yaml.Marshal(manifest.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{"apiVersion: v1", "kind: PersistentVolumeClaim", "volumeName: pv-1", annotationSourcePVC} {
+		if !strings.Contains(got, want) {
+			t.Errorf("manifest misses %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"uid", "resourceVersion", "creationTimestamp", "finalizers", "managedFields", "status", "pv.kubernetes.io", "storage-provisioner"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("manifest keeps %q:\n%s", unwanted, got)
+		}
+	}
+	if pvc.UID != "1234" || pvc.Status.Phase != corev1.ClaimBound {
+		t.Errorf("cleanManifest modified the object it was given")
+	}
+}
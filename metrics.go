@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// metricsRegistry holds the metrics of this run. They are only exported when
+// --pushgateway is set.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	migrationSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "snapshift_migration_success",
+		Help: "Whether the migration of a PVC succeeded (1) or failed (0).",
+	}, []string{"namespace", "pvc"})
+
+	migrationDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "snapshift_migration_duration_seconds",
+		Help: "Duration of the migration of a PVC.",
+	}, []string{"namespace", "pvc"})
+
+	phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "snapshift_phase_duration_seconds",
+		Help:    "Duration of each migration phase.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+	}, []string{"phase"})
+
+	migrationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "snapshift_migrations_total",
+		Help: "Number of PVC migrations by result: started, succeeded or failed.",
+	}, []string{"result"})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "snapshift_last_run_timestamp_seconds",
+		Help: "Time the run finished, as a Unix timestamp.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(migrationSuccess, migrationDuration, phaseDuration, migrationsTotal, lastRun)
+}
+
+// startMigration counts a PVC migration as started.
+func startMigration() {
+	migrationsTotal.WithLabelValues("started").Inc()
+}
+
+// recordMigration records the outcome and duration of a PVC migration.
+func recordMigration(m *migration, start time.Time, err error) {
+	success, result := 1.0, "succeeded"
+	if err != nil {
+		success, result = 0, "failed"
+	}
+	migrationsTotal.WithLabelValues(result).Inc()
+	migrationSuccess.WithLabelValues(m.pvcNamespace, m.pvcName).Set(success)
+	migrationDuration.WithLabelValues(m.pvcNamespace, m.pvcName).Set(time.Since(start).Seconds())
+}
+
+// pushMetrics pushes the run's metrics to a Prometheus Pushgateway under the
+// snapshift job, grouped by an instance named after the host, or the run ID
+// when the hostname is unknown. Failures are only reported, so metrics
+// problems never fail a migration.
+func pushMetrics(url string) {
+	lastRun.SetToCurrentTime()
+
+	instance, err := os.Hostname()
+	if err != nil || instance == "" {
+		instance = fmt.Sprintf("run-%s", runID)
+	}
+
+	err = push.New(url, "snapshift").
+		Grouping("instance", instance).
+		Gatherer(metricsRegistry).
+		Push()
+	if err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Failed to push metrics to %s: %v\n", url, err)
+		return
+	}
+	fmt.Fprintf(stepOut, "Pushed metrics to %s\n", url)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPhaseDurationObserved(t *testing.T) {
+	count := func(phase string) uint64 {
+		t.Helper()
+		families, err := metricsRegistry.Gather()
+		if err != nil {
+			t.Fatalf("Gather: %v", err)
+		}
+		for _, mf := range families {
+			if mf.GetName() != "snapshift_phase_duration_seconds" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "phase" && l.GetValue() == phase {
+						return m.GetHistogram().GetSampleCount()
+					}
+				}
+			}
+		}
+		return 0
+	}
+
+	before := count("test-phase")
+	_, p := startPhase(context.Background(), "test-phase")
+	p.end(nil)
+	_, p = startPhase(context.Background(), "test-phase")
+	p.end(errors.New("failed"))
+
+	if got := count("test-phase") - before; got != 2 {
+		t.Errorf("observed %d samples for test-phase, want 2", got)
+	}
+}
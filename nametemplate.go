@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Snapshot name templates parsed from --origin-snapshot-name-template and
+// --dest-snapshot-name-template
+var (
+	originSnapshotNameTemplate *template.Template
+	destSnapshotNameTemplate   *template.Template
+)
+
+// snapshotNameData holds the placeholders of the snapshot name templates.
+type snapshotNameData struct {
+	// PVC and Namespace are the source PVC's
+	PVC       string
+	Namespace string
+	// Timestamp is the start of the run, as YYYYMMDD-hhmmss in UTC
+	Timestamp string
+	// RunID is shared by every PVC of the run, see newRunID
+	RunID string
+}
+
+// parseSnapshotNameTemplate parses the value of a snapshot name template flag,
+// rendering it once so that unknown placeholders are reported before anything
+// is created.
+func parseSnapshotNameTemplate(flag, text string) (*template.Template, error) {
+	t, err := template.New(flag).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+	}
+	if err := t.Execute(new(strings.Builder), snapshotNameData{}); err != nil {
+		return nil, fmt.Errorf("invalid --%s, the placeholders are {{.PVC}}, {{.Namespace}}, {{.Timestamp}} and {{.RunID}}: %w", flag, err)
+	}
+	return t, nil
+}
+
+// renderSnapshotName renders a snapshot name template for a source PVC and
+// checks that the result is a valid object name.
+func renderSnapshotName(t *template.Template, namespace, pvc string) (string, error) {
+	var name strings.Builder
+	err := t.Execute(&name, snapshotNameData{
+		PVC:       pvc,
+		Namespace: namespace,
+		Timestamp: runStart.UTC().Format("20060102-150405"),
+		RunID:     runID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render --%s for PVC %s/%s: %w", t.Name(), namespace, pvc, err)
+	}
+	if errs := validation.IsDNS1123Subdomain(name.String()); len(errs) > 0 {
+		return "", fmt.Errorf("--%s renders an invalid name %q for PVC %s/%s: %s", t.Name(), name.String(), namespace, pvc, strings.Join(errs, ", "))
+	}
+	return name.String(), nil
+}
+
+// applySnapshotNameTemplates names the snapshots of a run from
+// --origin-snapshot-name-template and --dest-snapshot-name-template, if set.
+// Without a destination template, destination snapshots keep following the
+// origin names. Destination contents are named after their snapshot and are
+// cluster-scoped, so every run checks that no two destination snapshots would
+// share a content.
+func applySnapshotNameTemplates(migrations []*migration) error {
+	originPVCs := make(map[string]string, len(migrations))
+	destPVCs := make(map[string]string, len(migrations))
+	for _, m := range migrations {
+		pvc := m.pvcNamespace + "/" + m.pvcName
+
+		if originSnapshotNameTemplate != nil {
+			name, err := renderSnapshotName(originSnapshotNameTemplate, m.pvcNamespace, m.pvcName)
+			if err != nil {
+				return err
+			}
+			key := m.pvcNamespace + "/" + name
+			if other, ok := originPVCs[key]; ok {
+				return fmt.Errorf("--origin-snapshot-name-template renders the same name %s for PVCs %s and %s, add a placeholder such as {{.PVC}}", key, other, pvc)
+			}
+			originPVCs[key] = pvc
+			if destSnapshotName == "" {
+				m.destSnapshotName = name
+			}
+			m.snapshotName = name
+		}
+
+		if destSnapshotNameTemplate != nil {
+			name, err := renderSnapshotName(destSnapshotNameTemplate, m.pvcNamespace, m.pvcName)
+			if err != nil {
+				return err
+			}
+			m.destSnapshotName = name
+		}
+
+		content := destContentName(m)
+		if other, ok := destPVCs[content]; ok {
+			if destSnapshotNameTemplate != nil || originSnapshotNameTemplate != nil {
+				return fmt.Errorf("the destination snapshots of PVCs %s and %s would both be bound to VolumeSnapshotContent %s, add a placeholder such as {{.PVC}} or {{.Namespace}} to the template", other, pvc, content)
+			}
+			return fmt.Errorf("the destination snapshots of PVCs %s and %s would both be bound to VolumeSnapshotContent %s", other, pvc, content)
+		}
+		destPVCs[content] = pvc
+	}
+	return nil
+}
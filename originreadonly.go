@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errOriginReadOnly is the error of a request that would change the origin
+// cluster with --origin-read-only.
+var errOriginReadOnly = errors.New("the origin cluster is read-only with --origin-read-only")
+
+// refuseWrites wraps a client transport so that every request other than a
+// read fails without being sent.
+func refuseWrites(rt http.RoundTripper) http.RoundTripper {
+	return &readOnlyRoundTripper{rt: rt}
+}
+
+type readOnlyRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func (r *readOnlyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.rt.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, fmt.Errorf("refusing %s %s: %w", req.Method, req.URL.Path, errOriginReadOnly)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	stdinLinesOnce sync.Once
+	stdinLines     chan error
+)
+
+// enterPresses returns a channel receiving each line read from stdin, as the
+// read error or nil, so that a pause can also end with the run's context. A
+// single reader serves every pause of a bulk run.
+var enterPresses = func() <-chan error {
+	stdinLinesOnce.Do(func() {
+		stdinLines = make(chan error)
+		go func() {
+			r := bufio.NewReader(os.Stdin)
+			for {
+				_, err := r.ReadString('\n')
+				stdinLines <- err
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+	return stdinLines
+}
+
+// pauseBeforeDests waits, with --pause-before-dest, for the operator to press
+// Enter once the origin snapshot handle is known and before anything is
+// created in the destinations, e.g. to copy the storage snapshot to another
+// account or region by hand. Ctrl-C or the end of --timeout abort the
+// migration instead.
+func pauseBeforeDests(ctx context.Context, m *migration, handle, destHandle string) error {
+	if !pauseBeforeDest {
+		return nil
+	}
+
+	fmt.Fprintf(stepOut, "\nOrigin snapshot %s/%s has snapshot handle %s\n", m.pvcNamespace, m.snapshotName, handle)
+	if destHandle != handle {
+		fmt.Fprintf(stepOut, "  Destination snapshot handle: %s\n", destHandle)
+	}
+	fmt.Fprintf(stepOut, "Paused before creating the destination resources, press Enter to continue or Ctrl-C to abort...")
+
+	select {
+	case err := <-enterPresses():
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation to continue: %w", err)
+		}
+		fmt.Fprintf(stepOut, "Continuing with the destinations\n")
+		return nil
+	case <-ctx.Done():
+		fmt.Fprintln(stepOut)
+		return fmt.Errorf("paused before the destinations: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pinContentRef sets the UID of the destination snapshot in the snapshot
+// reference of its content, so that a later snapshot reusing the name cannot
+// bind to the content. A content already pinned to another snapshot of that
+// name is an error.
+func pinContentRef(ctx context.Context, d *destination, namespace, name, contentName string) error {
+	snapshot, err := d.snap.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get destination snapshot: %w", err)
+	}
+	if snapshot.UID == "" {
+		return fmt.Errorf("destination snapshot %s/%s has no UID to pin its VolumeSnapshotContent to", namespace, name)
+	}
+	content, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, contentName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get destination VolumeSnapshotContent: %w", err)
+	}
+
+	switch uid := content.Spec.VolumeSnapshotRef.UID; uid {
+	case snapshot.UID:
+		// Already bound by the snapshot controller
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("VolumeSnapshotContent %s is pinned to another snapshot %s/%s (UID %s), not to %s", contentName, namespace, name, uid, snapshot.UID)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"volumeSnapshotRef": map[string]interface{}{"uid": snapshot.UID},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	printCommand(kubectl(d.kubectlFlags, fmt.Sprintf("patch volumesnapshotcontent %s --type merge -p '%s'", contentName, patch)))
+	if _, err := d.snap.SnapshotV1().VolumeSnapshotContents().Patch(ctx, contentName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to pin VolumeSnapshotContent %s to snapshot %s/%s: %w", contentName, namespace, name, err)
+	}
+	fmt.Fprintf(stepOut, "%s✓ Pinned VolumeSnapshotContent %s to snapshot UID %s\n", d.prefix, contentName, snapshot.UID)
+	return nil
+}
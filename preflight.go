@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// snapshotGroupVersion is the API served by the VolumeSnapshot CRDs.
+const snapshotGroupVersion = "snapshot.storage.k8s.io/v1"
+
+// snapshotResources are the resources the snapshot CRDs define.
+var snapshotResources = []string{"volumesnapshots", "volumesnapshotcontents", "volumesnapshotclasses"}
+
+// checkSnapshotCRDs verifies through discovery that the VolumeSnapshot,
+// VolumeSnapshotContent and VolumeSnapshotClass resources are served.
+func checkSnapshotCRDs(client kubernetes.Interface) error {
+	missing, err := missingSnapshotResources(client)
+	if err != nil {
+		return fmt.Errorf("%s is not served: %w", snapshotGroupVersion, err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s does not serve %s", snapshotGroupVersion, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// requireSnapshotCRDs fails early with an actionable message when the snapshot
+// CRDs are not installed in a cluster, instead of letting the first snapshot
+// call fail with "the server could not find the requested resource".
+func requireSnapshotCRDs(client kubernetes.Interface, cluster string) error {
+	missing, err := missingSnapshotResources(client)
+	if err != nil {
+		return fmt.Errorf("failed to discover %s in %s: %w", snapshotGroupVersion, cluster, err)
+	}
+	switch {
+	case len(missing) == len(snapshotResources):
+		return fmt.Errorf("VolumeSnapshot CRDs (snapshot.storage.k8s.io) are not installed in %s; install external-snapshotter", cluster)
+	case len(missing) > 0:
+		return fmt.Errorf("VolumeSnapshot CRDs (snapshot.storage.k8s.io) are incomplete in %s, %s not served; install external-snapshotter", cluster, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// missingSnapshotResources returns the snapshot resources a cluster does not
+// serve. All of them are missing when the group version is not served at all.
+func missingSnapshotResources(client kubernetes.Interface) ([]string, error) {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(snapshotGroupVersion)
+	if apierrors.IsNotFound(err) {
+		return snapshotResources, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	served := make(map[string]bool)
+	for _, r := range resources.APIResources {
+		served[r.Name] = true
+	}
+
+	var missing []string
+	for _, name := range snapshotResources {
+		if !served[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// snapshotControllerNamespaces are the namespaces the snapshot controller is
+// commonly deployed to, searched when Deployments cannot be listed in all
+// namespaces.
+var snapshotControllerNamespaces = []string{"kube-system", "snapshot-controller", "openshift-cluster-storage-operator"}
+
+// findSnapshotController looks for a snapshot controller Deployment with ready
+// replicas and returns its namespace/name, or "" if none is running. Without
+// the permission to list Deployments in all namespaces, only the common
+// namespaces are searched.
+func findSnapshotController(ctx context.Context, client kubernetes.Interface) (string, error) {
+	deployments, err := client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err == nil {
+		return readySnapshotController(deployments.Items), nil
+	}
+	if !apierrors.IsForbidden(err) {
+		return "", fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	searched := 0
+	for _, namespace := range snapshotControllerNamespaces {
+		deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		if apierrors.IsForbidden(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to list deployments in %s: %w", namespace, err)
+		}
+		searched++
+		if controller := readySnapshotController(deployments.Items); controller != "" {
+			return controller, nil
+		}
+	}
+	if searched == 0 {
+		return "", fmt.Errorf("not allowed to list deployments in any of %s", strings.Join(snapshotControllerNamespaces, ", "))
+	}
+
+	return "", nil
+}
+
+// readySnapshotController returns the namespace/name of the first snapshot
+// controller Deployment with ready replicas, or "".
+func readySnapshotController(deployments []appsv1.Deployment) string {
+	for _, d := range deployments {
+		if strings.Contains(d.Name, "snapshot-controller") && d.Status.ReadyReplicas > 0 {
+			return fmt.Sprintf("%s/%s", d.Namespace, d.Name)
+		}
+	}
+	return ""
+}
+
+// checkSnapshotController warns, or fails with --require-snapshot-controller,
+// when no running snapshot controller is found in a destination cluster: the
+// usual reason for a destination snapshot that never becomes ready. Managed
+// clusters may run the controller out of sight, on the control plane.
+func checkSnapshotController(ctx context.Context, client kubernetes.Interface, cluster string) error {
+	controller, err := findSnapshotController(ctx, client)
+	var msg string
+	switch {
+	case err != nil:
+		msg = fmt.Sprintf("cannot check for a running snapshot controller in %s: %v", cluster, err)
+	case controller == "":
+		msg = fmt.Sprintf("no running snapshot-controller Deployment found in %s; without one, destination snapshots never become ready", cluster)
+	default:
+		return nil
+	}
+	if requireSnapshotController {
+		return fmt.Errorf("%s", msg)
+	}
+	fmt.Fprintf(stepOut, "⚠ Warning: %s\n", msg)
+	return nil
+}
+
+// permission is an API access snapshift needs in a cluster.
+type permission struct {
+	verb      string
+	group     string
+	resource  string
+	namespace string
+}
+
+func (p permission) String() string {
+	resource := p.resource
+	if p.group != "" {
+		resource = p.resource + "." + p.group
+	}
+	if p.namespace != "" {
+		return fmt.Sprintf("%s %s in %s", p.verb, resource, p.namespace)
+	}
+	return fmt.Sprintf("%s %s", p.verb, resource)
+}
+
+// originPermissions returns the access snapshift needs in the origin cluster.
+func originPermissions(namespace string) []permission {
+	return []permission{
+		{verb: "get", resource: "persistentvolumeclaims", namespace: namespace},
+		{verb: "create", group: "snapshot.storage.k8s.io", resource: "volumesnapshots", namespace: namespace},
+		{verb: "get", group: "snapshot.storage.k8s.io", resource: "volumesnapshots", namespace: namespace},
+		{verb: "get", group: "snapshot.storage.k8s.io", resource: "volumesnapshotcontents"},
+	}
+}
+
+// destPermissions returns the access snapshift needs in a destination cluster.
+func destPermissions(namespace string) []permission {
+	return []permission{
+		{verb: "create", group: "snapshot.storage.k8s.io", resource: "volumesnapshotcontents"},
+		{verb: "create", group: "snapshot.storage.k8s.io", resource: "volumesnapshots", namespace: namespace},
+		{verb: "get", group: "snapshot.storage.k8s.io", resource: "volumesnapshots", namespace: namespace},
+		{verb: "create", resource: "persistentvolumeclaims", namespace: namespace},
+	}
+}
+
+// checkPermissions asks the API server whether the current user has each
+// permission and returns the denied ones.
+func checkPermissions(ctx context.Context, client kubernetes.Interface, perms []permission) ([]permission, error) {
+	var denied []permission
+	for _, p := range perms {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:      p.verb,
+					Group:     p.group,
+					Resource:  p.resource,
+					Namespace: p.namespace,
+				},
+			},
+		}
+
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check permission to %s: %w", p, err)
+		}
+		if !result.Status.Allowed {
+			denied = append(denied, p)
+		}
+	}
+
+	return denied, nil
+}
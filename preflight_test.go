@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func snapshotControllerDeployment(namespace, name string, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+// forbidClusterWideDeployments denies listing Deployments in all namespaces,
+// like a user only granted access to some namespaces.
+func forbidClusterWideDeployments(client *k8sfake.Clientset) {
+	client.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() != metav1.NamespaceAll {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", nil)
+	})
+}
+
+func TestFindSnapshotController(t *testing.T) {
+	ctx := context.Background()
+
+	client := k8sfake.NewSimpleClientset(
+		snapshotControllerDeployment("storage", "snapshot-controller", 0),
+		snapshotControllerDeployment("kube-system", "snapshot-controller", 2),
+	)
+	if got, err := findSnapshotController(ctx, client); err != nil || got != "kube-system/snapshot-controller" {
+		t.Errorf("findSnapshotController = %q, %v, want the ready Deployment", got, err)
+	}
+
+	client = k8sfake.NewSimpleClientset(snapshotControllerDeployment("snapshot-controller", "snapshot-controller", 1))
+	forbidClusterWideDeployments(client)
+	if got, err := findSnapshotController(ctx, client); err != nil || got != "snapshot-controller/snapshot-controller" {
+		t.Errorf("findSnapshotController without cluster-wide access = %q, %v, want the common namespaces searched", got, err)
+	}
+
+	client = k8sfake.NewSimpleClientset()
+	client.PrependReactor("list", "deployments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", nil)
+	})
+	if _, err := findSnapshotController(ctx, client); err == nil || !strings.Contains(err.Error(), "not allowed to list deployments") {
+		t.Errorf("findSnapshotController without any access = %v, want an error", err)
+	}
+}
+
+func TestCheckSnapshotController(t *testing.T) {
+	ctx := context.Background()
+	client := k8sfake.NewSimpleClientset()
+
+	if err := checkSnapshotController(ctx, client, "destination cluster (dr)"); err != nil {
+		t.Errorf("checkSnapshotController = %v, want only a warning", err)
+	}
+
+	setFlag(t, &requireSnapshotController, true)
+	err := checkSnapshotController(ctx, client, "destination cluster (dr)")
+	if err == nil || !strings.Contains(err.Error(), "no running snapshot-controller Deployment found in destination cluster (dr)") {
+		t.Errorf("checkSnapshotController with --require-snapshot-controller = %v, want an error", err)
+	}
+
+	client = k8sfake.NewSimpleClientset(snapshotControllerDeployment("kube-system", "snapshot-controller", 1))
+	if err := checkSnapshotController(ctx, client, "destination cluster (dr)"); err != nil {
+		t.Errorf("checkSnapshotController with a running controller = %v", err)
+	}
+}
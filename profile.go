@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	profileName  string
+	profilesFile string
+)
+
+// profiles is the schema of a profiles file: named origin and destination
+// cluster pairs with the defaults of their migrations.
+type profiles struct {
+	Profiles map[string]profile `json:"profiles"`
+}
+
+// profile is one cluster pair of a profiles file. Each field is the default
+// of the flag of the same name, which still overrides it.
+type profile struct {
+	OriginKubeconfig  string `json:"originKubeconfig,omitempty"`
+	OriginContext     string `json:"originContext,omitempty"`
+	DestKubeconfig    string `json:"destKubeconfig,omitempty"`
+	DestContext       string `json:"destContext,omitempty"`
+	Namespace         string `json:"namespace,omitempty"`
+	DestNamespace     string `json:"destNamespace,omitempty"`
+	SnapshotClass     string `json:"snapshotClass,omitempty"`
+	DestSnapshotClass string `json:"destSnapshotClass,omitempty"`
+	ClassMapFile      string `json:"classMapFile,omitempty"`
+}
+
+// defaultProfilesFile returns ~/.snapshift/profiles.yaml.
+func defaultProfilesFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the profiles file: %w", err)
+	}
+	return filepath.Join(home, ".snapshift", "profiles.yaml"), nil
+}
+
+// loadProfile reads the named profile from a profiles file. Unknown fields are
+// rejected so a misspelled setting is not silently ignored.
+func loadProfile(path, name string) (*profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles file: %w", err)
+	}
+
+	var p profiles
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid profiles file %s: %w", path, err)
+	}
+
+	prof, ok := p.Profiles[name]
+	if !ok && len(p.Profiles) == 0 {
+		return nil, fmt.Errorf("profile %q not found, %s defines no profiles", name, path)
+	}
+	if !ok {
+		names := make([]string, 0, len(p.Profiles))
+		for n := range p.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found in %s (profiles: %s)", name, path, strings.Join(names, ", "))
+	}
+	if prof.OriginKubeconfig == "" && prof.OriginContext == "" && prof.DestKubeconfig == "" && prof.DestContext == "" {
+		return nil, fmt.Errorf("invalid profiles file %s: profile %q has no origin or destination cluster", path, name)
+	}
+
+	prof.OriginKubeconfig = expandKubeconfig(prof.OriginKubeconfig)
+	prof.DestKubeconfig = expandKubeconfig(prof.DestKubeconfig)
+	prof.ClassMapFile = expandHome(prof.ClassMapFile)
+	return &prof, nil
+}
+
+// applyProfile sets the flags of cmd left unset to the values of the
+// --profile, then checks that the kubeconfigs and contexts it gives resolve.
+func applyProfile(cmd *cobra.Command) error {
+	if profileName == "" {
+		return nil
+	}
+	path := profilesFile
+	if path == "" {
+		var err error
+		if path, err = defaultProfilesFile(); err != nil {
+			return err
+		}
+	}
+	prof, err := loadProfile(path, profileName)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []struct{ name, value string }{
+		{"origin-kubeconfig", prof.OriginKubeconfig},
+		{"origin-context", prof.OriginContext},
+		{"dest-kubeconfig", prof.DestKubeconfig},
+		{"dest-context", prof.DestContext},
+		{"namespace", prof.Namespace},
+		{"dest-namespace", prof.DestNamespace},
+		{"snapshot-class", prof.SnapshotClass},
+		{"dest-snapshot-class", prof.DestSnapshotClass},
+		{"class-map-file", prof.ClassMapFile},
+	} {
+		// Subcommands lack the flags of the migration itself
+		flag := cmd.Flags().Lookup(f.name)
+		if f.value == "" || flag == nil || flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(f.name, f.value); err != nil {
+			return fmt.Errorf("profile %q: invalid %s: %w", profileName, f.name, err)
+		}
+	}
+
+	if prof.OriginKubeconfig != "" || prof.OriginContext != "" {
+		if _, err := loadKubeconfig(originKubeconfig, originContext); err != nil {
+			return fmt.Errorf("profile %q: origin cluster (%s): %w", profileName, clusterName(originKubeconfig, originContext), err)
+		}
+	}
+	if prof.DestKubeconfig != "" || prof.DestContext != "" {
+		targets, err := destinationTargets(destKubeconfigs, destContexts)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", profileName, err)
+		}
+		for _, t := range targets {
+			if _, err := loadKubeconfig(t.kubeconfig, t.context); err != nil {
+				return fmt.Errorf("profile %q: destination cluster (%s): %w", profileName, t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expandKubeconfig expands ~ in every path of a colon-separated kubeconfig
+// list.
+func expandKubeconfig(value string) string {
+	paths := filepath.SplitList(value)
+	for i, p := range paths {
+		paths[i] = expandHome(p)
+	}
+	return strings.Join(paths, string(filepath.ListSeparator))
+}
+
+// expandHome replaces a leading ~/ in path with the home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
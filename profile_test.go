@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+const profileKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+- name: dr
+  cluster:
+    server: https://dr.example.com
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+- name: dr
+  context:
+    cluster: dr
+    user: admin
+current-context: prod
+`
+
+// profileCommand returns a command with the flags a profile sets, bound to
+// their globals, parsed from args.
+func profileCommand(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	setFlag(t, &originKubeconfig, "")
+	setFlag(t, &originContext, "")
+	setFlag(t, &destKubeconfigs, nil)
+	setFlag(t, &destContexts, nil)
+	setFlag(t, &pvcNamespace, "")
+	setFlag(t, &destNamespace, "")
+	setFlag(t, &snapshotClass, "")
+	setFlag(t, &destSnapClass, "")
+	setFlag(t, &classMapFile, "")
+
+	cmd := &cobra.Command{Use: "snapshift"}
+	cmd.Flags().StringVar(&originKubeconfig, "origin-kubeconfig", "", "")
+	cmd.Flags().StringVar(&originContext, "origin-context", "", "")
+	cmd.Flags().StringArrayVar(&destKubeconfigs, "dest-kubeconfig", nil, "")
+	cmd.Flags().StringArrayVar(&destContexts, "dest-context", nil, "")
+	cmd.Flags().StringVarP(&pvcNamespace, "namespace", "n", "default", "")
+	cmd.Flags().StringVar(&destNamespace, "dest-namespace", "", "")
+	cmd.Flags().StringVar(&snapshotClass, "snapshot-class", "", "")
+	cmd.Flags().StringVar(&destSnapClass, "dest-snapshot-class", "", "")
+	cmd.Flags().StringVar(&classMapFile, "class-map-file", "", "")
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestApplyProfile(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "config")
+	if err := os.WriteFile(kubeconfig, []byte(profileKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "profiles.yaml")
+	profilesYAML := `profiles:
+  prod-to-dr:
+    originKubeconfig: ` + kubeconfig + `
+    originContext: prod
+    destKubeconfig: ` + kubeconfig + `
+    destContext: dr
+    namespace: apps
+    snapshotClass: csi-snapclass
+    destSnapshotClass: dr-snapclass
+  broken:
+    originKubeconfig: ` + kubeconfig + `
+    originContext: staging
+`
+	if err := os.WriteFile(path, []byte(profilesYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &profilesFile, path)
+
+	t.Run("defaults", func(t *testing.T) {
+		setFlag(t, &profileName, "prod-to-dr")
+		if err := applyProfile(profileCommand(t)); err != nil {
+			t.Fatalf("applyProfile: %v", err)
+		}
+		if originKubeconfig != kubeconfig || originContext != "prod" || len(destContexts) != 1 || destContexts[0] != "dr" {
+			t.Errorf("clusters = %s/%s -> %v/%v", originKubeconfig, originContext, destKubeconfigs, destContexts)
+		}
+		if pvcNamespace != "apps" || snapshotClass != "csi-snapclass" || destSnapClass != "dr-snapclass" {
+			t.Errorf("namespace = %q, classes = %q, %q", pvcNamespace, snapshotClass, destSnapClass)
+		}
+	})
+
+	t.Run("flags override", func(t *testing.T) {
+		setFlag(t, &profileName, "prod-to-dr")
+		if err := applyProfile(profileCommand(t, "--namespace", "web", "--origin-context", "dr", "--snapshot-class", "fast")); err != nil {
+			t.Fatalf("applyProfile: %v", err)
+		}
+		if pvcNamespace != "web" || originContext != "dr" || snapshotClass != "fast" {
+			t.Errorf("namespace = %q, origin context = %q, class = %q, want the flags", pvcNamespace, originContext, snapshotClass)
+		}
+		if destSnapClass != "dr-snapclass" {
+			t.Errorf("dest class = %q, want the profile's", destSnapClass)
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		setFlag(t, &profileName, "prod-to-staging")
+		err := applyProfile(profileCommand(t))
+		if err == nil || !strings.Contains(err.Error(), `profile "prod-to-staging" not found`) || !strings.Contains(err.Error(), "broken, prod-to-dr") {
+			t.Errorf("applyProfile = %v, want the profile not found and the known ones", err)
+		}
+	})
+
+	t.Run("unresolved context", func(t *testing.T) {
+		setFlag(t, &profileName, "broken")
+		err := applyProfile(profileCommand(t))
+		if err == nil || !strings.Contains(err.Error(), "origin cluster") || !strings.Contains(err.Error(), "staging") {
+			t.Errorf("applyProfile = %v, want the missing origin context", err)
+		}
+	})
+}
+
+func TestLoadProfileRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  dr:\n    originContex: prod\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadProfile(path, "dr"); err == nil || !strings.Contains(err.Error(), "originContex") {
+		t.Errorf("loadProfile = %v, want the unknown field", err)
+	}
+}
+
+func TestExpandKubeconfig(t *testing.T) {
+	t.Setenv("HOME", "/home/ops")
+	got := expandKubeconfig("~/.kube/prod" + string(filepath.ListSeparator) + "/etc/kube/dr")
+	want := "/home/ops/.kube/prod" + string(filepath.ListSeparator) + "/etc/kube/dr"
+	if got != want {
+		t.Errorf("expandKubeconfig = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// progressBarWidth is the number of cells of the --progress-bar bar.
+const progressBarWidth = 30
+
+// waitBar renders a wait as a single line updated in place, with the time
+// elapsed against the time left before the timeout, when --progress-bar is set
+// and the step output is a terminal.
+type waitBar struct {
+	what     string
+	status   string
+	start    time.Time
+	deadline time.Time
+	ticker   *time.Ticker
+	drawn    bool
+}
+
+// newWaitBar returns a progress bar for a wait, or nil when the wait should be
+// reported with plain status lines instead.
+func newWaitBar(ctx context.Context, what string) *waitBar {
+	if !progressBar || !isTerminalWriter(stepOut) {
+		return nil
+	}
+	b := &waitBar{what: what, start: time.Now(), ticker: time.NewTicker(time.Second)}
+	b.deadline, _ = ctx.Deadline()
+	return b
+}
+
+// tick fires when the bar should be redrawn. It never fires without a bar, so
+// it can always be selected on.
+func (b *waitBar) tick() <-chan time.Time {
+	if b == nil {
+		return nil
+	}
+	return b.ticker.C
+}
+
+// update records the last polled status, if any, and redraws the bar.
+func (b *waitBar) update(status string) {
+	if status != "" {
+		b.status = status
+	}
+
+	elapsed := time.Since(b.start).Round(time.Second)
+	line := fmt.Sprintf("  Waiting for %s %s", b.what, elapsed)
+	if !b.deadline.IsZero() {
+		total := b.deadline.Sub(b.start)
+		filled := int(float64(progressBarWidth) * float64(elapsed) / float64(total))
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+		line = fmt.Sprintf("  Waiting for %s [%s%s] %s/%s", b.what,
+			strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), elapsed, total.Round(time.Second))
+	}
+	if b.status != "" {
+		line += " " + b.status
+	}
+	// Clear the rest of the previous, possibly longer, line
+	fmt.Fprintf(stepOut, "\r%s\033[K", line)
+	b.drawn = true
+}
+
+// done ends the bar's line, so that the next output starts on its own line.
+// It is safe to call on a nil bar and more than once.
+func (b *waitBar) done() {
+	if b == nil {
+		return
+	}
+	b.ticker.Stop()
+	if b.drawn {
+		fmt.Fprintln(stepOut)
+		b.drawn = false
+	}
+}
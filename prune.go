@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	pruneKubeconfig string
+	pruneContext    string
+	prunePVC        string
+	pruneKeep       int
+	pruneKeepWithin time.Duration
+	pruneDryRun     bool
+	pruneYes        bool
+	pruneStorage    bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old snapshift-managed VolumeSnapshots",
+	Long: `prune deletes the VolumeSnapshots created by snapshift in the namespace given
+with --namespace, keeping for each source PVC the --keep most recent ones and
+those younger than --keep-within. Snapshots are deleted oldest first. When the bound
+VolumeSnapshotContent has DeletionPolicy Retain, which snapshift sets so that
+the snapshot handle can be shared between clusters, the content is deleted as
+well and the snapshot in the storage backend is left in place, as other
+clusters may still reference it. Snapshots whose content has DeletionPolicy
+Delete, such as origin snapshots, are skipped: deleting them destroys the
+storage snapshot, along with every destination restored from its handle.
+--delete-storage-snapshots deletes them anyway.`,
+	Args: cobra.NoArgs,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().StringVar(&pruneKubeconfig, "kubeconfig", "", "Path to the kubeconfig of the cluster to prune")
+	pruneCmd.Flags().StringVar(&pruneContext, "context", "", "Context of the cluster to prune")
+	pruneCmd.Flags().StringVar(&prunePVC, "pvc", "", "Only prune the snapshots of this source PVC")
+	pruneCmd.Flags().IntVar(&pruneKeep, "keep", 0, "Number of most recent snapshots to keep per PVC")
+	pruneCmd.Flags().DurationVar(&pruneKeepWithin, "keep-within", 0, "Keep snapshots younger than this duration, e.g. 168h")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Only print the snapshots that would be deleted")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Delete without asking for confirmation")
+	pruneCmd.Flags().BoolVar(&pruneStorage, "delete-storage-snapshots", false, "Also prune snapshots whose VolumeSnapshotContent has DeletionPolicy Delete, destroying their storage snapshot even if destination clusters still use its handle")
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	if pruneKeep < 0 || pruneKeepWithin < 0 {
+		return fmt.Errorf("--keep and --keep-within must not be negative")
+	}
+	if pruneKeep == 0 && pruneKeepWithin == 0 {
+		return fmt.Errorf("at least one of --keep or --keep-within is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, snapClient, err := createClients(pruneKubeconfig, pruneContext, clientOverrides{})
+	if err != nil {
+		return fmt.Errorf("failed to create clients: %w", err)
+	}
+
+	list, err := snapClient.SnapshotV1().VolumeSnapshots(pvcNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: managedByLabel + "=" + managedBy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list VolumeSnapshots in namespace %s: %w", pvcNamespace, err)
+	}
+
+	expired, err := skipStorageSnapshots(ctx, snapClient, expiredSnapshots(list.Items, time.Now()))
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		fmt.Printf("No snapshots to prune in namespace %s\n", pvcNamespace)
+		return nil
+	}
+
+	fmt.Printf("Snapshots to delete in namespace %s:\n", pvcNamespace)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  NAME\tSOURCE PVC\tCREATED\t\n")
+	for _, s := range expired {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t\n", s.Name, snapshotSourcePVC(&s), s.CreationTimestamp.Format(time.RFC3339))
+	}
+	w.Flush()
+
+	if pruneDryRun {
+		fmt.Printf("\nDry run: %d snapshot(s) would be deleted\n", len(expired))
+		return nil
+	}
+
+	if !pruneYes {
+		ok, err := confirm(fmt.Sprintf("\nDelete %d snapshot(s)?", len(expired)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Printf("Aborted\n")
+			return nil
+		}
+	}
+
+	var failed int
+	for i := range expired {
+		if err := pruneSnapshot(ctx, snapClient, &expired[i]); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to prune %d of %d snapshot(s)", failed, len(expired))
+	}
+	fmt.Printf("✓ Pruned %d snapshot(s)\n", len(expired))
+	return nil
+}
+
+// expiredSnapshots groups snapshots by source PVC and returns those outside
+// the retention policy, oldest first.
+func expiredSnapshots(snapshots []snapshotv1.VolumeSnapshot, now time.Time) []snapshotv1.VolumeSnapshot {
+	byPVC := make(map[string][]snapshotv1.VolumeSnapshot)
+	for _, s := range snapshots {
+		source := snapshotSourcePVC(&s)
+		if source == "" || (prunePVC != "" && source != pvcNamespace+"/"+prunePVC && source != prunePVC) {
+			continue
+		}
+		byPVC[source] = append(byPVC[source], s)
+	}
+
+	var expired []snapshotv1.VolumeSnapshot
+	for _, group := range byPVC {
+		// Newest first, so the first --keep entries are the ones kept
+		sort.Slice(group, func(i, j int) bool {
+			return group[j].CreationTimestamp.Before(&group[i].CreationTimestamp)
+		})
+		for i, s := range group {
+			if pruneKeep > 0 && i < pruneKeep {
+				continue
+			}
+			if pruneKeepWithin > 0 && now.Sub(s.CreationTimestamp.Time) < pruneKeepWithin {
+				continue
+			}
+			expired = append(expired, s)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].CreationTimestamp.Before(&expired[j].CreationTimestamp)
+	})
+	return expired
+}
+
+// skipStorageSnapshots leaves out of snapshots, unless --delete-storage-snapshots
+// is set, those bound to a content with DeletionPolicy Delete, whose deletion
+// would destroy the storage snapshot that destination contents may still
+// reference.
+func skipStorageSnapshots(ctx context.Context, client snapshotclient.Interface, snapshots []snapshotv1.VolumeSnapshot) ([]snapshotv1.VolumeSnapshot, error) {
+	if pruneStorage {
+		return snapshots, nil
+	}
+	var kept []snapshotv1.VolumeSnapshot
+	for _, s := range snapshots {
+		contentName := boundContentName(&s)
+		if contentName == "" {
+			kept = append(kept, s)
+			continue
+		}
+		content, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, contentName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			kept = append(kept, s)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VolumeSnapshotContent %s of VolumeSnapshot %s: %w", contentName, s.Name, err)
+		}
+		if content.Spec.DeletionPolicy == snapshotv1.VolumeSnapshotContentDelete {
+			fmt.Printf("⚠ Skipping VolumeSnapshot %s: its VolumeSnapshotContent %s has DeletionPolicy Delete, so deleting it would destroy the storage snapshot destinations may still use (--delete-storage-snapshots to prune it anyway)\n", s.Name, contentName)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept, nil
+}
+
+// snapshotSourcePVC returns the source PVC recorded on a snapshift snapshot,
+// falling back to the PVC it was taken from for snapshots created before the
+// annotation existed.
+func snapshotSourcePVC(s *snapshotv1.VolumeSnapshot) string {
+	if source := s.Annotations[annotationSourcePVC]; source != "" {
+		return source
+	}
+	if s.Spec.Source.PersistentVolumeClaimName != nil {
+		return s.Namespace + "/" + *s.Spec.Source.PersistentVolumeClaimName
+	}
+	return ""
+}
+
+// pruneSnapshot deletes a snapshot and, if its content is retained, the
+// content too, since it would otherwise be left behind unbound.
+func pruneSnapshot(ctx context.Context, client snapshotclient.Interface, s *snapshotv1.VolumeSnapshot) error {
+	contentName := boundContentName(s)
+
+	err := client.SnapshotV1().VolumeSnapshots(s.Namespace).Delete(ctx, s.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete VolumeSnapshot %s: %w", s.Name, err)
+	}
+	fmt.Printf("✓ Deleted VolumeSnapshot %s\n", s.Name)
+
+	if contentName == "" {
+		return nil
+	}
+	content, err := client.SnapshotV1().VolumeSnapshotContents().Get(ctx, contentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get VolumeSnapshotContent %s: %w", contentName, err)
+	}
+	if content.Spec.DeletionPolicy != snapshotv1.VolumeSnapshotContentRetain {
+		// The snapshot controller deletes the content and storage snapshot
+		return waitContentDeleted(ctx, client, contentName)
+	}
+	err = client.SnapshotV1().VolumeSnapshotContents().Delete(ctx, contentName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete retained VolumeSnapshotContent %s: %w", contentName, err)
+	}
+	fmt.Printf("✓ Deleted retained VolumeSnapshotContent %s\n", contentName)
+	return waitContentDeleted(ctx, client, contentName)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSkipStorageSnapshots(t *testing.T) {
+	bound := func(name, content string) snapshotv1.VolumeSnapshot {
+		s := snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: name}}
+		if content != "" {
+			s.Status = &snapshotv1.VolumeSnapshotStatus{BoundVolumeSnapshotContentName: &content}
+		}
+		return s
+	}
+	content := func(name string, policy snapshotv1.DeletionPolicy) *snapshotv1.VolumeSnapshotContent {
+		return &snapshotv1.VolumeSnapshotContent{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       snapshotv1.VolumeSnapshotContentSpec{DeletionPolicy: policy},
+		}
+	}
+	client := snapfake.NewSimpleClientset(
+		content("snapcontent-origin", snapshotv1.VolumeSnapshotContentDelete),
+		content("snapcontent-dest", snapshotv1.VolumeSnapshotContentRetain),
+	)
+	snapshots := []snapshotv1.VolumeSnapshot{
+		bound("origin", "snapcontent-origin"),
+		bound("dest", "snapcontent-dest"),
+		bound("unbound", ""),
+		bound("gone", "snapcontent-gone"),
+	}
+
+	tests := []struct {
+		name          string
+		deleteStorage bool
+		want          []string
+	}{
+		{"storage snapshots kept", false, []string{"dest", "unbound", "gone"}},
+		{"--delete-storage-snapshots", true, []string{"origin", "dest", "unbound", "gone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &pruneStorage, tt.deleteStorage)
+			var got []snapshotv1.VolumeSnapshot
+			var err error
+			captureStdout(t, func() {
+				got, err = skipStorageSnapshots(context.Background(), client, snapshots)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := snapshotNames(got); !slices.Equal(names, tt.want) {
+				t.Errorf("pruned %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func snapshotNames(snapshots []snapshotv1.VolumeSnapshot) []string {
+	names := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	snapshot := func(name, source, pvc string, age time.Duration) snapshotv1.VolumeSnapshot {
+		s := snapshotv1.VolumeSnapshot{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "apps",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+		}}
+		if source != "" {
+			s.Annotations = map[string]string{annotationSourcePVC: source}
+		}
+		if pvc != "" {
+			s.Spec.Source.PersistentVolumeClaimName = &pvc
+		}
+		return s
+	}
+	day := 24 * time.Hour
+	snapshots := []snapshotv1.VolumeSnapshot{
+		snapshot("data-1", "apps/data", "data", 1*day),
+		snapshot("data-3", "apps/data", "data", 3*day),
+		snapshot("data-2", "apps/data", "data", 2*day),
+		snapshot("data-5", "apps/data", "data", 5*day),
+		// Replicated from another namespace, annotated with the source PVC
+		snapshot("logs-2", "prod/logs", "", 2*day),
+		snapshot("logs-4", "prod/logs", "", 4*day),
+		// Taken before the annotation existed
+		snapshot("cache-1", "", "cache", 1*day),
+		snapshot("cache-6", "", "cache", 6*day),
+		// Neither annotated nor taken from a PVC
+		snapshot("orphan", "", "", 9*day),
+	}
+
+	tests := []struct {
+		name       string
+		keep       int
+		keepWithin time.Duration
+		pvc        string
+		want       []string // oldest first
+	}{
+		{name: "keep", keep: 1, want: []string{"cache-6", "data-5", "logs-4", "data-3", "data-2"}},
+		{name: "keep more than there are", keep: 5},
+		{name: "keep within", keepWithin: 2*day + time.Hour, want: []string{"cache-6", "data-5", "logs-4", "data-3"}},
+		{name: "keep and keep within", keep: 2, keepWithin: 2*day + time.Hour, want: []string{"data-5", "data-3"}},
+		{name: "bare pvc name", keep: 1, pvc: "data", want: []string{"data-5", "data-3", "data-2"}},
+		{name: "namespaced pvc", keep: 1, pvc: "prod/logs", want: []string{"logs-4"}},
+		{name: "bare pvc name of another namespace", keep: 1, pvc: "logs"},
+		{name: "pvc without annotation", keep: 1, pvc: "cache", want: []string{"cache-6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &pvcNamespace, "apps")
+			setFlag(t, &pruneKeep, tt.keep)
+			setFlag(t, &pruneKeepWithin, tt.keepWithin)
+			setFlag(t, &prunePVC, tt.pvc)
+			got := snapshotNames(expiredSnapshots(snapshots, now))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
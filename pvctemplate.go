@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// PVC manifest loaded from --pvc-template
+var pvcTemplate *corev1.PersistentVolumeClaim
+
+// loadPVCTemplate reads a PVC manifest used as the template of the destination
+// PVC.
+func loadPVCTemplate(path string) (*corev1.PersistentVolumeClaim, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PVC template: %w", err)
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := yaml.UnmarshalStrict(data, &pvc); err != nil {
+		return nil, fmt.Errorf("invalid PVC template %s: %w", path, err)
+	}
+	if pvc.Kind != "" && pvc.Kind != "PersistentVolumeClaim" {
+		return nil, fmt.Errorf("invalid PVC template %s: kind is %s, not PersistentVolumeClaim", path, pvc.Kind)
+	}
+	return &pvc, nil
+}
+
+// templateStorageSize returns the storage request of the PVC template, if it
+// sets one, instead of the source PVC's.
+func templateStorageSize(sourceSize resource.Quantity) resource.Quantity {
+	if pvcTemplate == nil {
+		return sourceSize
+	}
+	if size, ok := pvcTemplate.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+		return size
+	}
+	return sourceSize
+}
+
+// applyPVCTemplate overrides the destination PVC with the values set in the PVC
+// template. The name, namespace and data source are kept, so the PVC is still
+// restored from the destination snapshot, and so is the storage request, which
+// has already been taken from the template and checked against the snapshot
+// restore size. The storage class is applied with the other storage class
+// overrides, see mapStorageClass.
+func applyPVCTemplate(pvc *corev1.PersistentVolumeClaim) {
+	if pvcTemplate == nil {
+		return
+	}
+	t := pvcTemplate.DeepCopy()
+
+	pvc.Labels = mergeStringMaps(pvc.Labels, t.Labels)
+	pvc.Annotations = mergeStringMaps(pvc.Annotations, t.Annotations)
+
+	if len(t.Spec.AccessModes) > 0 {
+		pvc.Spec.AccessModes = t.Spec.AccessModes
+	}
+	if t.Spec.Selector != nil {
+		pvc.Spec.Selector = t.Spec.Selector
+	}
+	if t.Spec.VolumeMode != nil {
+		pvc.Spec.VolumeMode = t.Spec.VolumeMode
+	}
+	if t.Spec.VolumeName != "" {
+		pvc.Spec.VolumeName = t.Spec.VolumeName
+	}
+	if t.Spec.VolumeAttributesClassName != nil {
+		pvc.Spec.VolumeAttributesClassName = t.Spec.VolumeAttributesClassName
+	}
+
+	storage := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if t.Spec.Resources.Limits != nil {
+		pvc.Spec.Resources.Limits = t.Spec.Resources.Limits
+	}
+	for name, quantity := range t.Spec.Resources.Requests {
+		pvc.Spec.Resources.Requests[name] = quantity
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = storage
+}
+
+// mergeStringMaps returns base with the entries of override added, override
+// winning on conflicts.
+func mergeStringMaps(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
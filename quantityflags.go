@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validateQuantityFlags parses the flags holding quantities and rates before
+// the run starts, so a typo such as 10GB for 10Gi fails before anything is
+// created in either cluster.
+func validateQuantityFlags(cmd *cobra.Command, args []string) error {
+	if maxSnapshotSizeSpec != "" {
+		var err error
+		if maxSnapshotSize, err = parseMaxSnapshotSize(maxSnapshotSizeSpec); err != nil {
+			return err
+		}
+	}
+	if snapshotRateSpec != "" {
+		var err error
+		if snapshotRate, err = parseSnapshotRate(snapshotRateSpec); err != nil {
+			return err
+		}
+	}
+	if pvcTemplateFile != "" {
+		var err error
+		if pvcTemplate, err = loadPVCTemplate(pvcTemplateFile); err != nil {
+			return err
+		}
+		if size, ok := pvcTemplate.Spec.Resources.Requests[corev1.ResourceStorage]; ok && size.Sign() <= 0 {
+			return fmt.Errorf("invalid PVC template %s: storage request %s must be positive", pvcTemplateFile, size.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestValidateQuantityFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  string
+		rate     string
+		template string
+		wantErr  string
+	}{
+		{name: "none"},
+		{name: "valid", maxSize: "500Gi", rate: "10/1m", template: "spec:\n  resources:\n    requests:\n      storage: 20Gi\n"},
+		{name: "max size typo", maxSize: "10GB", wantErr: `invalid --max-snapshot-size "10GB"`},
+		{name: "max size zero", maxSize: "0", wantErr: "must be positive"},
+		{name: "rate typo", rate: "10/1x", wantErr: "--storage-snapshot-rate"},
+		{name: "template size typo", template: "spec:\n  resources:\n    requests:\n      storage: 10GB\n", wantErr: "invalid PVC template"},
+		{name: "template size zero", template: "spec:\n  resources:\n    requests:\n      storage: \"0\"\n", wantErr: "storage request 0 must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &maxSnapshotSizeSpec, tt.maxSize)
+			setFlag(t, &maxSnapshotSize, (*resource.Quantity)(nil))
+			setFlag(t, &snapshotRateSpec, tt.rate)
+			setFlag(t, &snapshotRate, (*rate.Limiter)(nil))
+			setFlag(t, &pvcTemplateFile, "")
+			setFlag(t, &pvcTemplate, (*corev1.PersistentVolumeClaim)(nil))
+			if tt.template != "" {
+				pvcTemplateFile = filepath.Join(t.TempDir(), "pvc.yaml")
+				if err := os.WriteFile(pvcTemplateFile, []byte(tt.template), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := validateQuantityFlags(rootCmd, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateQuantityFlags() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateQuantityFlags() = %v", err)
+			}
+			if (maxSnapshotSize != nil) != (tt.maxSize != "") || (snapshotRate != nil) != (tt.rate != "") || (pvcTemplate != nil) != (tt.template != "") {
+				t.Errorf("flags not normalized: max size %v, rate %v, template %v", maxSnapshotSize, snapshotRate, pvcTemplate)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkStorageQuota fails, with --check-quota, when the ResourceQuotas of the
+// destination PVC namespace do not have enough storage request headroom left
+// for the destination PVCs, which would otherwise only be rejected once the
+// snapshot is replicated. Both the namespace-wide requests.storage and the one
+// scoped to the PVC's StorageClass are checked.
+func checkStorageQuota(ctx context.Context, d *destination, namespace string, pvc *corev1.PersistentVolumeClaim, size resource.Quantity) error {
+	if !checkQuota {
+		return nil
+	}
+	quotas, err := d.k8s.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list ResourceQuotas in %s: %w", namespace, err)
+	}
+
+	needed := size.DeepCopy()
+	for i := 1; i < replicas; i++ {
+		needed.Add(size)
+	}
+	resources := []corev1.ResourceName{corev1.ResourceRequestsStorage}
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		resources = append(resources, corev1.ResourceName(*pvc.Spec.StorageClassName+".storageclass.storage.k8s.io/requests.storage"))
+	}
+
+	for _, quota := range quotas.Items {
+		for _, name := range resources {
+			hard, ok := quota.Spec.Hard[name]
+			if !ok {
+				continue
+			}
+			used, ok := quota.Status.Used[name]
+			if !ok {
+				fmt.Fprintf(stepOut, "%s⚠ Warning: ResourceQuota %s/%s does not report its %s usage yet, not checking it\n", d.prefix, namespace, quota.Name, name)
+				continue
+			}
+			remaining := hard.DeepCopy()
+			remaining.Sub(used)
+			if remaining.Cmp(needed) < 0 {
+				if remaining.Sign() < 0 {
+					remaining = resource.Quantity{}
+				}
+				return fmt.Errorf("ResourceQuota %s/%s has %s of %s left, the destination PVCs need %s", namespace, quota.Name, remaining.String(), name, needed.String())
+			}
+			fmt.Fprintf(stepOut, "%s✓ ResourceQuota %s/%s has %s of %s left\n", d.prefix, namespace, quota.Name, remaining.String(), name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// annotationReadOnly marks a destination PVC restored with --readonly, for the
+// workloads mounting it to set readOnly on their volume mounts.
+const annotationReadOnly = "snapshift.io/read-only"
+
+// readOnlyUnsupportedDrivers are CSI drivers known to provision only
+// single-node writable volumes, so a ReadOnlyMany PVC of theirs stays Pending.
+var readOnlyUnsupportedDrivers = map[string]bool{
+	"ebs.csi.aws.com": true,
+}
+
+// destAccessModes returns the access modes of the destination PVC: the
+// source PVC's, or ReadOnlyMany with --readonly.
+func destAccessModes(sourcePVC *corev1.PersistentVolumeClaim) []corev1.PersistentVolumeAccessMode {
+	if readOnly {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}
+	}
+	return sourcePVC.Spec.AccessModes
+}
+
+// applyReadOnly makes a destination PVC ReadOnlyMany and annotates it as
+// read-only when --readonly is set.
+func applyReadOnly(pvc *corev1.PersistentVolumeClaim) {
+	if !readOnly {
+		return
+	}
+	pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadOnlyMany}
+	pvc.Annotations = mergeStringMaps(pvc.Annotations, map[string]string{annotationReadOnly: "true"})
+}
+
+// checkReadOnlyDriver refuses --readonly for a driver known not to support
+// ReadOnlyMany volumes. Drivers do not advertise their access modes to the
+// API server, so other drivers are only warned about.
+func checkReadOnlyDriver(d *destination, driver string) error {
+	if readOnlyUnsupportedDrivers[driver] {
+		return fmt.Errorf("--readonly: CSI driver %s does not support ReadOnlyMany volumes", driver)
+	}
+	fmt.Fprintf(stepOut, "%s⚠ Warning: Cannot verify that CSI driver %s supports ReadOnlyMany volumes; the destination PVC stays Pending if it does not\n", d.prefix, driver)
+	return nil
+}
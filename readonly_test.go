@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildPVCFromSnapshotReadOnly(t *testing.T) {
+	source := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "data"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+	}
+	build := func() *corev1.PersistentVolumeClaim {
+		return buildPVCFromSnapshot("apps", "data", "data-snap", resource.MustParse("1Gi"), source, nil, nil, map[string]string{annotationSourcePVC: "apps/data"})
+	}
+
+	pvc := build()
+	if len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != corev1.ReadWriteOnce {
+		t.Errorf("access modes = %v, want the source's", pvc.Spec.AccessModes)
+	}
+	if _, ok := pvc.Annotations[annotationReadOnly]; ok {
+		t.Errorf("read-only annotation set without --readonly")
+	}
+
+	setFlag(t, &readOnly, true)
+	pvc = build()
+	if len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != corev1.ReadOnlyMany {
+		t.Errorf("access modes = %v, want ReadOnlyMany", pvc.Spec.AccessModes)
+	}
+	if pvc.Annotations[annotationReadOnly] != "true" || pvc.Annotations[annotationSourcePVC] != "apps/data" {
+		t.Errorf("annotations = %v, want the read-only and provenance annotations", pvc.Annotations)
+	}
+	if got := destAccessModes(source); len(got) != 1 || got[0] != corev1.ReadOnlyMany {
+		t.Errorf("destAccessModes = %v, want ReadOnlyMany", got)
+	}
+}
+
+func TestCheckReadOnlyDriver(t *testing.T) {
+	d := &destination{name: "dr"}
+	if err := checkReadOnlyDriver(d, "ebs.csi.aws.com"); err == nil || !strings.Contains(err.Error(), "does not support ReadOnlyMany") {
+		t.Errorf("checkReadOnlyDriver(ebs) = %v, want a refusal", err)
+	}
+	if err := checkReadOnlyDriver(d, "pd.csi.storage.gke.io"); err != nil {
+		t.Errorf("checkReadOnlyDriver(pd) = %v, want only a warning", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+)
+
+// objectRef names a namespaced object.
+type objectRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// migrationResult is what a migration created, returned by migratePVC for
+// callers that need more than its output. It is filled in as the migration
+// goes, so on failure it shows how far the migration got. It is also the
+// record written to the --result-file.
+type migrationResult struct {
+	RunID     string    `json:"runID"`
+	SourcePVC objectRef `json:"sourcePVC"`
+	// OriginSnapshot is set once the origin snapshot is created, or given
+	// with --source-snapshot
+	OriginSnapshot *objectRef   `json:"originSnapshot,omitempty"`
+	OriginContent  string       `json:"originContent,omitempty"`
+	SnapshotHandle string       `json:"snapshotHandle,omitempty"`
+	Driver         string       `json:"driver,omitempty"`
+	StorageSize    string       `json:"storageSize,omitempty"`
+	RestoreSize    string       `json:"restoreSize,omitempty"`
+	Destinations   []destResult `json:"destinations,omitempty"`
+	Duration       string       `json:"duration"`
+	Error          string       `json:"error,omitempty"`
+	// DestSnapshotHandle is set when --handle-transform rewrote the handle
+	// for the destination contents
+	DestSnapshotHandle string `json:"destSnapshotHandle,omitempty"`
+}
+
+// destResult is what a migration created in one destination.
+type destResult struct {
+	Cluster  string     `json:"cluster"`
+	Snapshot *objectRef `json:"snapshot,omitempty"`
+	Content  string     `json:"content,omitempty"`
+	// SnapshotCopy is the copy of the snapshot in the PVC namespace with
+	// --copy-snapshot-to-pvc-namespace
+	SnapshotCopy *objectRef  `json:"snapshotCopy,omitempty"`
+	PVCs         []objectRef `json:"pvcs,omitempty"`
+	Duration     string      `json:"duration"`
+	Error        string      `json:"error,omitempty"`
+}
+
+func newMigrationResult(m *migration) *migrationResult {
+	res := &migrationResult{
+		RunID:     runID,
+		SourcePVC: objectRef{Namespace: m.pvcNamespace, Name: m.pvcName},
+	}
+	if m.existingSnapshot {
+		res.OriginSnapshot = &objectRef{Namespace: m.pvcNamespace, Name: m.snapshotName}
+	}
+	return res
+}
+
+// setOriginContent records the origin content and its snapshot.
+func (r *migrationResult) setOriginContent(snapshot *snapshotv1.VolumeSnapshot, content *snapshotv1.VolumeSnapshotContent) {
+	r.OriginContent = content.Name
+	r.Driver = content.Spec.Driver
+	r.SnapshotHandle = contentHandle(content)
+	if snapshot.Status != nil && snapshot.Status.RestoreSize != nil {
+		r.RestoreSize = snapshot.Status.RestoreSize.String()
+	}
+}
+
+// addDest records the outcome of replicating to a destination.
+func (r *migrationResult) addDest(d *destination, m *migration, st *destState, duration time.Duration) {
+	dr := destResult{Cluster: d.name, Content: st.contentName, Duration: duration.Round(time.Millisecond).String()}
+	if st.snapshotCreated {
+		dr.Snapshot = &objectRef{Namespace: m.destNamespace, Name: m.destSnapshotName}
+	}
+	if st.copy != nil && st.copy.snapshotCreated {
+		dr.SnapshotCopy = &objectRef{Namespace: m.destPVCNamespace, Name: m.destSnapshotName}
+	}
+	if st.err != nil {
+		dr.Error = st.err.Error()
+	} else if createPVC {
+		for _, name := range m.destPVCNames() {
+			dr.PVCs = append(dr.PVCs, objectRef{Namespace: m.destPVCNamespace, Name: name})
+		}
+	}
+	r.Destinations = append(r.Destinations, dr)
+}
+
+func (r *migrationResult) finish(start time.Time, err error) {
+	r.Duration = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// writeResult appends a migration result to the --result-file, if set.
+func writeResult(res *migrationResult) {
+	if resultFile == "" || res == nil {
+		return
+	}
+	if err := appendJSONLine(resultFile, res); err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Failed to write result file: %v\n", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// retryBackoff returns the delay before a retry, growing from --retry-base-delay
+// by --retry-factor on each attempt, up to --retry-max-delay.
+func retryBackoff(attempt int) time.Duration {
+	delay := float64(retryBaseDelay)
+	for i := 0; i < attempt; i++ {
+		delay *= retryFactor
+		if delay >= float64(retryMaxDelay) {
+			return retryMaxDelay
+		}
+	}
+	return time.Duration(delay)
+}
+
+// waitRetry waits for the backoff of a retry, or until ctx is done.
+func waitRetry(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(retryBackoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting to retry: %w", ctx.Err())
+	}
+}
+
+// retryTransient wraps a client transport so requests the API server turns
+// away as overloaded or unavailable are retried, up to --max-retries times
+// with the --retry-* backoff.
+func retryTransient(rt http.RoundTripper) http.RoundTripper {
+	return &retryingRoundTripper{rt: rt}
+}
+
+type retryingRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func (r *retryingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := r.rt.RoundTrip(req)
+		if err != nil || attempt >= maxRetries || !transientStatus(req.Method, resp.StatusCode) {
+			return resp, err
+		}
+		// A request body can only be sent again if it can be rewound
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err := waitRetry(req.Context(), attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// transientStatus reports whether a response status means the request was
+// not processed and can be sent again. A gateway timeout may come after a
+// write was applied, so only reads are retried on it.
+func transientStatus(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusGatewayTimeout:
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
+// validateRetryBackoff checks the --retry-* flags.
+func validateRetryBackoff() error {
+	if maxRetries < 0 {
+		return fmt.Errorf("--max-retries must not be negative")
+	}
+	if retryBaseDelay <= 0 {
+		return fmt.Errorf("--retry-base-delay must be positive")
+	}
+	if retryMaxDelay <= retryBaseDelay {
+		return fmt.Errorf("--retry-max-delay (%s) must be greater than --retry-base-delay (%s)", retryMaxDelay, retryBaseDelay)
+	}
+	if retryFactor <= 1 {
+		return fmt.Errorf("--retry-factor must be greater than 1")
+	}
+	return nil
+}
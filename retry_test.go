@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryTransient(t *testing.T) {
+	setFlag(t, &maxRetries, 3)
+	setFlag(t, &retryBaseDelay, time.Millisecond)
+	setFlag(t, &retryMaxDelay, 5*time.Millisecond)
+
+	tests := []struct {
+		name     string
+		method   string
+		failures int
+		status   int
+		wantHits int
+		wantCode int
+	}{
+		{"recovers after unavailable", http.MethodPost, 2, http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"recovers after throttling", http.MethodGet, 1, http.StatusTooManyRequests, 2, http.StatusOK},
+		{"gives up after max retries", http.MethodGet, 10, http.StatusServiceUnavailable, 4, http.StatusServiceUnavailable},
+		{"retries read on gateway timeout", http.MethodGet, 1, http.StatusGatewayTimeout, 2, http.StatusOK},
+		{"keeps write on gateway timeout", http.MethodPost, 1, http.StatusGatewayTimeout, 1, http.StatusGatewayTimeout},
+		{"keeps other errors", http.MethodGet, 1, http.StatusInternalServerError, 1, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				if r.Method == http.MethodPost {
+					body, _ := io.ReadAll(r.Body)
+					if string(body) != "payload" {
+						t.Errorf("attempt %d body = %q, want %q", hits, body, "payload")
+					}
+				}
+				if hits <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			var body io.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader("payload")
+			}
+			req, err := http.NewRequest(tt.method, srv.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := retryTransient(http.DefaultTransport).RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if hits != tt.wantHits {
+				t.Errorf("server hit %d times, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
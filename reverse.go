@@ -0,0 +1,50 @@
+package main
+
+import "fmt"
+
+// reverseRoles swaps the origin and destination flags for --reverse, so the
+// configuration used for a failover also drives the failback. It runs before
+// any other validation, which then applies to the swapped values.
+func reverseRoles() error {
+	if fleetFile != "" || destKubeSecret != "" || snapshotOnly {
+		return fmt.Errorf("--reverse cannot be combined with --fleet, --dest-kubeconfig-secret or --snapshot-only")
+	}
+	if len(destKubeconfigs) > 1 || len(destContexts) > 1 {
+		return fmt.Errorf("--reverse needs a single destination cluster")
+	}
+	if len(destKubeconfigs) == 0 && len(destContexts) == 0 {
+		return fmt.Errorf("--reverse needs the destination cluster set with --dest-kubeconfig or --dest-context")
+	}
+	if len(namespaceMap) > 0 || destClassSelector != "" || selectDestClass != classSelectCopy || destStorageClass != "" || destPVCNamespace != "" {
+		return fmt.Errorf("--reverse cannot be combined with --namespace-map, --dest-snapshot-class-selector, --select-dest-class-by, --dest-storage-class or --dest-pvc-namespace, which only apply one way")
+	}
+
+	swapCluster(&originKubeconfig, &destKubeconfigs)
+	swapCluster(&originContext, &destContexts)
+	swapIfSet(&pvcNamespace, &destNamespace)
+	swapIfSet(&pvcName, &destPVCName)
+	swapIfSet(&snapshotName, &destSnapshotName)
+	snapshotClass, destSnapClass = destSnapClass, snapshotClass
+
+	fmt.Fprintf(stepOut, "Reverse: replicating from %s back to %s\n", clusterName(originKubeconfig, originContext), clusterName(pick(destKubeconfigs, 0), pick(destContexts, 0)))
+	return nil
+}
+
+// swapCluster swaps the origin value of a cluster flag with the single
+// destination value.
+func swapCluster(origin *string, dests *[]string) {
+	dest := pick(*dests, 0)
+	*dests = nil
+	if *origin != "" {
+		*dests = []string{*origin}
+	}
+	*origin = dest
+}
+
+// swapIfSet swaps an origin value with its destination counterpart. An unset
+// destination value defaults to the origin one, so there is nothing to swap.
+func swapIfSet(origin, dest *string) {
+	if *dest != "" {
+		*origin, *dest = *dest, *origin
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// fieldManager is the field manager of the objects applied with
+// --server-side-apply, which the API server names in conflicts.
+const fieldManager = "snapshift"
+
+// applyOptions are the options of a server-side apply. Fields owned by another
+// manager are conflicts, not taken over.
+func applyOptions() metav1.PatchOptions {
+	return metav1.PatchOptions{FieldManager: fieldManager}
+}
+
+// applyPatch renders an object built by snapshift as a server-side apply
+// patch, which needs its apiVersion and kind.
+func applyPatch(obj runtime.Object) ([]byte, error) {
+	return json.Marshal(withTypeMeta(obj))
+}
+
+// applyVolumeSnapshotContent creates or updates a VolumeSnapshotContent with
+// server-side apply.
+func applyVolumeSnapshotContent(ctx context.Context, client snapshotclient.Interface, content *snapshotv1.VolumeSnapshotContent) (*snapshotv1.VolumeSnapshotContent, error) {
+	patch, err := applyPatch(content)
+	if err != nil {
+		return nil, err
+	}
+	return client.SnapshotV1().VolumeSnapshotContents().Patch(ctx, content.Name, types.ApplyPatchType, patch, applyOptions())
+}
+
+// applyVolumeSnapshot creates or updates a VolumeSnapshot with server-side
+// apply.
+func applyVolumeSnapshot(ctx context.Context, client snapshotclient.Interface, snapshot *snapshotv1.VolumeSnapshot) (*snapshotv1.VolumeSnapshot, error) {
+	patch, err := applyPatch(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return client.SnapshotV1().VolumeSnapshots(snapshot.Namespace).Patch(ctx, snapshot.Name, types.ApplyPatchType, patch, applyOptions())
+}
+
+// applyPVC creates or updates a PersistentVolumeClaim with server-side apply.
+func applyPVC(ctx context.Context, client kubernetes.Interface, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	patch, err := applyPatch(pvc)
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.ApplyPatchType, patch, applyOptions())
+}
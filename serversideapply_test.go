@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	"k8s.io/client-go/rest"
+)
+
+func TestApplyVolumeSnapshotContentRequest(t *testing.T) {
+	var method, contentType, manager, force string
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		manager = r.URL.Query().Get("fieldManager")
+		force = r.URL.Query().Get("force")
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("apply body is not JSON: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	client, err := snapshotclient.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	originContent := &snapshotv1.VolumeSnapshotContent{Spec: snapshotv1.VolumeSnapshotContentSpec{Driver: "csi.example.com"}}
+	content := buildVolumeSnapshotContent("snapcontent-data-snap", "apps", "data-snap", "handle-1", "", originContent)
+	if _, err := applyVolumeSnapshotContent(context.Background(), client, content); err != nil {
+		t.Fatalf("applyVolumeSnapshotContent: %v", err)
+	}
+
+	if method != http.MethodPatch {
+		t.Errorf("method = %s, want PATCH", method)
+	}
+	if contentType != "application/apply-patch+yaml" {
+		t.Errorf("content type = %q, want an apply patch", contentType)
+	}
+	if manager != fieldManager {
+		t.Errorf("fieldManager = %q, want %q", manager, fieldManager)
+	}
+	if force != "" {
+		t.Errorf("force = %q, want conflicts reported", force)
+	}
+	if body["apiVersion"] != "snapshot.storage.k8s.io/v1" || body["kind"] != "VolumeSnapshotContent" {
+		t.Errorf("apply body apiVersion/kind = %v/%v", body["apiVersion"], body["kind"])
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// maxSnapshotSize is the largest volume a run migrates when
+// --max-snapshot-size is set.
+var maxSnapshotSize *resource.Quantity
+
+// parseMaxSnapshotSize parses a --max-snapshot-size value, a positive
+// quantity such as 500Gi.
+func parseMaxSnapshotSize(value string) (*resource.Quantity, error) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --max-snapshot-size %q: %w", value, err)
+	}
+	if q.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid --max-snapshot-size %q: must be positive", value)
+	}
+	return &q, nil
+}
+
+// checkSnapshotSize refuses a volume whose size, described by what, exceeds
+// --max-snapshot-size. A size equal to the limit is allowed.
+func checkSnapshotSize(what string, size resource.Quantity) error {
+	if maxSnapshotSize == nil || size.Cmp(*maxSnapshotSize) <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%s %s exceeds --max-snapshot-size %s, refusing to migrate it", what, size.String(), maxSnapshotSize.String())
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestCheckSnapshotSize(t *testing.T) {
+	limit, err := parseMaxSnapshotSize("10Gi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, &maxSnapshotSize, limit)
+
+	tests := []struct {
+		size    string
+		refused bool
+	}{
+		{"1Gi", false},
+		{"10Gi", false},
+		{"10240Mi", false},
+		{"10737418240", false},
+		{"10737418241", true},
+		{"11Gi", true},
+		{"11G", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			err := checkSnapshotSize("PVC apps/data request", resource.MustParse(tt.size))
+			if (err != nil) != tt.refused {
+				t.Fatalf("checkSnapshotSize(%s) = %v, want refused %v", tt.size, err, tt.refused)
+			}
+			if err != nil && !strings.Contains(err.Error(), "PVC apps/data request "+tt.size+" exceeds --max-snapshot-size 10Gi") {
+				t.Errorf("error = %v, want the size and the limit", err)
+			}
+		})
+	}
+
+	setFlag(t, &maxSnapshotSize, nil)
+	if err := checkSnapshotSize("PVC apps/data request", resource.MustParse("1Pi")); err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+}
+
+func TestParseMaxSnapshotSize(t *testing.T) {
+	for _, value := range []string{"0", "-1Gi", "ten", ""} {
+		if _, err := parseMaxSnapshotSize(value); err == nil {
+			t.Errorf("parseMaxSnapshotSize(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats accepted by snapshot-classes --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var (
+	classesKubeconfig string
+	classesContext    string
+	classesOutput     string
+)
+
+var snapshotClassesCmd = &cobra.Command{
+	Use:   "snapshot-classes",
+	Short: "List the VolumeSnapshotClasses available in a cluster",
+	Long: `snapshot-classes lists VolumeSnapshotClasses with their driver and deletion
+policy, marking the default class. With --context or --kubeconfig it lists one
+cluster; otherwise it lists the origin cluster and any destination clusters
+side by side, marking the drivers common to all of them, which are the ones
+whose snapshot handles can be replicated.`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotClasses,
+}
+
+func init() {
+	snapshotClassesCmd.Flags().StringVar(&classesKubeconfig, "kubeconfig", "", "Path to the kubeconfig of a single cluster to list")
+	snapshotClassesCmd.Flags().StringVar(&classesContext, "context", "", "Context of a single cluster to list")
+	snapshotClassesCmd.Flags().StringVarP(&classesOutput, "output", "o", outputTable, "Output format: table or json")
+	rootCmd.AddCommand(snapshotClassesCmd)
+}
+
+// clusterClasses is the list of snapshot classes of one cluster.
+type clusterClasses struct {
+	Cluster string      `json:"cluster"`
+	Classes []classInfo `json:"classes"`
+}
+
+// classInfo summarizes one VolumeSnapshotClass.
+type classInfo struct {
+	Name           string `json:"name"`
+	Driver         string `json:"driver"`
+	DeletionPolicy string `json:"deletionPolicy"`
+	Default        bool   `json:"default"`
+	Common         bool   `json:"common"`
+}
+
+func runSnapshotClasses(cmd *cobra.Command, args []string) error {
+	prettySet = cmd.Flags().Changed("pretty")
+	if classesOutput != outputTable && classesOutput != outputJSON {
+		return fmt.Errorf("invalid --output value %q: must be %q or %q", classesOutput, outputTable, outputJSON)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var targets []destinationTarget
+	if classesKubeconfig != "" || classesContext != "" {
+		targets = []destinationTarget{{
+			name:       clusterName(classesKubeconfig, classesContext),
+			kubeconfig: classesKubeconfig,
+			context:    classesContext,
+		}}
+	} else {
+		targets = []destinationTarget{{
+			name:       "origin " + clusterName(originKubeconfig, originContext),
+			kubeconfig: originKubeconfig,
+			context:    originContext,
+			overrides:  originOverrides(),
+		}}
+		if len(destKubeconfigs) > 0 || len(destContexts) > 0 {
+			dests, err := destinationTargets(destKubeconfigs, destContexts)
+			if err != nil {
+				return err
+			}
+			for _, t := range dests {
+				t.name = "destination " + t.name
+				targets = append(targets, t)
+			}
+		}
+	}
+
+	clusters := make([]clusterClasses, 0, len(targets))
+	for _, t := range targets {
+		classes, err := listSnapshotClasses(ctx, t)
+		if err != nil {
+			return err
+		}
+		clusters = append(clusters, clusterClasses{Cluster: t.name, Classes: classes})
+	}
+
+	common := commonDrivers(clusters)
+	for i := range clusters {
+		for j := range clusters[i].Classes {
+			clusters[i].Classes[j].Common = len(clusters) > 1 && common[clusters[i].Classes[j].Driver]
+		}
+	}
+
+	if classesOutput == outputJSON {
+		return newJSONEncoder(os.Stdout).Encode(clusters)
+	}
+
+	for i, cl := range clusters {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s:\n", cl.Cluster)
+		if len(cl.Classes) == 0 {
+			fmt.Printf("  No VolumeSnapshotClasses found\n")
+			continue
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "  NAME\tDRIVER\tDELETION POLICY\tDEFAULT\t\n")
+		for _, c := range cl.Classes {
+			driver := c.Driver
+			if c.Common {
+				driver += " *"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%t\t\n", c.Name, driver, c.DeletionPolicy, c.Default)
+		}
+		w.Flush()
+	}
+
+	if len(clusters) > 1 {
+		var shared []string
+		for driver := range common {
+			shared = append(shared, driver)
+		}
+		sort.Strings(shared)
+		if len(shared) == 0 {
+			fmt.Printf("\n✗ No snapshot driver is common to all clusters\n")
+		} else {
+			fmt.Printf("\n* Common drivers, safe for handle replication: %s\n", strings.Join(shared, ", "))
+		}
+	}
+
+	return nil
+}
+
+// listSnapshotClasses lists the VolumeSnapshotClasses of a cluster.
+func listSnapshotClasses(ctx context.Context, t destinationTarget) ([]classInfo, error) {
+	_, snapClient, err := createClients(t.kubeconfig, t.context, t.overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clients for %s: %w", t.name, err)
+	}
+
+	classes, err := listClasses(ctx, snapClient, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshotClasses in %s: %w", t.name, err)
+	}
+	return classes, nil
+}
+
+// commonDrivers returns the drivers that have a snapshot class in every
+// cluster.
+func commonDrivers(clusters []clusterClasses) map[string]bool {
+	counts := make(map[string]int)
+	for _, cl := range clusters {
+		seen := make(map[string]bool)
+		for _, c := range cl.Classes {
+			if !seen[c.Driver] {
+				seen[c.Driver] = true
+				counts[c.Driver]++
+			}
+		}
+	}
+
+	common := make(map[string]bool)
+	for driver, n := range counts {
+		if n == len(clusters) {
+			common[driver] = true
+		}
+	}
+	return common
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// snapshotRate paces the origin snapshot creations of every PVC of a run when
+// --storage-snapshot-rate is set.
+var snapshotRate *rate.Limiter
+
+// parseSnapshotRate parses a --storage-snapshot-rate value of the form
+// N/duration, e.g. 10/1m, into a token bucket holding N tokens refilled one at
+// a time over the duration.
+func parseSnapshotRate(value string) (*rate.Limiter, error) {
+	count, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid --storage-snapshot-rate %q: expected N/duration, e.g. 10/1m", value)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid --storage-snapshot-rate %q: the count must be a positive integer", value)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid --storage-snapshot-rate %q: the period must be a positive duration, e.g. 1m", value)
+	}
+	return rate.NewLimiter(rate.Every(d/time.Duration(n)), n), nil
+}
+
+// waitSnapshotRate takes a token of the storage snapshot rate limit, waiting
+// for one to be refilled if the bucket is empty.
+func waitSnapshotRate(ctx context.Context, namespace, name string) error {
+	if snapshotRate == nil {
+		return nil
+	}
+
+	r := snapshotRate.Reserve()
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	if delay > time.Second {
+		delay = delay.Round(time.Second)
+	}
+	fmt.Fprintf(stepOut, "Waiting %s for --storage-snapshot-rate before creating snapshot %s/%s...\n", delay.Round(time.Millisecond), namespace, name)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return fmt.Errorf("waiting for --storage-snapshot-rate: %w", ctx.Err())
+	}
+}
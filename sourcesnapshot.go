@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// sourceSnapshotMigration returns the migration of the existing origin snapshot
+// given with --source-snapshot. The source PVC is the one the snapshot was
+// taken from, if it records one.
+func sourceSnapshotMigration(ctx context.Context, client snapshotclient.Interface) (*migration, error) {
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(pvcNamespace).Get(ctx, sourceSnapshot, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source snapshot %s/%s: %w", pvcNamespace, sourceSnapshot, err)
+	}
+
+	var name string
+	if snapshot.Spec.Source.PersistentVolumeClaimName != nil {
+		name = *snapshot.Spec.Source.PersistentVolumeClaimName
+	}
+	if createPVC && name == "" && destPVCName == "" {
+		return nil, fmt.Errorf("source snapshot %s/%s was not taken from a PVC, --dest-pvc-name is required with --create-pvc", pvcNamespace, sourceSnapshot)
+	}
+
+	m := newMigration(pvcNamespace, name)
+	m.existingSnapshot = true
+	m.snapshotName = sourceSnapshot
+	if destSnapshotName == "" {
+		m.destSnapshotName = sourceSnapshot
+	}
+	return m, nil
+}
+
+// readySnapshot returns an existing snapshot without waiting for it, for
+// --skip-wait-origin, failing if it is not ready to use yet.
+func readySnapshot(ctx context.Context, client snapshotclient.Interface, namespace, name string) (*snapshotv1.VolumeSnapshot, error) {
+	fmt.Fprintf(stepOut, "Checking that origin snapshot %s/%s is ready...\n", namespace, name)
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get origin snapshot: %w", err)
+	}
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse {
+		return nil, fmt.Errorf("origin snapshot %s/%s is not ready to use, run without --skip-wait-origin to wait for it", namespace, name)
+	}
+	return snapshot, nil
+}
+
+// existingSnapshotPVC returns the PVC an existing snapshot was taken from, or,
+// when it has none or the PVC is gone, a placeholder for the destination PVC to
+// be built from: ReadWriteOnce, in the default storage class and sized to the
+// restore size, unless --pvc-template or --dest-storage-class say otherwise.
+func existingSnapshotPVC(ctx context.Context, client kubernetes.Interface, m *migration) (*corev1.PersistentVolumeClaim, error) {
+	if m.pvcName != "" {
+		fmt.Fprintf(stepOut, "Fetching PVC %s/%s of the source snapshot from origin cluster...\n", m.pvcNamespace, m.pvcName)
+		pvc, err := client.CoreV1().PersistentVolumeClaims(m.pvcNamespace).Get(ctx, m.pvcName, metav1.GetOptions{})
+		if err == nil {
+			return pvc, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get source PVC: %w", err)
+		}
+	}
+
+	if createPVC {
+		fmt.Fprintf(stepOut, "⚠ Warning: The source PVC of snapshot %s/%s is not available, the destination PVC is ReadWriteOnce in the default storage class unless set by --pvc-template or --dest-storage-class\n", m.pvcNamespace, m.snapshotName)
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.pvcName,
+			Namespace: m.pvcNamespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+	}, nil
+}
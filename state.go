@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status of a PVC in the --state-file.
+const (
+	statePending   = "pending"
+	stateStarted   = "started"
+	stateSucceeded = "succeeded"
+	stateFailed    = "failed"
+)
+
+// runState is the schema of the --state-file: the progress of every PVC of a
+// bulk run, rewritten after each PVC so that a crashed or interrupted run can
+// be resumed with --resume-from-state.
+type runState struct {
+	RunID string      `json:"runID"`
+	PVCs  []*pvcState `json:"pvcs"`
+}
+
+// pvcState is the progress of one PVC. The snapshot names are recorded before
+// the migration starts, so a resumed run can reuse the resources a crashed
+// one left under them.
+type pvcState struct {
+	Namespace            string `json:"namespace"`
+	Name                 string `json:"name"`
+	Status               string `json:"status"`
+	OriginSnapshot       string `json:"originSnapshot"`
+	DestinationSnapshot  string `json:"destinationSnapshot"`
+	DestinationNamespace string `json:"destinationNamespace"`
+	// Result is what the last attempt created, as far as it got
+	Result *migrationResult `json:"result,omitempty"`
+}
+
+// resumeState is the loaded --resume-from-state file, nil when the flag is
+// unset.
+var resumeState *runState
+
+// runProgress tracks the --state-file of a bulk run.
+type runProgress struct {
+	path  string
+	state runState
+	pvcs  map[string]*pvcState
+}
+
+// loadState reads a --resume-from-state file.
+func loadState(path string) (*runState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var s runState
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	for _, p := range s.PVCs {
+		switch p.Status {
+		case statePending, stateStarted, stateSucceeded, stateFailed:
+		default:
+			return nil, fmt.Errorf("invalid state file %s: PVC %s/%s has unknown status %q", path, p.Namespace, p.Name, p.Status)
+		}
+	}
+	return &s, nil
+}
+
+// resumeMigrations drops the migrations a previous run completed according to
+// its state, and gives those it left started or failed the snapshot names it
+// used, so their leftover resources are reused rather than duplicated. It
+// returns the migrations left to run and the states of the completed ones.
+func resumeMigrations(previous *runState, migrations []*migration) ([]*migration, []*pvcState) {
+	byPVC := make(map[string]*pvcState, len(previous.PVCs))
+	for _, p := range previous.PVCs {
+		byPVC[p.Namespace+"/"+p.Name] = p
+	}
+
+	var remaining []*migration
+	var completed []*pvcState
+	for _, m := range migrations {
+		p := byPVC[m.pvcNamespace+"/"+m.pvcName]
+		switch {
+		case p == nil || p.Status == statePending:
+			remaining = append(remaining, m)
+		case p.Status == stateSucceeded:
+			completed = append(completed, p)
+		default:
+			m.snapshotName = p.OriginSnapshot
+			m.destSnapshotName = p.DestinationSnapshot
+			m.resumed = true
+			remaining = append(remaining, m)
+		}
+	}
+
+	fmt.Fprintf(stepOut, "Resuming run %s: %d PVCs already migrated, %d to retry or migrate\n", previous.RunID, len(completed), len(remaining))
+	for _, p := range completed {
+		fmt.Fprintf(stepOut, "  Skipping %s/%s, already migrated\n", p.Namespace, p.Name)
+	}
+	return remaining, completed
+}
+
+// newRunProgress starts the --state-file of a run: the completed PVCs of a
+// resumed run, and the migrations left to run as pending.
+func newRunProgress(path string, completed []*pvcState, migrations []*migration) (*runProgress, error) {
+	rp := &runProgress{path: path, state: runState{RunID: runID}, pvcs: make(map[string]*pvcState)}
+	rp.state.PVCs = append(rp.state.PVCs, completed...)
+	for _, m := range migrations {
+		p := &pvcState{
+			Namespace:            m.pvcNamespace,
+			Name:                 m.pvcName,
+			Status:               statePending,
+			OriginSnapshot:       m.snapshotName,
+			DestinationSnapshot:  m.destSnapshotName,
+			DestinationNamespace: m.destNamespace,
+		}
+		rp.state.PVCs = append(rp.state.PVCs, p)
+		rp.pvcs[m.pvcNamespace+"/"+m.pvcName] = p
+	}
+	if err := rp.write(); err != nil {
+		return nil, fmt.Errorf("failed to write state file: %w", err)
+	}
+	return rp, nil
+}
+
+// update records the status of a migration, and its result once it has one,
+// and rewrites the state file. A failure to write it is only a warning, since
+// the migrations themselves are unaffected.
+func (rp *runProgress) update(m *migration, status string, res *migrationResult) {
+	if rp == nil {
+		return
+	}
+	p := rp.pvcs[m.pvcNamespace+"/"+m.pvcName]
+	p.Status = status
+	// The migration may have reused another snapshot in progress
+	p.OriginSnapshot = m.snapshotName
+	if res != nil {
+		p.Result = res
+	}
+	if err := rp.write(); err != nil {
+		fmt.Fprintf(stepOut, "⚠ Warning: Failed to write state file: %v\n", err)
+	}
+}
+
+func (rp *runProgress) write() error {
+	return writeFileAtomic(rp.path, func(w io.Writer) error {
+		return newJSONEncoder(w).Encode(rp.state)
+	})
+}
+
+// reuseResumedSnapshot accepts an origin snapshot that already exists under
+// the name a resumed PVC was given, when a previous run took it of the same
+// PVC, instead of failing to create it.
+func reuseResumedSnapshot(ctx context.Context, client snapshotclient.Interface, m *migration) error {
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(m.pvcNamespace).Get(ctx, m.snapshotName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get existing snapshot %s/%s: %w", m.pvcNamespace, m.snapshotName, err)
+	}
+	if snapshot.Annotations[annotationSourcePVC] != m.pvcNamespace+"/"+m.pvcName {
+		return fmt.Errorf("snapshot %s/%s already exists and was not taken of PVC %s/%s by snapshift", m.pvcNamespace, m.snapshotName, m.pvcNamespace, m.pvcName)
+	}
+	fmt.Fprintf(stepOut, "Origin snapshot %s/%s was left by the resumed run, reusing it\n", m.pvcNamespace, m.snapshotName)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStateFileRoundTrip(t *testing.T) {
+	setFlag(t, &runID, "1700000000-abcd")
+	path := filepath.Join(t.TempDir(), "state.json")
+	data, logs := newMigration("apps", "data"), newMigration("apps", "logs")
+	for _, m := range []*migration{data, logs} {
+		m.snapshotName = m.pvcName + "-snap"
+		m.destSnapshotName = m.pvcName + "-snap"
+	}
+
+	var rp *runProgress
+	out := captureStdout(t, func() {
+		var err error
+		if rp, err = newRunProgress(path, nil, []*migration{data, logs}); err != nil {
+			t.Fatal(err)
+		}
+		rp.update(data, stateStarted, nil)
+		rp.update(data, stateSucceeded, &migrationResult{SourcePVC: objectRef{Namespace: "apps", Name: "data"}})
+		rp.update(logs, stateStarted, nil)
+	})
+	if out != "" {
+		t.Errorf("writing the state printed %q", out)
+	}
+
+	s, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RunID != runID || len(s.PVCs) != 2 {
+		t.Fatalf("state = %+v", s)
+	}
+	if s.PVCs[0].Status != stateSucceeded || s.PVCs[0].Result == nil || s.PVCs[1].Status != stateStarted {
+		t.Errorf("PVC states = %+v, %+v", s.PVCs[0], s.PVCs[1])
+	}
+
+	// A resumed run skips the completed PVC and retries the started one
+	// under the names it used
+	next := []*migration{newMigration("apps", "data"), newMigration("apps", "logs"), newMigration("apps", "new")}
+	var remaining []*migration
+	var completed []*pvcState
+	out = captureStdout(t, func() { remaining, completed = resumeMigrations(s, next) })
+	if len(completed) != 1 || completed[0].Name != "data" {
+		t.Errorf("completed = %+v, want apps/data", completed)
+	}
+	if len(remaining) != 2 || remaining[0].pvcName != "logs" || remaining[1].pvcName != "new" {
+		t.Fatalf("remaining = %+v, want apps/logs and apps/new", remaining)
+	}
+	if !remaining[0].resumed || remaining[0].snapshotName != "logs-snap" || remaining[0].destSnapshotName != "logs-snap" {
+		t.Errorf("retried migration = %+v, want the names of the previous run", remaining[0])
+	}
+	if remaining[1].resumed {
+		t.Errorf("a PVC absent from the state is marked resumed")
+	}
+	if !strings.Contains(out, "Skipping apps/data, already migrated") {
+		t.Errorf("output misses the skipped PVC:\n%s", out)
+	}
+}
+
+func TestLoadStateRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown status": `{"runID": "1", "pvcs": [{"namespace": "apps", "name": "data", "status": "done"}]}`,
+		"unknown field":  `{"runID": "1", "pvc": []}`,
+		"not JSON":       `pvcs: []`,
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-"))
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadState(path); err == nil || !strings.Contains(err.Error(), "invalid state file") {
+			t.Errorf("%s: loadState = %v, want an invalid state file error", name, err)
+		}
+	}
+}
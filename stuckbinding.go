@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// waitDestSnapshotReady waits for the destination snapshot of st to be ready.
+// With --retry-stuck-binding, a snapshot still not ready after
+// --stuck-binding-threshold is deleted along with its content and both are
+// recreated, up to that many times, before the last attempt waits out the
+// rest of --timeout. Only a pair this run created is recreated.
+func waitDestSnapshotReady(ctx context.Context, d *destination, m *migration, origin *originState, st *destState, content *snapshotv1.VolumeSnapshotContent, contentClass, destClass string) (*snapshotv1.VolumeSnapshot, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt >= stuckBindingRetries || !st.snapshotCreated || !st.contentCreated {
+			return waitForSnapshotReady(ctx, d.snap, m.destNamespace, m.destSnapshotName, waitForReady)
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, stuckBindingThreshold)
+		snapshot, err := waitForSnapshotReady(attemptCtx, d.snap, m.destNamespace, m.destSnapshotName, waitForReady)
+		stuck := errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+		if err == nil || !stuck {
+			return snapshot, err
+		}
+
+		fmt.Fprintf(stepOut, "%s⚠ Warning: Destination snapshot %s/%s is not ready after %s, recreating it and its VolumeSnapshotContent (retry %d/%d)\n",
+			d.prefix, m.destNamespace, m.destSnapshotName, stuckBindingThreshold, attempt+1, stuckBindingRetries)
+		if err := deleteStuckPair(ctx, d, m, st); err != nil {
+			return nil, err
+		}
+		if err := retryDestSnapshotPair(ctx, d, m, origin, st, content, contentClass, destClass); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// deleteStuckPair deletes a destination snapshot and its content that never
+// became ready, waiting for both to be gone so that they can be recreated
+// under the same names. The content is Retain, so the storage snapshot is
+// kept.
+func deleteStuckPair(ctx context.Context, d *destination, m *migration, st *destState) error {
+	err := d.snap.SnapshotV1().VolumeSnapshots(m.destNamespace).Delete(ctx, m.destSnapshotName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete stuck destination snapshot %s/%s: %w", m.destNamespace, m.destSnapshotName, err)
+	}
+	if err := waitDeleted(ctx, func() error {
+		_, err := d.snap.SnapshotV1().VolumeSnapshots(m.destNamespace).Get(ctx, m.destSnapshotName, metav1.GetOptions{})
+		return err
+	}); err != nil {
+		return fmt.Errorf("stuck destination snapshot %s/%s was not deleted: %w", m.destNamespace, m.destSnapshotName, err)
+	}
+	st.snapshotCreated = false
+
+	err = d.snap.SnapshotV1().VolumeSnapshotContents().Delete(ctx, st.contentName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete stuck destination VolumeSnapshotContent %s: %w", st.contentName, err)
+	}
+	if err := waitDeleted(ctx, func() error {
+		_, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, st.contentName, metav1.GetOptions{})
+		return err
+	}); err != nil {
+		return fmt.Errorf("stuck destination VolumeSnapshotContent %s was not deleted: %w", st.contentName, err)
+	}
+	st.contentCreated = false
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// outputText is the --output format printing only the failures of a bulk run
+// after the output of every step.
+const outputText = "text"
+
+// Longest column values in the results table before they are truncated
+const (
+	maxNameWidth   = 40
+	maxHandleWidth = 24
+	maxErrorWidth  = 60
+)
+
+// stepOut receives the output of each step of a migration run. It is stdout,
+// except with --inspect-plan or a JSON summary on stdout, where it is stderr so
+// that stdout holds nothing else, and with --summary-only, where it is
+// discarded.
+var stepOut io.Writer = os.Stdout
+
+// bulkResult is the outcome of one PVC migration in a bulk run.
+type bulkResult struct {
+	m        *migration
+	res      *migrationResult
+	err      error
+	duration time.Duration
+}
+
+// progress reports bulk migration progress with --summary-only, while the
+// per-step output of each migration is discarded. On a terminal the counter
+// is updated in place, otherwise each update is a line.
+type progress struct {
+	out   io.Writer
+	tty   bool
+	total int
+}
+
+// startSummaryOnly discards the step output for the rest of the run and
+// returns the progress reporter writing where the step output went. The
+// returned function restores the step output.
+func startSummaryOnly(total int) (*progress, func()) {
+	out := stepOut
+	stepOut = io.Discard
+	restore := func() { stepOut = out }
+	return &progress{out: out, tty: isTerminalWriter(out), total: total}, restore
+}
+
+// update shows the number of PVCs done so far and the last one finished.
+func (p *progress) update(done int, r bulkResult) {
+	status := "✓"
+	if r.err != nil {
+		status = "✗"
+	}
+	line := fmt.Sprintf("migrated %d/%d (%s %s/%s)", done, p.total, status, r.m.pvcNamespace, r.m.pvcName)
+	if p.tty {
+		// Clear the rest of the previous, possibly longer, line
+		fmt.Fprintf(p.out, "\r%s\033[K", line)
+		if done == p.total {
+			fmt.Fprintln(p.out)
+		}
+		return
+	}
+	fmt.Fprintln(p.out, line)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// isTerminalWriter tells whether w is a file on a terminal.
+func isTerminalWriter(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// newJSONEncoder returns an encoder for the JSON output written to w,
+// indented with --pretty or, when the flag is unset, on a terminal, and on a
+// single line otherwise.
+func newJSONEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if prettyJSON(w) {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
+
+// prettyJSON tells whether the JSON output written to w is indented.
+func prettyJSON(w io.Writer) bool {
+	if prettySet {
+		return pretty
+	}
+	return isTerminalWriter(w)
+}
+
+// tableOutput tells whether the results of a bulk run are printed as a table:
+// with --output table or --summary-only, or by default on a terminal.
+func tableOutput() bool {
+	return summaryOnly || resultsOutput == outputTable || (resultsOutput == "" && isTerminal(os.Stdout))
+}
+
+// summaryFormat returns the format of the summary of a bulk run: json, table,
+// or text. A --summary-out file defaults to a table, stdout to a table only on
+// a terminal.
+func summaryFormat() string {
+	switch {
+	case resultsOutput == outputJSON:
+		return outputJSON
+	case summaryOut != "" && resultsOutput == "", tableOutput():
+		return outputTable
+	default:
+		return outputText
+	}
+}
+
+// runSummary is the JSON summary of a bulk run.
+type runSummary struct {
+	RunID          string             `json:"runID"`
+	Total          int                `json:"total"`
+	Succeeded      int                `json:"succeeded"`
+	Failed         int                `json:"failed"`
+	NotAttempted   int                `json:"notAttempted"`
+	Error          string             `json:"error,omitempty"`
+	SnapshotBudget *snapshotBudget    `json:"snapshotBudget,omitempty"`
+	Results        []*migrationResult `json:"results"`
+}
+
+// printSummary prints the summary of a bulk run of total PVCs in format: the
+// results table or the failures, and the counts. interrupted is set when the
+// run stopped before attempting every PVC, and budget with
+// --max-total-snapshots.
+func printSummary(w io.Writer, format string, results, failed []bulkResult, total int, interrupted error, budget *snapshotBudget) error {
+	skipped := total - len(results)
+	if format == outputJSON {
+		s := runSummary{
+			RunID:          runID,
+			Total:          total,
+			Succeeded:      len(results) - len(failed),
+			Failed:         len(failed),
+			NotAttempted:   skipped,
+			SnapshotBudget: budget,
+			Results:        make([]*migrationResult, 0, len(results)),
+		}
+		if interrupted != nil {
+			s.Error = interrupted.Error()
+		}
+		for _, r := range results {
+			s.Results = append(s.Results, r.res)
+		}
+		return newJSONEncoder(w).Encode(s)
+	}
+
+	if format == outputTable {
+		printResults(w, results)
+	} else if len(failed) > 0 {
+		printFailures(w, failed)
+	}
+	fmt.Fprintf(w, "\nMigrated %d/%d PVCs\n", len(results)-len(failed), total)
+	if budget != nil {
+		fmt.Fprintf(w, "Created %d of --max-total-snapshots %d origin snapshots\n", budget.Used, budget.Limit)
+	}
+	switch {
+	case interrupted != nil:
+		fmt.Fprintf(w, "Interrupted, %d PVCs not attempted: %v\n", skipped, interrupted)
+	case budget != nil && len(budget.Skipped) > 0:
+		fmt.Fprintf(w, "Reached --max-total-snapshots, %d PVCs not attempted:\n", len(budget.Skipped))
+		for _, ref := range budget.Skipped {
+			fmt.Fprintf(w, "  - %s/%s\n", ref.Namespace, ref.Name)
+		}
+	case skipped > 0:
+		fmt.Fprintf(w, "Stopped at the first failure with --fail-fast, %d PVCs not attempted\n", skipped)
+	}
+	return nil
+}
+
+// writeFileAtomic writes a file, such as the summary or the state file, to
+// path through a temporary file in the same directory, renamed over path once
+// complete, so that readers never see a partial file.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	// Nothing is left to remove once the file is renamed
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// printResults prints a table of the outcome of every PVC of a bulk run, and
+// how many succeeded and failed.
+func printResults(w io.Writer, results []bulkResult) {
+	fmt.Fprintf(w, "\nResults:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  NAMESPACE\tPVC\tORIGIN SNAPSHOT\tDEST SNAPSHOT\tHANDLE\tSTATUS\tDURATION\tERROR\t\n")
+	failed := 0
+	for _, r := range results {
+		status, msg := "migrated", "-"
+		if r.err != nil {
+			status, msg = "failed", strings.ReplaceAll(r.err.Error(), "\n", " ")
+			failed++
+		}
+		originSnapshot, handle := "-", "-"
+		if r.res != nil && r.res.OriginSnapshot != nil {
+			originSnapshot = r.res.OriginSnapshot.Name
+		}
+		if r.res != nil && r.res.SnapshotHandle != "" {
+			handle = r.res.SnapshotHandle
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			truncate(r.m.pvcNamespace, maxNameWidth), truncate(r.m.pvcName, maxNameWidth),
+			truncate(originSnapshot, maxNameWidth), truncate(r.m.destNamespace+"/"+r.m.destSnapshotName, maxNameWidth),
+			truncate(handle, maxHandleWidth), status, r.duration.Round(time.Second), truncate(msg, maxErrorWidth))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "  %d succeeded, %d failed\n", len(results)-failed, failed)
+}
+
+// truncate shortens s to width characters, ending it with an ellipsis.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// printFailures prints the error of every failed PVC of a bulk run.
+func printFailures(w io.Writer, failed []bulkResult) {
+	fmt.Fprintf(w, "\nFailures:\n")
+	for _, r := range failed {
+		fmt.Fprintf(w, "  ✗ %s/%s: %s\n", r.m.pvcNamespace, r.m.pvcName, strings.ReplaceAll(r.err.Error(), "\n", " "))
+	}
+}
+
+// partialFailureError is returned by a bulk run in which some PVCs failed to
+// migrate. Each PVC's error is kept, so callers can tell which failed and why.
+type partialFailureError struct {
+	failed []bulkResult
+	total  int
+}
+
+func (e *partialFailureError) Error() string {
+	names := make([]string, len(e.failed))
+	for i, r := range e.failed {
+		names[i] = r.m.pvcNamespace + "/" + r.m.pvcName
+	}
+	return fmt.Sprintf("failed to migrate %d of %d PVCs: %s", len(e.failed), e.total, strings.Join(names, ", "))
+}
+
+// Unwrap returns the error of each failed PVC.
+func (e *partialFailureError) Unwrap() []error {
+	errs := make([]error, len(e.failed))
+	for i, r := range e.failed {
+		errs[i] = r.err
+	}
+	return errs
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintSummaryJSON(t *testing.T) {
+	ok := bulkResult{m: newMigration("apps", "data"), res: &migrationResult{SourcePVC: objectRef{Namespace: "apps", Name: "data"}}}
+	bad := bulkResult{m: newMigration("apps", "logs"), res: &migrationResult{SourcePVC: objectRef{Namespace: "apps", Name: "logs"}, Error: "boom"}, err: errors.New("boom")}
+
+	var buf bytes.Buffer
+	interrupted := errors.New("interrupted after 2/3 PVCs: context canceled")
+	if err := printSummary(&buf, outputJSON, []bulkResult{ok, bad}, []bulkResult{bad}, 3, interrupted, nil); err != nil {
+		t.Fatal(err)
+	}
+	var s runSummary
+	if err := json.Unmarshal(buf.Bytes(), &s); err != nil {
+		t.Fatalf("summary is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if s.Total != 3 || s.Succeeded != 1 || s.Failed != 1 || s.NotAttempted != 1 || s.Error != interrupted.Error() {
+		t.Errorf("summary counts = %+v", s)
+	}
+	if len(s.Results) != 2 || s.Results[1].SourcePVC.Name != "logs" || s.Results[1].Error != "boom" {
+		t.Errorf("summary results = %+v", s.Results)
+	}
+
+	buf.Reset()
+	if err := printSummary(&buf, outputText, []bulkResult{ok, bad}, []bulkResult{bad}, 3, interrupted, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"✗ apps/logs: boom", "Migrated 1/3 PVCs", "Interrupted, 1 PVCs not attempted"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text summary misses %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintSummaryJSONPretty(t *testing.T) {
+	res := &migrationResult{
+		SourcePVC:    objectRef{Namespace: "apps", Name: "data"},
+		Destinations: []destResult{{Cluster: "dr", PVCs: []objectRef{{Namespace: "apps", Name: "data"}}}},
+	}
+	results := []bulkResult{{m: newMigration("apps", "data"), res: res}}
+	summary := func() string {
+		var buf bytes.Buffer
+		if err := printSummary(&buf, outputJSON, results, nil, 1, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	// Not a terminal, so compact unless --pretty is given
+	compact := summary()
+	if strings.Count(compact, "\n") != 1 || !strings.HasSuffix(compact, "}\n") {
+		t.Errorf("compact summary is not a single line:\n%s", compact)
+	}
+	if again := summary(); again != compact {
+		t.Errorf("summary differs between runs:\n%s\n%s", compact, again)
+	}
+
+	setFlag(t, &prettySet, true)
+	setFlag(t, &pretty, true)
+	indented := summary()
+	if !strings.Contains(indented, "\n  \"total\": 1,\n") {
+		t.Errorf("--pretty summary is not indented:\n%s", indented)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(indented)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String()+"\n" != compact {
+		t.Errorf("--pretty summary differs from the compact one:\n%s\n%s", indented, compact)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "summary.json")
+	if err := os.WriteFile(path, []byte("previous run, longer than the new summary\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "{}\n")
+		return err
+	}); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "{}\n" {
+		t.Errorf("summary file = %q, want the new summary only", data)
+	}
+
+	// A failed write leaves the previous file in place and no temporary file
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "{")
+		return errors.New("encoding failed")
+	}); err == nil {
+		t.Fatal("writeFileAtomic succeeded despite the write error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{}\n" {
+		t.Errorf("summary file after a failed write = %q", data)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the summary file", len(entries))
+	}
+}
+
+func TestPrintSummarySnapshotBudget(t *testing.T) {
+	ok := bulkResult{m: newMigration("apps", "data"), res: &migrationResult{SourcePVC: objectRef{Namespace: "apps", Name: "data"}}}
+	budget := &snapshotBudget{Limit: 1, Used: 1, Skipped: []objectRef{{Namespace: "apps", Name: "logs"}, {Namespace: "apps", Name: "cache"}}}
+
+	var buf bytes.Buffer
+	if err := printSummary(&buf, outputText, []bulkResult{ok}, nil, 3, nil, budget); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Migrated 1/3 PVCs", "Created 1 of --max-total-snapshots 1 origin snapshots", "Reached --max-total-snapshots, 2 PVCs not attempted", "  - apps/logs\n", "  - apps/cache\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text summary misses %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "--fail-fast") {
+		t.Errorf("text summary blames --fail-fast:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := printSummary(&buf, outputJSON, []bulkResult{ok}, nil, 3, nil, budget); err != nil {
+		t.Fatal(err)
+	}
+	var s runSummary
+	if err := json.Unmarshal(buf.Bytes(), &s); err != nil {
+		t.Fatalf("summary is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if s.NotAttempted != 2 || s.SnapshotBudget == nil || s.SnapshotBudget.Used != 1 || len(s.SnapshotBudget.Skipped) != 2 {
+		t.Errorf("summary = %+v, budget = %+v", s, s.SnapshotBudget)
+	}
+}
+
+func TestStartSummaryOnlyKeepsStdout(t *testing.T) {
+	var steps bytes.Buffer
+	setFlag[io.Writer](t, &stepOut, &steps)
+	stdout := os.Stdout
+
+	prog, restore := startSummaryOnly(1)
+	fmt.Fprintln(stepOut, "Creating snapshot")
+	prog.update(1, bulkResult{m: newMigration("apps", "data")})
+	if os.Stdout != stdout {
+		t.Error("--summary-only replaced os.Stdout")
+	}
+	restore()
+	fmt.Fprintln(stepOut, "done")
+
+	if got, want := steps.String(), "migrated 1/1 (✓ apps/data)\ndone\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
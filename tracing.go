@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/fabiorauber/snapshift")
+
+// setupTracing installs an OTLP/HTTP tracer provider when an endpoint is given
+// via --otel-endpoint or OTEL_EXPORTER_OTLP_ENDPOINT. Without one, the global
+// no-op provider is kept. The returned function flushes and stops the exporter.
+func setupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("snapshift")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// phase is a traced step of a migration.
+type phase struct {
+	name  string
+	span  trace.Span
+	start time.Time
+}
+
+// startPhase starts a child span for a migration phase.
+func startPhase(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *phase) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, &phase{name: name, span: span, start: time.Now()}
+}
+
+// end records the phase duration and error, if any, and ends its span.
+func (p *phase) end(err error) {
+	phaseDuration.WithLabelValues(p.name).Observe(time.Since(p.start).Seconds())
+	endSpan(p.span, p.start, err)
+}
+
+// endSpan records the duration and error, if any, on a span and ends it.
+func endSpan(span trace.Span, start time.Time, err error) {
+	span.SetAttributes(attribute.Float64("snapshift.duration_seconds", time.Since(start).Seconds()))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// replaceOutdatedDestSnapshot makes way, with --update-existing-snapshot, for
+// a destination snapshot and content of the same names to be created for a
+// new snapshot handle. The snapshot source of both is immutable, so an
+// existing pair for another handle is deleted rather than updated. Only
+// objects labeled as managed by snapshift are deleted, and only retained
+// contents, which leaves the storage snapshot of the previous run to its
+// origin snapshot.
+func replaceOutdatedDestSnapshot(ctx context.Context, d *destination, namespace, name, contentName, snapshotHandle string) error {
+	if !updateExistingSnapshot {
+		return nil
+	}
+
+	var outdated []*snapshotv1.VolumeSnapshotContent
+	content, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, contentName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		content = nil
+	case err != nil:
+		return fmt.Errorf("failed to check for existing VolumeSnapshotContent: %w", err)
+	case contentHandle(content) != snapshotHandle:
+		outdated = append(outdated, content)
+	}
+
+	snapshot, err := d.snap.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		snapshot = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check for existing VolumeSnapshot: %w", err)
+	}
+	if snapshot != nil {
+		if !managedBySnapshift(snapshot.Labels) {
+			return fmt.Errorf("VolumeSnapshot %s/%s is not labeled %s=%s, refusing to replace a snapshot snapshift did not create", namespace, name, managedByLabel, managedBy)
+		}
+		// Only a snapshot pre-bound to a content is replaced: deleting a
+		// dynamically provisioned one could delete its storage snapshot
+		if snapshot.Spec.Source.VolumeSnapshotContentName == nil {
+			return fmt.Errorf("VolumeSnapshot %s/%s is not pre-bound to a VolumeSnapshotContent, refusing to replace it", namespace, name)
+		}
+		bound := boundContentName(snapshot)
+		if bound == contentName && len(outdated) == 0 && content != nil {
+			// Already bound to a content for this handle
+			return nil
+		}
+		if bound != contentName {
+			other, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, bound, metav1.GetOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+			case err != nil:
+				return fmt.Errorf("failed to get VolumeSnapshotContent %s of the existing VolumeSnapshot %s/%s: %w", bound, namespace, name, err)
+			default:
+				outdated = append(outdated, other)
+			}
+		}
+	}
+	if snapshot == nil && len(outdated) == 0 {
+		return nil
+	}
+	for _, c := range outdated {
+		if !managedBySnapshift(c.Labels) {
+			return fmt.Errorf("VolumeSnapshotContent %s is not labeled %s=%s, refusing to replace a content snapshift did not create", c.Name, managedByLabel, managedBy)
+		}
+		if c.Spec.DeletionPolicy != snapshotv1.VolumeSnapshotContentRetain {
+			return fmt.Errorf("VolumeSnapshotContent %s has DeletionPolicy %s, deleting it to update the snapshot would delete its storage snapshot", c.Name, c.Spec.DeletionPolicy)
+		}
+	}
+
+	if snapshot != nil {
+		fmt.Fprintf(stepOut, "%sDeleting outdated VolumeSnapshot %s/%s to update it...\n", d.prefix, namespace, name)
+		err := d.snap.SnapshotV1().VolumeSnapshots(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete outdated VolumeSnapshot %s/%s: %w", namespace, name, err)
+		}
+		if err := waitDeleted(ctx, func() error {
+			_, err := d.snap.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		}); err != nil {
+			return fmt.Errorf("outdated VolumeSnapshot %s/%s was not deleted: %w", namespace, name, err)
+		}
+	}
+	for _, c := range outdated {
+		fmt.Fprintf(stepOut, "%sDeleting outdated VolumeSnapshotContent %s (handle %s)...\n", d.prefix, c.Name, contentHandle(c))
+		err := d.snap.SnapshotV1().VolumeSnapshotContents().Delete(ctx, c.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete outdated VolumeSnapshotContent %s: %w", c.Name, err)
+		}
+		if err := waitDeleted(ctx, func() error {
+			_, err := d.snap.SnapshotV1().VolumeSnapshotContents().Get(ctx, c.Name, metav1.GetOptions{})
+			return err
+		}); err != nil {
+			return fmt.Errorf("outdated VolumeSnapshotContent %s was not deleted: %w", c.Name, err)
+		}
+	}
+	fmt.Fprintf(stepOut, "%s✓ Removed the outdated destination snapshot, recreating it for handle %s\n", d.prefix, snapshotHandle)
+	return nil
+}
+
+func managedBySnapshift(labels map[string]string) bool {
+	return labels[managedByLabel] == managedBy
+}
+
+// boundContentName returns the content a snapshot is bound to, or is
+// pre-bound to.
+func boundContentName(snapshot *snapshotv1.VolumeSnapshot) string {
+	if snapshot.Status != nil && snapshot.Status.BoundVolumeSnapshotContentName != nil {
+		return *snapshot.Status.BoundVolumeSnapshotContentName
+	}
+	if snapshot.Spec.Source.VolumeSnapshotContentName != nil {
+		return *snapshot.Spec.Source.VolumeSnapshotContentName
+	}
+	return ""
+}
+
+// waitDeleted polls get until it reports the object is not found.
+func waitDeleted(ctx context.Context, get func() error) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		err := get()
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	snapshotclient "github.com/kubernetes-csi/external-snapshotter/client/v6/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Values of --wait-timeout-action
+const (
+	timeoutActionFail  = "fail"
+	timeoutActionLeave = "leave"
+)
+
+// exitWaitTimeout is the exit status of a run whose only failures are
+// readiness timeouts left in place with --wait-timeout-action=leave.
+const exitWaitTimeout = 3
+
+// errWaitTimeout is wrapped by the errors of readiness waits that ran out of
+// time, as opposed to being cancelled.
+var errWaitTimeout = errors.New("timeout")
+
+// waitEnded returns the error of a readiness wait whose context is done.
+func waitEnded(ctx context.Context, what string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w waiting for %s", errWaitTimeout, what)
+	}
+	return fmt.Errorf("cancelled waiting for %s: %w", what, ctx.Err())
+}
+
+// leaveOnTimeout reports whether err is a readiness timeout whose snapshot
+// is to be left alone, since it may still become ready.
+func leaveOnTimeout(err error) bool {
+	return waitTimeoutAction == timeoutActionLeave && errors.Is(err, errWaitTimeout)
+}
+
+// leftPending reports whether a run failed only on readiness timeouts that
+// were left in place, and so should exit with exitWaitTimeout.
+func leftPending(err error) bool {
+	if waitTimeoutAction != timeoutActionLeave {
+		return false
+	}
+	var partial *partialFailureError
+	if errors.As(err, &partial) {
+		for _, r := range partial.failed {
+			if !errors.Is(r.err, errWaitTimeout) {
+				return false
+			}
+		}
+		return true
+	}
+	return errors.Is(err, errWaitTimeout)
+}
+
+// printLeftSnapshot prints the status of a snapshot left in place after a
+// readiness timeout, and how to check on it.
+func printLeftSnapshot(prefix, flags string, client snapshotclient.Interface, namespace, name string) {
+	fmt.Fprintf(stepOut, "\n%s⚠ Timed out, leaving VolumeSnapshot %s/%s in place as it may still become ready\n", prefix, namespace, name)
+	// The run's context is done, so read the status with a fresh one
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupHookTimeout)
+	defer cancel()
+	snapshot, err := client.SnapshotV1().VolumeSnapshots(namespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case err != nil:
+		fmt.Fprintf(stepOut, "%s  Failed to read its status: %v\n", prefix, err)
+	case snapshot.Status == nil:
+		fmt.Fprintf(stepOut, "%s  Status: not reported yet\n", prefix)
+	default:
+		ready := snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+		content := "<none>"
+		if snapshot.Status.BoundVolumeSnapshotContentName != nil {
+			content = *snapshot.Status.BoundVolumeSnapshotContentName
+		}
+		fmt.Fprintf(stepOut, "%s  Status: ReadyToUse=%v, VolumeSnapshotContent %s\n", prefix, ready, content)
+		if snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+			fmt.Fprintf(stepOut, "%s  Last error: %s\n", prefix, *snapshot.Status.Error.Message)
+		}
+	}
+	fmt.Fprintf(stepOut, "%s  Check it with: %s\n", prefix, kubectl(flags, fmt.Sprintf("get volumesnapshot -n %s %s", namespace, name)))
+	fmt.Fprintf(stepOut, "%s  Delete it, and its content if retained, if it never becomes ready\n", prefix)
+}
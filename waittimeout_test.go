@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLeftPending(t *testing.T) {
+	timedOut := fmt.Errorf("failed waiting for destination snapshot: %w", waitEnded(expired(t), "snapshot to be ready"))
+	failed := errors.New("failed to create destination snapshot")
+	bulk := func(errs ...error) error {
+		e := &partialFailureError{total: len(errs) + 1}
+		for _, err := range errs {
+			e.failed = append(e.failed, bulkResult{m: newMigration("apps", "data"), err: err})
+		}
+		return e
+	}
+
+	tests := []struct {
+		name   string
+		action string
+		err    error
+		want   bool
+	}{
+		{"timeout left", timeoutActionLeave, timedOut, true},
+		{"timeout failed", timeoutActionFail, timedOut, false},
+		{"other error", timeoutActionLeave, failed, false},
+		{"bulk timeouts", timeoutActionLeave, bulk(timedOut, timedOut), true},
+		{"bulk with other error", timeoutActionLeave, bulk(timedOut, failed), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &waitTimeoutAction, tt.action)
+			if got := leftPending(tt.err); got != tt.want {
+				t.Errorf("leftPending(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitEndedCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitEnded(ctx, "snapshot to be ready"); errors.Is(err, errWaitTimeout) {
+		t.Errorf("cancelled wait reported as a timeout: %v", err)
+	}
+}
+
+// expired returns a context whose deadline has passed.
+func expired(t *testing.T) context.Context {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// probePodImage is the image of the pod --schedule-probe-pod creates to
+// trigger the binding of a WaitForFirstConsumer PVC.
+const probePodImage = "registry.k8s.io/pause:3.9"
+
+// bindDestPVC waits for a destination PVC to be bound. A PVC of a
+// WaitForFirstConsumer storage class only binds once a pod using it is
+// scheduled, so it is reported as pending instead, unless
+// --schedule-probe-pod is set, in which case a probe pod is run to bind it.
+func bindDestPVC(ctx context.Context, d *destination, namespace, name string) (pending bool, err error) {
+	pvc, err := d.k8s.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if pvc.Status.Phase == corev1.ClaimBound || pvc.Spec.VolumeName != "" {
+		return false, waitForPVCBound(ctx, d.k8s, namespace, name)
+	}
+
+	class, err := pvcStorageClass(ctx, d.k8s, pvc)
+	if err != nil {
+		return false, err
+	}
+	if class == nil || class.VolumeBindingMode == nil || *class.VolumeBindingMode != storagev1.VolumeBindingWaitForFirstConsumer {
+		return false, waitForPVCBound(ctx, d.k8s, namespace, name)
+	}
+
+	if !scheduleProbePod {
+		fmt.Fprintf(stepOut, "%sPVC %s uses storage class %s with WaitForFirstConsumer, it will bind and be restored when a pod first uses it\n", d.prefix, name, class.Name)
+		return true, nil
+	}
+
+	fmt.Fprintf(stepOut, "%sPVC %s uses storage class %s with WaitForFirstConsumer, scheduling a probe pod to bind it...\n", d.prefix, name, class.Name)
+	pod, err := d.k8s.CoreV1().Pods(namespace).Create(ctx, buildProbePod(pvc), metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to create probe pod: %w", err)
+	}
+	defer func() {
+		cleanupCtx, cancel := cleanupContext()
+		defer cancel()
+		if err := d.k8s.CoreV1().Pods(namespace).Delete(cleanupCtx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			fmt.Fprintf(stepOut, "%s⚠ Warning: Failed to delete probe pod %s/%s: %v\n", d.prefix, namespace, pod.Name, err)
+		}
+	}()
+
+	return false, waitForPVCBound(ctx, d.k8s, namespace, name)
+}
+
+// buildProbePod renders a pod that does nothing but use a PVC, so that the
+// scheduler picks a node and the PVC gets bound.
+func buildProbePod(pvc *corev1.PersistentVolumeClaim) *corev1.Pod {
+	container := corev1.Container{
+		Name:  "probe",
+		Image: probePodImage,
+	}
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		container.VolumeDevices = []corev1.VolumeDevice{{Name: "data", DevicePath: "/dev/data"}}
+	} else {
+		container.VolumeMounts = []corev1.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: true}}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "snapshift-probe-",
+			Namespace:    pvc.Namespace,
+			Labels:       map[string]string{managedByLabel: managedBy},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{container},
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name},
+				},
+			}},
+		},
+	}
+}